@@ -0,0 +1,131 @@
+package models
+
+import (
+	"time"
+
+	"github.com/mobilemindtech/go-utils/beego/db"
+)
+
+// EntityAudit structured audit of models with tag `goutils:"audit"`.
+// To enable, register model and call db.SetAuditStore(models.NewEntityAuditStore())
+type EntityAudit struct {
+	Id        int64     `form:"-" json:",string,omitempty"`
+	CreatedAt time.Time `orm:"auto_now_add;type(datetime)"`
+
+	EntityType string `orm:"size(100)" json:""`
+	EntityId   int64  `orm:"" json:",string"`
+	Action     string `orm:"size(20)" json:""`
+	Changes    string `orm:"type(text)" json:""`
+
+	Tenant *Tenant `orm:"null;rel(fk);on_delete(do_nothing)" valid:"" goutils:"tenant"`
+	User   *User   `orm:"null;rel(fk);on_delete(do_nothing)"`
+
+	Session *db.Session `orm:"-" json:"-" inject:""`
+}
+
+func NewEntityAudit(session *db.Session) *EntityAudit {
+	return &EntityAudit{Session: session}
+}
+
+func (this *EntityAudit) TableName() string {
+	return "entity_audits"
+}
+
+func (this *EntityAudit) TableIndex() [][]string {
+	return [][]string{
+		[]string{"EntityType", "EntityId"},
+	}
+}
+
+func (this *EntityAudit) IsPersisted() bool {
+	return this.Id > 0
+}
+
+// ListByEntity list audits of entity filtered by session tenant, oldest first
+func (this *EntityAudit) ListByEntity(entityType string, entityId int64) (*[]*EntityAudit, error) {
+	var results []*EntityAudit
+
+	query, err := this.Session.Query(this)
+
+	if err != nil {
+		return nil, err
+	}
+
+	query = query.
+		Filter("EntityType", entityType).
+		Filter("EntityId", entityId).
+		OrderBy("CreatedAt", "Id")
+
+	if err := this.Session.ToList(query, &results); err != nil {
+		return nil, err
+	}
+
+	return &results, nil
+}
+
+func (this *EntityAudit) ToEntry() *db.AuditEntry {
+	entry := &db.AuditEntry{
+		EntityType: this.EntityType,
+		EntityId:   this.EntityId,
+		Action:     db.AuditAction(this.Action),
+		Changes:    this.Changes,
+		CreatedAt:  this.CreatedAt,
+	}
+
+	if this.Tenant != nil {
+		entry.TenantId = this.Tenant.Id
+	}
+
+	if this.User != nil {
+		entry.UserId = this.User.Id
+	}
+
+	return entry
+}
+
+// EntityAuditStore implements db.AuditStore using EntityAudit model
+type EntityAuditStore struct {
+}
+
+func NewEntityAuditStore() *EntityAuditStore {
+	return &EntityAuditStore{}
+}
+
+func (this *EntityAuditStore) Write(session *db.Session, entry *db.AuditEntry) error {
+
+	audit := &EntityAudit{
+		EntityType: entry.EntityType,
+		EntityId:   entry.EntityId,
+		Action:     string(entry.Action),
+		Changes:    entry.Changes,
+	}
+
+	if entry.TenantId > 0 {
+		audit.Tenant = NewTenantWithId(entry.TenantId)
+	}
+
+	if entry.UserId > 0 {
+		audit.User = &User{Id: entry.UserId}
+	}
+
+	// insert directly, audit rows should not be audited, filtered or checked
+	_, err := session.GetDb().Insert(audit)
+	return err
+}
+
+func (this *EntityAuditStore) History(session *db.Session, entityType string, entityId int64) ([]*db.AuditEntry, error) {
+
+	results, err := NewEntityAudit(session).ListByEntity(entityType, entityId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []*db.AuditEntry{}
+
+	for _, it := range *results {
+		entries = append(entries, it.ToEntry())
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"log"
+	"os"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/mobilemindtech/go-utils/beego/db"
+	"github.com/mobilemindtech/go-utils/beego/dbtest"
+	uuid "github.com/satori/go.uuid"
+)
+
+func TestMain(m *testing.M) {
+
+	err := dbtest.Setup(new(Estado), new(Cidade), new(Tenant), new(User), new(EntityAudit))
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	os.Exit(m.Run())
+}
+
+// go test -v github.com/mobilemindtech/go-utils/app/models -run TestEntityAuditStore
+func TestEntityAuditStore(t *testing.T) {
+
+	session := dbtest.NewSession(t)
+	estado := &Estado{Nome: "Rio Grande do Sul", Uf: "RS"}
+	cidade := &Cidade{Nome: "Porto Alegre", Estado: estado}
+	acme := &Tenant{Name: "acme", Documento: "12345678901", Enabled: true, Uuid: uuid.NewV4().String(), Cidade: cidade}
+	other := &Tenant{Name: "other", Documento: "10987654321", Enabled: true, Uuid: uuid.NewV4().String(), Cidade: cidade}
+
+	for _, it := range []interface{}{estado, cidade, acme, other} {
+		if err := session.Save(it); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store := NewEntityAuditStore()
+	entries := []*db.AuditEntry{
+		{EntityType: "Product", EntityId: 1, TenantId: acme.Id, Action: db.AuditActionCreate},
+		{EntityType: "Product", EntityId: 1, TenantId: other.Id, Action: db.AuditActionCreate},
+		{EntityType: "Product", EntityId: 1, TenantId: acme.Id, Action: db.AuditActionUpdate},
+		{EntityType: "Product", EntityId: 2, TenantId: acme.Id, Action: db.AuditActionCreate},
+		{EntityType: "Product", EntityId: 1, TenantId: acme.Id, Action: db.AuditActionRemove},
+	}
+
+	for _, it := range entries {
+		if err := store.Write(session, it); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tenantSession := db.NewSessionWithTenant(acme).SetDatabase(session.GetDb())
+
+	history, err := store.History(tenantSession, "Product", 1)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actions := []db.AuditAction{db.AuditActionCreate, db.AuditActionUpdate, db.AuditActionRemove}
+
+	if len(history) != len(actions) {
+		t.Fatalf("expected %v entries of tenant, got %v", len(actions), len(history))
+	}
+
+	for i, it := range history {
+		if it.Action != actions[i] || it.TenantId != acme.Id {
+			t.Errorf("expected %v of tenant %v at %v, got %+v", actions[i], acme.Id, i, it)
+		}
+	}
+}
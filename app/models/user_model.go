@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/core/logs"
 	"github.com/mobilemindtech/go-utils/app/util"
 	"github.com/mobilemindtech/go-utils/beego/db"
 	"github.com/mobilemindtech/go-utils/support"
@@ -13,9 +14,11 @@ import (
 )
 
 var (
+	// Deprecated: legacy sha1 passwords, base64 or hex, are verified and rehashed on login. New passwords use support.HashPassword
 	UserHexPassword = false
 )
 
+// Deprecated: legacy hex sha1 passwords are detected on verify
 func OnUserHexPassword() { UserHexPassword = true }
 
 type User struct {
@@ -122,13 +125,18 @@ func (this *User) GetByToken(token string) (*User, error) {
 
 func (this *User) EncodePassword() {
 	this.GenerateToken(this.Password)
-	if UserHexPassword {
-		this.Password = support.TextToSha1Hex(this.Password)
-	} else {
-		this.Password = support.TextToSha1(this.Password)
+	if err := this.HashPassword(); err != nil {
+		logs.Error("error on hash user password: %v", err)
 	}
 }
 
+// HashPassword hash plain Password with support.HashPassword. Password is cleared on error, so login fails
+func (this *User) HashPassword() error {
+	encoded, err := support.HashPassword(this.Password)
+	this.Password = encoded
+	return err
+}
+
 func (this *User) ChangePassword(newPassword string) {
 	this.Password = newPassword
 	this.GenerateToken(this.Password)
@@ -148,12 +156,31 @@ func (this *User) GenereteUuid() string {
 }
 
 func (this *User) IsSamePassword(newPassword string) bool {
+	ok, _ := support.VerifyPassword(this.Password, newPassword)
+	return ok
+}
 
-	if UserHexPassword {
-		return support.IsSameHashHex(this.Password, newPassword)
-	} else {
-		return support.IsSameHash(this.Password, newPassword)
+// VerifyPassword verify password. rehash is true when password is legacy sha1 or of outdated hasher, see RehashPassword
+func (this *User) VerifyPassword(password string) (ok bool, rehash bool) {
+	return support.VerifyPassword(this.Password, password)
+}
+
+// RehashPassword hash password of user with current hasher and update it, to migrate legacy passwords on login
+func (this *User) RehashPassword(user *User, password string) error {
+
+	encoded, err := support.HashPassword(password)
+
+	if err != nil {
+		return err
+	}
+
+	if err := this.UpdatePassword(user.Id, encoded); err != nil {
+		return err
 	}
+
+	user.Password = encoded
+
+	return nil
 }
 
 func (this *User) IsPersisted() bool {
@@ -221,7 +248,7 @@ func (this *User) GenerateToken(password string) {
 
 	var err error
 	if this.Token, err = support.GenereteApiToken(this.Id, this.Uuid, password, this.ExpirationDate); err != nil {
-		fmt.Println("** error on generete api token: %v", err)
+		fmt.Printf("** error on generete api token: %v\n", err)
 	}
 
 }
@@ -272,6 +299,14 @@ func (this *User) UpdateLastLogin(userId int64) {
 		fmt.Println("last login update success")
 	}
 }
+
+// UpdatePassword update encoded password only
+func (this *User) UpdatePassword(userId int64, encoded string) error {
+	query := "update users set password = ? where id = ?"
+	_, err := db.NewRawQueryArgs(this.Session, query, encoded, userId).Execute()
+	return err
+}
+
 func (this *User) FirstName() string {
 	sp := strings.Split(this.Name, " ")
 	if len(sp) > 0 {
@@ -57,3 +57,8 @@ func (this *AuditorService) OnAuditWithNewDbSession(format string, v ...interfac
 	go action()
 
 }
+
+// EntityHistory list structured audit of entity. entity model should have tag `goutils:"audit"`
+func (this *AuditorService) EntityHistory(entity interface{}) ([]*db.AuditEntry, error) {
+	return this.Session.AuditHistory(entity)
+}
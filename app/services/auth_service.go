@@ -4,20 +4,15 @@ import (
 	"errors"
 	"fmt"
 	"github.com/beego/beego/v2/core/logs"
-	beego "github.com/beego/beego/v2/server/web"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/mobilemindtech/go-io/option"
 	"github.com/mobilemindtech/go-io/result"
 	"github.com/mobilemindtech/go-io/rio"
 	"github.com/mobilemindtech/go-utils/app/models"
-	"github.com/mobilemindtech/go-utils/app/util"
 	"github.com/mobilemindtech/go-utils/beego/db"
 	"github.com/mobilemindtech/go-utils/beego/validator"
 	"github.com/mobilemindtech/go-utils/json"
-	"github.com/mobilemindtech/go-utils/support"
 	"github.com/mobilemindtech/go-utils/v2/criteria"
 	"strings"
-	"time"
 )
 
 type AuthData struct {
@@ -32,8 +27,10 @@ type AuthUser struct {
 }
 
 type AuthResult struct {
-	Token     string `json:"token"`
-	ExpiresAt int64  `json:"expires_at"`
+	Token            string `json:"token"`
+	ExpiresAt        int64  `json:"expires_at"`
+	RefreshToken     string `json:"refresh_token,omitempty"`
+	RefreshExpiresAt int64  `json:"refresh_expires_at,omitempty"`
 }
 
 type AuthService struct {
@@ -121,33 +118,21 @@ func (this *AuthService) IsBearerToken(token string) bool {
 	return strings.HasPrefix(token, "Bearer ")
 }
 
+// CheckBearerToken verify access token, see ParseToken, and returns the user of token
 func (this *AuthService) CheckBearerToken(bearerToken string) (*models.User, error) {
 
 	if !this.IsBearerToken(bearerToken) {
 		return nil, nil
 	}
 
-	token := bearerToken[len("Bearer "):]
-
-	jwtToken, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-		secret, err := beego.AppConfig.String("jwt_token_secret")
-		return []byte(secret), err
-	})
+	claims, err := this.ParseToken(bearerToken, TokenTypeAccess)
 
 	if err != nil {
-		logs.Error("jwt error:", err)
+		logs.Error("jwt error: %v", err)
 		return nil, err
 	}
 
-	mapClaims := jwtToken.Claims.(jwt.MapClaims)
-	uuid := mapClaims["user"].(string)
-	expiresAt := support.AnyToInt64(mapClaims["expires_at"])
-
-	logs.Debug("expires_at = %v, now %v", expiresAt, util.DateNow().Unix())
-
-	if expiresAt < util.DateNow().Unix() {
-		return nil, errors.New("token expired")
-	}
+	uuid, _ := claims["user"].(string)
 
 	return criteria.New[*models.User](this.Session).
 		Eq("Uuid", uuid).
@@ -182,11 +167,8 @@ func (this *AuthService) Auth(body []byte, allowedRoles []string) *rio.IO[*AuthR
 
 	login := rio.AttemptThen(entityValidator, func(auth *AuthData) *result.Result[*AuthUser] {
 
-		encoded := support.TextToSha1(auth.Password)
-
 		user := criteria.New[*models.User](this.Session).
 			Eq("UserName", auth.UserName).
-			Eq("Password", encoded).
 			Eq("Enabled", true).
 			GetFirst()
 
@@ -197,6 +179,19 @@ func (this *AuthService) Auth(body []byte, allowedRoles []string) *rio.IO[*AuthR
 				if opt.IsNone() {
 					return result.OfError[*AuthUser](fmt.Errorf("user not found"))
 				}
+
+				ok, rehash := opt.Get().VerifyPassword(auth.Password)
+
+				if !ok {
+					return result.OfError[*AuthUser](fmt.Errorf("user not found"))
+				}
+
+				if rehash {
+					if err := models.NewUser(this.Session).RehashPassword(opt.Get(), auth.Password); err != nil {
+						logs.Error("error on rehash user password: %v", err)
+					}
+				}
+
 				return result.OfValue(&AuthUser{
 					auth, opt.Get(),
 				})
@@ -206,15 +201,8 @@ func (this *AuthService) Auth(body []byte, allowedRoles []string) *rio.IO[*AuthR
 	return rio.AttemptThen(
 		rio.AttemptThenOfIO(login, this.checkPermissions(allowedRoles)),
 		func(auth *AuthUser) *result.Result[*AuthResult] {
-			secret, _ := beego.AppConfig.String("jwt_token_secret")
-			expiresAt := util.DateNow().Add(time.Hour * 3).Unix()
-			token := support.NewBearerToken(jwt.MapClaims{
-				"user":       auth.User.Uuid,
-				"expires_at": expiresAt,
-			}, secret)
-
-			return result.Map(token, func(token string) *AuthResult {
-				return &AuthResult{token, expiresAt}
+			return result.Try(func() (*AuthResult, error) {
+				return this.issueTokens(auth.User, newTokenSession(auth.Data.TenantUUID, allowedRoles))
 			})
 		})
 }
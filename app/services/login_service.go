@@ -49,7 +49,7 @@ func (this *LoginService) Login(user *models.User, password string, byToken bool
 		logs.Error("### user not enabled ")
 		return user, LoginUserInactive(this.GetMessage("login.inactiveMsg"))
 
-	} else if !byToken && !user.IsSamePassword(password) {
+	} else if !byToken && !this.checkPassword(user, password) {
 		logs.Error("### password not match ")
 		// No matched password
 		return user, LoginWrongPassword(this.GetMessage("login.invalid"))
@@ -75,6 +75,20 @@ func (this *LoginService) Login(user *models.User, password string, byToken bool
 	}
 }
 
+// verify password and rehash legacy or outdated password
+func (this *LoginService) checkPassword(user *models.User, password string) bool {
+
+	ok, rehash := user.VerifyPassword(password)
+
+	if ok && rehash {
+		if err := this.ModelUser.RehashPassword(user, password); err != nil {
+			logs.Error("### error on rehash user password %v", err)
+		}
+	}
+
+	return ok
+}
+
 func (this *LoginService) GetMessage(key string, args ...interface{}) string {
 	return i18n.Tr(this.Lang, key, args)
 }
@@ -0,0 +1,571 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/v2/core/logs"
+	beego "github.com/beego/beego/v2/server/web"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mobilemindtech/go-utils/app/models"
+	"github.com/mobilemindtech/go-utils/beego/db"
+	"github.com/mobilemindtech/go-utils/beego/web/trait"
+	"github.com/mobilemindtech/go-utils/cache"
+	"github.com/mobilemindtech/go-utils/support"
+	"github.com/mobilemindtech/go-utils/v2/criteria"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+var (
+	ErrTokenRevoked      = errors.New("token revoked")
+	ErrTokenType         = errors.New("invalid token type")
+	ErrRefreshTokenReuse = errors.New("refresh token reused, session revoked")
+	ErrSessionExpired    = errors.New("session expired")
+)
+
+// TokenConfig jwt of AuthService. Empty values are loaded from app config jwt_token_secret, jwt_issuer and jwt_audience
+type TokenConfig struct {
+	Secret     string
+	Issuer     string
+	Audience   string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	// absolute lifetime of login, not extended by refresh. default is RefreshTTL
+	SessionTTL time.Duration
+	// clock skew tolerance of exp, nbf and iat
+	Leeway time.Duration
+}
+
+var (
+	tokenConfig         = new(TokenConfig)
+	tokenRevocation     TokenRevocationStore
+	tokenRevocationOnce sync.Once
+)
+
+// tenant of trait.JwtClaimTenantResolver is read from access tokens verified as AuthService.ParseToken
+func init() {
+	trait.SetTokenVerifier(func(token string) (jwt.MapClaims, error) {
+		return new(AuthService).ParseToken(token, TokenTypeAccess)
+	})
+}
+
+func SetTokenConfig(config *TokenConfig) {
+	tokenConfig = config
+}
+
+func getTokenConfig() *TokenConfig {
+
+	config := *tokenConfig
+
+	if len(config.Secret) == 0 {
+		config.Secret, _ = beego.AppConfig.String("jwt_token_secret")
+	}
+
+	if len(config.Issuer) == 0 {
+		config.Issuer, _ = beego.AppConfig.String("jwt_issuer")
+	}
+
+	if len(config.Audience) == 0 {
+		config.Audience, _ = beego.AppConfig.String("jwt_audience")
+	}
+
+	if config.AccessTTL <= 0 {
+		config.AccessTTL = time.Hour * 3
+	}
+
+	if config.RefreshTTL <= 0 {
+		config.RefreshTTL = time.Hour * 24 * 30
+	}
+
+	if config.SessionTTL <= 0 {
+		config.SessionTTL = config.RefreshTTL
+	}
+
+	return &config
+}
+
+// TokenRevocationStore revoked token ids (jti and sid), kept until token expiration
+type TokenRevocationStore interface {
+	// Revoke id until expiresAt. Returns false when already revoked
+	Revoke(id string, expiresAt time.Time) (bool, error)
+	IsRevoked(id string) (bool, error)
+}
+
+// SetTokenRevocationStore store of revoked tokens. default is app config jwt_revocation_store: redis, as
+// CacheRevocationStore of sessionproviderconfig, or memory (not shared between instances).
+// eg.: services.SetTokenRevocationStore(services.NewCacheRevocationStore(cache.New()))
+func SetTokenRevocationStore(store TokenRevocationStore) {
+	tokenRevocation = store
+}
+
+func getTokenRevocation() TokenRevocationStore {
+	tokenRevocationOnce.Do(func() {
+		if tokenRevocation != nil {
+			return
+		}
+		kind, _ := beego.AppConfig.String("jwt_revocation_store")
+		tokenRevocation = newTokenRevocationStore(kind)
+	})
+	return tokenRevocation
+}
+
+// store of app config jwt_revocation_store
+func newTokenRevocationStore(kind string) TokenRevocationStore {
+	switch kind {
+	case "redis":
+		return NewCacheRevocationStore(cache.New())
+	case "memory":
+		return NewMemoryRevocationStore()
+	case "":
+		logs.Warning("token revocation: jwt_revocation_store not configured, using memory store")
+	default:
+		logs.Error("token revocation: invalid jwt_revocation_store %v, using memory store", kind)
+	}
+	return NewMemoryRevocationStore()
+}
+
+type MemoryRevocationStore struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time
+}
+
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{revoked: map[string]time.Time{}}
+}
+
+func (this *MemoryRevocationStore) Revoke(id string, expiresAt time.Time) (bool, error) {
+
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	now := time.Now()
+
+	for key, exp := range this.revoked {
+		if exp.Before(now) {
+			delete(this.revoked, key)
+		}
+	}
+
+	if exp, ok := this.revoked[id]; ok && exp.After(now) {
+		return false, nil
+	}
+
+	this.revoked[id] = expiresAt
+
+	return true, nil
+}
+
+func (this *MemoryRevocationStore) IsRevoked(id string) (bool, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	exp, ok := this.revoked[id]
+	return ok && exp.After(time.Now()), nil
+}
+
+// CacheRevocationStore revoked tokens on redis by cache.CacheService, shared between instances.
+// Revocations are kept in memory too. Redis errors are returned, so tokens are rejected while redis is not available
+type CacheRevocationStore struct {
+	Cache  *cache.CacheService
+	memory *MemoryRevocationStore
+}
+
+func NewCacheRevocationStore(cacheService *cache.CacheService) *CacheRevocationStore {
+	return &CacheRevocationStore{Cache: cacheService, memory: NewMemoryRevocationStore()}
+}
+
+func (this *CacheRevocationStore) key(id string) string {
+	return fmt.Sprintf("jwt_revoked_%v", id)
+}
+
+func (this *CacheRevocationStore) Revoke(id string, expiresAt time.Time) (bool, error) {
+
+	revoked, _ := this.memory.Revoke(id, expiresAt)
+
+	if this.Cache == nil {
+		return revoked, nil
+	}
+
+	ttl := time.Until(expiresAt)
+
+	if ttl <= 0 {
+		return revoked, nil
+	}
+
+	ok, err := this.Cache.SetNX(this.key(id), expiresAt.Unix(), ttl)
+
+	if err != nil {
+		return revoked, fmt.Errorf("token revocation: %v", err)
+	}
+
+	return ok, nil
+}
+
+func (this *CacheRevocationStore) IsRevoked(id string) (bool, error) {
+
+	if revoked, _ := this.memory.IsRevoked(id); revoked || this.Cache == nil {
+		return revoked, nil
+	}
+
+	revoked, err := this.Cache.Exists(this.key(id))
+
+	if err != nil {
+		return false, fmt.Errorf("token revocation: %v", err)
+	}
+
+	return revoked, nil
+}
+
+// claims of login, shared by all tokens of login. sid is revoked on logout and refresh token reuse
+type tokenSession struct {
+	sid    string
+	tenant string
+	// roles required on login, checked again on refresh
+	roles    []string
+	authTime int64
+}
+
+func newTokenSession(tenant string, roles []string) *tokenSession {
+	return &tokenSession{sid: uuid.NewV4().String(), tenant: tenant, roles: roles, authTime: time.Now().Unix()}
+}
+
+// IssueTokens new access and refresh tokens of user. tenant is the tenant uuid claim, optional
+func (this *AuthService) IssueTokens(user *models.User, tenant string) (*AuthResult, error) {
+	return this.issueTokens(user, newTokenSession(tenant, nil))
+}
+
+func (this *AuthService) issueTokens(user *models.User, session *tokenSession) (*AuthResult, error) {
+
+	config := getTokenConfig()
+
+	token, expiresAt, err := this.newToken(config, user, session, TokenTypeAccess, config.AccessTTL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, refreshExpiresAt, err := this.newToken(config, user, session, TokenTypeRefresh, config.RefreshTTL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthResult{
+		Token:            token,
+		ExpiresAt:        expiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: refreshExpiresAt,
+	}, nil
+}
+
+func (this *AuthService) newToken(config *TokenConfig, user *models.User, session *tokenSession, typ string, ttl time.Duration) (string, int64, error) {
+
+	now := time.Now()
+	expiresAt := now.Add(ttl).Unix()
+
+	// tokens do not outlive the login
+	if sessionExpiresAt := time.Unix(session.authTime, 0).Add(config.SessionTTL).Unix(); sessionExpiresAt < expiresAt {
+		expiresAt = sessionExpiresAt
+	}
+
+	claims := jwt.MapClaims{
+		"jti":       uuid.NewV4().String(),
+		"sid":       session.sid,
+		"sub":       user.Uuid,
+		"user":      user.Uuid,
+		"typ":       typ,
+		"iat":       now.Unix(),
+		"nbf":       now.Unix(),
+		"exp":       expiresAt,
+		"auth_time": session.authTime,
+		// legacy claim
+		"expires_at": expiresAt,
+	}
+
+	if len(config.Issuer) > 0 {
+		claims["iss"] = config.Issuer
+	}
+
+	if len(config.Audience) > 0 {
+		claims["aud"] = config.Audience
+	}
+
+	if len(session.tenant) > 0 {
+		claims["tenant"] = session.tenant
+	}
+
+	if len(session.roles) > 0 {
+		claims["roles"] = session.roles
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.Secret))
+
+	return token, expiresAt, err
+}
+
+// ParseToken verify signature, standard claims, type and revocation. Tokens without typ are access tokens
+func (this *AuthService) ParseToken(token string, typ string) (jwt.MapClaims, error) {
+
+	claims, err := this.parseToken(token)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tokenType, _ := claims["typ"].(string)
+
+	if len(tokenType) == 0 {
+		tokenType = TokenTypeAccess
+	}
+
+	if tokenType != typ {
+		return nil, ErrTokenType
+	}
+
+	for _, claim := range []string{"jti", "sid"} {
+
+		id, _ := claims[claim].(string)
+
+		if len(id) == 0 {
+			continue
+		}
+
+		revoked, err := getTokenRevocation().IsRevoked(id)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if revoked {
+			return claims, ErrTokenRevoked
+		}
+	}
+
+	return claims, nil
+}
+
+// verify signature and standard claims, without revocation
+func (this *AuthService) parseToken(token string) (jwt.MapClaims, error) {
+
+	config := getTokenConfig()
+
+	token = strings.TrimPrefix(token, "Bearer ")
+
+	opts := []jwt.ParserOption{jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithIssuedAt()}
+
+	if len(config.Issuer) > 0 {
+		opts = append(opts, jwt.WithIssuer(config.Issuer))
+	}
+
+	if len(config.Audience) > 0 {
+		opts = append(opts, jwt.WithAudience(config.Audience))
+	}
+
+	if config.Leeway > 0 {
+		opts = append(opts, jwt.WithLeeway(config.Leeway))
+	}
+
+	jwtToken, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+		return []byte(config.Secret), nil
+	}, opts...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwtToken.Claims.(jwt.MapClaims)
+
+	// legacy tokens without exp
+	if _, ok := claims["exp"]; !ok {
+		if support.AnyToInt64(claims["expires_at"]) < time.Now().Unix() {
+			return nil, errors.New("token expired")
+		}
+	}
+
+	return claims, nil
+}
+
+// RefreshToken exchange refresh token by new access and refresh tokens. The refresh token is revoked (rotation),
+// a revoked refresh token reused revokes all tokens of login. Tokens are not issued after SessionTTL of login, and
+// login is revoked when user loses the roles of login or the tenant
+func (this *AuthService) RefreshToken(refreshToken string) (*AuthResult, error) {
+
+	claims, err := this.ParseToken(refreshToken, TokenTypeRefresh)
+
+	if errors.Is(err, ErrTokenRevoked) {
+		sid, _ := claims["sid"].(string)
+		logs.Warning("revoked refresh token reused, revoking session %v", sid)
+		this.revokeSession(sid)
+		return nil, ErrRefreshTokenReuse
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	jti, _ := claims["jti"].(string)
+	sid, _ := claims["sid"].(string)
+	expiresAt := time.Unix(support.AnyToInt64(claims["exp"]), 0)
+
+	if len(jti) == 0 || len(sid) == 0 {
+		return nil, errors.New("invalid refresh token")
+	}
+
+	// tokens without auth_time are of login before session ttl
+	authTime := support.AnyToInt64(claims["auth_time"])
+
+	if authTime == 0 {
+		authTime = support.AnyToInt64(claims["iat"])
+	}
+
+	if time.Unix(authTime, 0).Add(getTokenConfig().SessionTTL).Before(time.Now()) {
+		return nil, ErrSessionExpired
+	}
+
+	ok, err := getTokenRevocation().Revoke(jti, expiresAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		logs.Warning("refresh token %v reused, revoking session %v", jti, sid)
+		this.revokeSession(sid)
+		return nil, ErrRefreshTokenReuse
+	}
+
+	userUuid, _ := claims["user"].(string)
+
+	user, err := criteria.New[*models.User](this.Session).
+		Eq("Uuid", userUuid).
+		Eq("Enabled", true).
+		First()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil || user.Id == 0 {
+		this.revokeSession(sid)
+		return nil, errors.New("user not found")
+	}
+
+	session := &tokenSession{sid: sid, authTime: authTime}
+	session.tenant, _ = claims["tenant"].(string)
+
+	if roles, ok := claims["roles"].([]interface{}); ok {
+		for _, role := range roles {
+			session.roles = append(session.roles, fmt.Sprintf("%v", role))
+		}
+	}
+
+	if err := this.checkTokenSession(user, session); err != nil {
+		logs.Warning("refresh token of user %v not authorized, revoking session %v: %v", user.Id, sid, err)
+		this.revokeSession(sid)
+		return nil, err
+	}
+
+	return this.issueTokens(user, session)
+}
+
+// user still has one of roles of login and enabled membership of enabled tenant, or is root
+func (this *AuthService) checkTokenSession(user *models.User, session *tokenSession) error {
+
+	if len(session.roles) > 0 {
+
+		ok, err := this.hasAnyRole(user, session.roles)
+
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return errors.New("user not authorized")
+		}
+	}
+
+	if len(session.tenant) == 0 {
+		return nil
+	}
+
+	if root, err := this.hasAnyRole(user, []string{"ROLE_ROOT"}); err != nil || root {
+		return err
+	}
+
+	ok, err := criteria.New[*models.TenantUser](this.Session).
+		Eq("User", user).
+		Eq("Enabled", true).
+		Eq("Tenant__Uuid", session.tenant).
+		Eq("Tenant__Enabled", true).
+		Exists()
+
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return errors.New("user not authorized for tenant")
+	}
+
+	return nil
+}
+
+func (this *AuthService) hasAnyRole(user *models.User, roles []string) (bool, error) {
+
+	cond := db.NewCondition()
+	for _, role := range roles {
+		cond.Eq("Role__Authority", role)
+	}
+
+	return criteria.New[*models.UserRole](this.Session).
+		Eq("User", user).
+		AndOr(cond).
+		Exists()
+}
+
+// RevokeToken revoke access or refresh token and all tokens of its login, as logout. Expired tokens are ignored
+func (this *AuthService) RevokeToken(token string) error {
+
+	claims, err := this.parseToken(token)
+
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	expiresAt := time.Unix(support.AnyToInt64(claims["exp"]), 0)
+
+	// both are revoked, at least in memory, when the store fails
+	errs := []error{}
+
+	if jti, _ := claims["jti"].(string); len(jti) > 0 {
+		if _, err := getTokenRevocation().Revoke(jti, expiresAt); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if sid, _ := claims["sid"].(string); len(sid) > 0 {
+		errs = append(errs, this.revokeSession(sid))
+	}
+
+	return errors.Join(errs...)
+}
+
+// refresh tokens of session expire until RefreshTTL
+func (this *AuthService) revokeSession(sid string) error {
+	if len(sid) == 0 {
+		return nil
+	}
+	_, err := getTokenRevocation().Revoke(sid, time.Now().Add(getTokenConfig().RefreshTTL))
+	return err
+}
@@ -0,0 +1,296 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/beego/beego/v2/server/web/context"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/mobilemindtech/go-utils/app/models"
+	"github.com/mobilemindtech/go-utils/beego/db"
+	"github.com/mobilemindtech/go-utils/beego/dbtest"
+	"github.com/mobilemindtech/go-utils/beego/web/trait"
+	"github.com/mobilemindtech/go-utils/cache"
+	uuid "github.com/satori/go.uuid"
+)
+
+func TestMain(m *testing.M) {
+
+	SetTokenConfig(&TokenConfig{Secret: "secret"})
+	SetTokenRevocationStore(NewMemoryRevocationStore())
+
+	err := dbtest.Setup(new(models.Estado), new(models.Cidade), new(models.Tenant), new(models.User),
+		new(models.Role), new(models.UserRole), new(models.TenantUser))
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	os.Exit(m.Run())
+}
+
+type tokenFixture struct {
+	session    *db.Session
+	user       *models.User
+	tenant     *models.Tenant
+	tenantUser *models.TenantUser
+	userRole   *models.UserRole
+}
+
+func newTokenFixture(t *testing.T) *tokenFixture {
+
+	t.Helper()
+
+	session := dbtest.NewSession(t)
+	estado := &models.Estado{Nome: "Rio Grande do Sul", Uf: "RS"}
+	cidade := &models.Cidade{Nome: "Porto Alegre", Estado: estado}
+	tenant := &models.Tenant{Name: "acme", Documento: "12345678901", Enabled: true, Uuid: uuid.NewV4().String(), Cidade: cidade}
+	user := &models.User{Name: "ana", UserName: uuid.NewV4().String() + "@example.com", Enabled: true, Uuid: uuid.NewV4().String(), Tenant: tenant}
+	role := &models.Role{Authority: "ROLE_ADMIN"}
+	userRole := &models.UserRole{User: user, Role: role}
+	tenantUser := &models.TenantUser{Tenant: tenant, User: user, Enabled: true, Admin: true}
+
+	for _, it := range []interface{}{estado, cidade, tenant, user, role, userRole, tenantUser} {
+		if err := session.Save(it); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return &tokenFixture{session: session, user: user, tenant: tenant, tenantUser: tenantUser, userRole: userRole}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/app/services -run TestRefreshToken
+func TestRefreshToken(t *testing.T) {
+
+	fixture := newTokenFixture(t)
+	auth := NewAuthService(fixture.session)
+
+	login, err := auth.issueTokens(fixture.user, newTokenSession(fixture.tenant.Uuid, []string{"ROLE_ADMIN"}))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refreshed, err := auth.RefreshToken(login.RefreshToken)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before, _ := auth.ParseToken(login.RefreshToken, TokenTypeRefresh)
+	after, err := auth.ParseToken(refreshed.RefreshToken, TokenTypeRefresh)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, claim := range []string{"sid", "auth_time", "tenant"} {
+		if before == nil || before[claim] != after[claim] {
+			t.Errorf("expected same %v of login, got %v", claim, after[claim])
+		}
+	}
+
+	if _, err := auth.RefreshToken(login.RefreshToken); !errors.Is(err, ErrRefreshTokenReuse) {
+		t.Errorf("expected refresh token reuse, got %v", err)
+	}
+
+	if _, err := auth.ParseToken(refreshed.Token, TokenTypeAccess); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("expected tokens of login revoked, got %v", err)
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/app/services -run TestRefreshTokenSessionExpired
+func TestRefreshTokenSessionExpired(t *testing.T) {
+
+	defer SetTokenConfig(tokenConfig)
+
+	SetTokenConfig(&TokenConfig{Secret: "secret", SessionTTL: time.Hour})
+
+	fixture := newTokenFixture(t)
+	auth := NewAuthService(fixture.session)
+
+	session := newTokenSession("", nil)
+	session.authTime = time.Now().Add(-time.Minute * 30).Unix()
+
+	login, err := auth.issueTokens(fixture.user, session)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if login.RefreshExpiresAt > session.authTime+int64(time.Hour.Seconds()) {
+		t.Errorf("expected refresh token expiration limited by session")
+	}
+
+	SetTokenConfig(&TokenConfig{Secret: "secret", SessionTTL: time.Minute * 10})
+
+	if _, err := auth.RefreshToken(login.RefreshToken); !errors.Is(err, ErrSessionExpired) {
+		t.Errorf("expected session expired, got %v", err)
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/app/services -run TestRefreshTokenAuthorization
+func TestRefreshTokenAuthorization(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		change func(t *testing.T, fixture *tokenFixture)
+		valid  bool
+	}{
+		{"authorized", func(t *testing.T, fixture *tokenFixture) {}, true},
+		{"tenant user disabled", func(t *testing.T, fixture *tokenFixture) {
+			fixture.tenantUser.Enabled = false
+			if err := fixture.session.Update(fixture.tenantUser); err != nil {
+				t.Fatal(err)
+			}
+		}, false},
+		{"tenant disabled", func(t *testing.T, fixture *tokenFixture) {
+			fixture.tenant.Enabled = false
+			if err := fixture.session.Update(fixture.tenant); err != nil {
+				t.Fatal(err)
+			}
+		}, false},
+		{"role removed", func(t *testing.T, fixture *tokenFixture) {
+			if err := fixture.session.Remove(fixture.userRole); err != nil {
+				t.Fatal(err)
+			}
+		}, false},
+		{"root without tenant", func(t *testing.T, fixture *tokenFixture) {
+			root := &models.Role{Authority: "ROLE_ROOT"}
+			if err := fixture.session.Save(root); err != nil {
+				t.Fatal(err)
+			}
+			if err := fixture.session.Save(&models.UserRole{User: fixture.user, Role: root}); err != nil {
+				t.Fatal(err)
+			}
+			if err := fixture.session.Remove(fixture.tenantUser); err != nil {
+				t.Fatal(err)
+			}
+		}, true},
+	}
+
+	for _, it := range tests {
+		t.Run(it.name, func(t *testing.T) {
+
+			fixture := newTokenFixture(t)
+			auth := NewAuthService(fixture.session)
+
+			login, err := auth.issueTokens(fixture.user, newTokenSession(fixture.tenant.Uuid, []string{"ROLE_ADMIN"}))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			it.change(t, fixture)
+
+			_, err = auth.RefreshToken(login.RefreshToken)
+
+			if it.valid && err != nil {
+				t.Errorf("expected refresh authorized, got %v", err)
+			}
+
+			if !it.valid {
+				if err == nil {
+					t.Errorf("expected refresh not authorized")
+				}
+				if _, err := auth.ParseToken(login.Token, TokenTypeAccess); !errors.Is(err, ErrTokenRevoked) {
+					t.Errorf("expected tokens of login revoked, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/app/services -run TestJwtClaimTenantResolver
+func TestJwtClaimTenantResolver(t *testing.T) {
+
+	fixture := newTokenFixture(t)
+	auth := NewAuthService(fixture.session)
+
+	login, err := auth.issueTokens(fixture.user, newTokenSession(fixture.tenant.Uuid, []string{"ROLE_ADMIN"}))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolve := func(token string) string {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/api", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		ctx := context.NewContext()
+		ctx.Reset(httptest.NewRecorder(), req)
+		return trait.NewJwtClaimTenantResolver("").ResolveTenant(ctx)
+	}
+
+	if key := resolve(login.Token); key != fixture.tenant.Uuid {
+		t.Errorf("expected tenant of access token, got %q", key)
+	}
+
+	if key := resolve(login.RefreshToken); len(key) > 0 {
+		t.Errorf("expected refresh token rejected, got %q", key)
+	}
+
+	if err := auth.RevokeToken(login.Token); err != nil {
+		t.Fatal(err)
+	}
+
+	if key := resolve(login.Token); len(key) > 0 {
+		t.Errorf("expected revoked token rejected, got %q", key)
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/app/services -run TestCacheRevocationStoreError
+func TestCacheRevocationStoreError(t *testing.T) {
+
+	fixture := newTokenFixture(t)
+	auth := NewAuthService(fixture.session)
+
+	login, err := auth.issueTokens(fixture.user, newTokenSession(fixture.tenant.Uuid, []string{"ROLE_ADMIN"}))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// redis not available
+	store := NewCacheRevocationStore(cache.New())
+
+	if _, err := store.IsRevoked("jti"); err == nil {
+		t.Skip("redis available")
+	}
+
+	SetTokenRevocationStore(store)
+	defer SetTokenRevocationStore(NewMemoryRevocationStore())
+
+	if _, err := auth.ParseToken(login.Token, TokenTypeAccess); err == nil {
+		t.Errorf("expected token rejected on revocation store error")
+	}
+
+	if err := auth.RevokeToken(login.Token); err == nil {
+		t.Errorf("expected revoke error of store")
+	}
+
+	claims, _ := auth.parseToken(login.Token)
+
+	// kept in memory of instance
+	if revoked, _ := store.memory.IsRevoked(claims["jti"].(string)); !revoked {
+		t.Errorf("expected token revoked in memory")
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/app/services -run TestTokenRevocationStoreConfig
+func TestTokenRevocationStoreConfig(t *testing.T) {
+
+	if _, ok := newTokenRevocationStore("redis").(*CacheRevocationStore); !ok {
+		t.Errorf("expected redis store")
+	}
+
+	for _, kind := range []string{"memory", "", "other"} {
+		if _, ok := newTokenRevocationStore(kind).(*MemoryRevocationStore); !ok {
+			t.Errorf("expected memory store of %q", kind)
+		}
+	}
+}
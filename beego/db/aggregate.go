@@ -0,0 +1,101 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+type AggregateFunc string
+
+const (
+	AggregateSum           AggregateFunc = "SUM"
+	AggregateAvg           AggregateFunc = "AVG"
+	AggregateMin           AggregateFunc = "MIN"
+	AggregateMax           AggregateFunc = "MAX"
+	AggregateCount         AggregateFunc = "COUNT"
+	AggregateCountDistinct AggregateFunc = "COUNT_DISTINCT"
+)
+
+// aggregate sql expression of path. path can be empty to AggregateCount
+func (this *Criteria) aggregateExpr(fn AggregateFunc, path string) (string, error) {
+
+	if fn == AggregateCount && len(path) == 0 {
+		return "COUNT(*)", nil
+	}
+
+	column, err := this.SQLColumn(path)
+
+	if err != nil {
+		return "", err
+	}
+
+	switch fn {
+	case AggregateSum, AggregateAvg, AggregateMin, AggregateMax, AggregateCount:
+		return fmt.Sprintf("%v(%v)", fn, column), nil
+	case AggregateCountDistinct:
+		return fmt.Sprintf("COUNT(DISTINCT %v)", column), nil
+	}
+
+	return "", fmt.Errorf("aggregate function %v not supported", fn)
+}
+
+// AggregateValue execute aggregate function of path, with criteria conditions and tenant filter.
+// result should be a pointer, null result is set as zero value
+func (this *Criteria) AggregateValue(fn AggregateFunc, path string, result interface{}) *Criteria {
+
+	expr, err := this.aggregateExpr(fn, path)
+
+	if err != nil {
+		this.SetError(err)
+		return this
+	}
+
+	query, args, err := this.SelectSQL(expr)
+
+	if err != nil {
+		this.SetError(err)
+		return this
+	}
+
+	defer this.observeQuery(CriteriaAggregateOne, time.Now(), &querySQL{sql: query, args: args})
+	defer this.Session.observeRead(time.Now())
+	err = this.Session.GetReadDb().Raw(query, args...).QueryRow(result)
+	this.SetError(err)
+
+	return this
+}
+
+// AggregateGroup execute aggregate function of path grouped by keyPath, ordered by key.
+// keys and values should be pointers of slices
+func (this *Criteria) AggregateGroup(keyPath string, fn AggregateFunc, path string, keys interface{}, values interface{}) *Criteria {
+
+	expr, err := this.aggregateExpr(fn, path)
+
+	if err != nil {
+		this.SetError(err)
+		return this
+	}
+
+	key, err := this.SQLColumn(keyPath)
+
+	if err != nil {
+		this.SetError(err)
+		return this
+	}
+
+	query, args, err := this.SelectSQL(fmt.Sprintf("%v, %v", key, expr))
+
+	if err != nil {
+		this.SetError(err)
+		return this
+	}
+
+	query = fmt.Sprintf("%v GROUP BY %v ORDER BY %v", query, key, key)
+
+	defer this.observeQuery(CriteriaAggregateList, time.Now(), &querySQL{sql: query, args: args})
+	defer this.Session.observeRead(time.Now())
+	_, err = this.Session.GetReadDb().Raw(query, args...).QueryRows(keys, values)
+	this.SetError(err)
+
+	return this
+}
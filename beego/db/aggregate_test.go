@@ -0,0 +1,110 @@
+package db_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mobilemindtech/go-utils/beego/db"
+)
+
+func aggregateFixtures(t *testing.T) *db.Session {
+
+	t.Helper()
+
+	session, _ := newTenantSession(t, "aggregate")
+
+	books, music := &Category{Name: "books"}, &Category{Name: "music"}
+
+	for _, it := range []*Category{books, music} {
+		if err := session.Save(it); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	saveProducts(t, session,
+		&Product{Code: "p1", Name: "100% cotton", Price: 10, Category: books},
+		&Product{Code: "p2", Name: "1000 cotton", Price: 20, Category: books},
+		&Product{Code: "p3", Name: "a_b", Price: 5, Category: music},
+		&Product{Code: "p4", Name: "axb", Price: 7},
+		&Product{Code: "p5", Name: `c:\dir`, Price: 1})
+
+	return session
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestAggregateJoinedPath
+func TestAggregateJoinedPath(t *testing.T) {
+
+	session := aggregateFixtures(t)
+
+	var total float64
+
+	c := db.NewCriteria(session, new(Product), nil).
+		Eq("Category__Name", "books").
+		AggregateValue(db.AggregateSum, "Price", &total)
+
+	if c.HasError {
+		t.Fatal(c.Error)
+	}
+
+	if total != 30 {
+		t.Errorf("expected total 30 of books, got %v", total)
+	}
+
+	var names []string
+	var counts []int64
+
+	c = db.NewCriteria(session, new(Product), nil).
+		IsNotNull("Category").
+		AggregateGroup("Category__Name", db.AggregateCount, "", &names, &counts)
+
+	if c.HasError {
+		t.Fatal(c.Error)
+	}
+
+	if fmt.Sprint(names, counts) != "[books music] [2 1]" {
+		t.Errorf("expected books 2 and music 1, got %v %v", names, counts)
+	}
+
+	c = db.NewCriteria(session, new(Product), nil).
+		Eq("Code__Name", "books").
+		AggregateValue(db.AggregateSum, "Price", &total)
+
+	if !c.HasError {
+		t.Errorf("expected error of path of field that is not a relation")
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestAggregateLikeEscape
+func TestAggregateLikeEscape(t *testing.T) {
+
+	session := aggregateFixtures(t)
+
+	tests := []struct {
+		name     string
+		criteria func(c *db.Criteria) *db.Criteria
+		expected int64
+	}{
+		{"percent", func(c *db.Criteria) *db.Criteria { return c.Like("Name", "100%") }, 1},
+		{"underscore", func(c *db.Criteria) *db.Criteria { return c.Like("Name", "a_b") }, 1},
+		{"backslash", func(c *db.Criteria) *db.Criteria { return c.Like("Name", `c:\d`) }, 1},
+		{"ignore case", func(c *db.Criteria) *db.Criteria { return c.Like("Name", "A_B") }, 1},
+		{"joined", func(c *db.Criteria) *db.Criteria { return c.Like("Category__Name", "oo") }, 2},
+	}
+
+	for _, it := range tests {
+		t.Run(it.name, func(t *testing.T) {
+
+			var count int64
+
+			c := it.criteria(db.NewCriteria(session, new(Product), nil)).AggregateValue(db.AggregateCount, "", &count)
+
+			if c.HasError {
+				t.Fatal(c.Error)
+			}
+
+			if count != it.expected {
+				t.Errorf("expected %v, got %v", it.expected, count)
+			}
+		})
+	}
+}
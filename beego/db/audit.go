@@ -0,0 +1,246 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/beego/beego/v2/core/logs"
+)
+
+type AuditAction string
+
+const (
+	AuditActionCreate AuditAction = "create"
+	AuditActionUpdate AuditAction = "update"
+	AuditActionRemove AuditAction = "remove"
+)
+
+type AuditEntry struct {
+	EntityType string
+	EntityId   int64
+	TenantId   int64
+	UserId     int64
+	Action     AuditAction
+	// json of changed fields. eg.: {"Name": {"old": "foo", "new": "bar"}}
+	Changes   string
+	CreatedAt time.Time
+}
+
+type AuditChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// AuditStore write and read entity audit. The write should use session database, so audit is saved on same transaction.
+// History returns entries of session tenant, oldest first.
+type AuditStore interface {
+	Write(session *Session, entry *AuditEntry) error
+	History(session *Session, entityType string, entityId int64) ([]*AuditEntry, error)
+}
+
+var auditStore AuditStore
+
+// SetAuditStore enable the entity audit of models with tag `goutils:"audit"`
+func SetAuditStore(store AuditStore) {
+	auditStore = store
+}
+
+func GetAuditStore() AuditStore {
+	return auditStore
+}
+
+func (this *Session) SetAuditUserId(id int64) *Session {
+	this.AuditUserId = id
+	return this
+}
+
+// IsAudited check if entity has any field with tag `goutils:"audit"`
+func (this *Session) IsAudited(entity interface{}) bool {
+
+	if auditStore == nil || this.IsNil(entity) {
+		return false
+	}
+
+	fullType := reflect.ValueOf(entity).Elem().Type()
+
+	for i := 0; i < fullType.NumField(); i++ {
+		if this.hasTag(this.getTags(fullType.Field(i)), "audit") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AuditHistory list audit entries of entity
+func (this *Session) AuditHistory(entity interface{}) ([]*AuditEntry, error) {
+	if auditStore == nil {
+		return nil, errors.New("audit store not configured")
+	}
+	return auditStore.History(this, getTypeName(entity), this.getEntityId(entity))
+}
+
+// load current database state of entity, before update
+func (this *Session) loadAuditState(entity interface{}) (interface{}, error) {
+
+	if !this.IsAudited(entity) {
+		return nil, nil
+	}
+
+	old := reflect.New(reflect.ValueOf(entity).Elem().Type())
+	old.Elem().FieldByName("Id").Set(reflect.ValueOf(entity).Elem().FieldByName("Id"))
+
+	if err := this.GetDb().Read(old.Interface()); err != nil {
+		return nil, fmt.Errorf("audit: error on load entity state: %v", err)
+	}
+
+	return old.Interface(), nil
+}
+
+func (this *Session) audit(action AuditAction, entity interface{}, old interface{}) error {
+
+	if !this.IsAudited(entity) {
+		return nil
+	}
+
+	var changes map[string]*AuditChange
+
+	switch action {
+	case AuditActionCreate:
+		changes = this.auditDiff(nil, entity)
+	case AuditActionUpdate:
+		changes = this.auditDiff(old, entity)
+		if len(changes) == 0 {
+			return nil
+		}
+	case AuditActionRemove:
+		changes = this.auditDiff(entity, nil)
+	}
+
+	payload, err := json.Marshal(changes)
+
+	if err != nil {
+		return fmt.Errorf("audit: error on encode changes: %v", err)
+	}
+
+	entry := &AuditEntry{
+		EntityType: getTypeName(entity),
+		EntityId:   this.getEntityId(entity),
+		TenantId:   this.getAuditTenantId(entity),
+		UserId:     this.AuditUserId,
+		Action:     action,
+		Changes:    string(payload),
+		CreatedAt:  time.Now(),
+	}
+
+	if this.Debug {
+		logs.Debug("## audit %v %v %v: %v", entry.Action, entry.EntityType, entry.EntityId, entry.Changes)
+	}
+
+	if err := auditStore.Write(this, entry); err != nil {
+		this.SetError()
+		return fmt.Errorf("audit: error on write: %v", err)
+	}
+
+	return nil
+}
+
+// compute changed fields. ignore fields with orm:"-", auto_now, auto_now_add and goutils:"no_audit"
+func (this *Session) auditDiff(old interface{}, current interface{}) map[string]*AuditChange {
+
+	changes := map[string]*AuditChange{}
+
+	ref := current
+	if ref == nil {
+		ref = old
+	}
+
+	fullType := reflect.ValueOf(ref).Elem().Type()
+
+	for i := 0; i < fullType.NumField(); i++ {
+		field := fullType.Field(i)
+
+		if !field.IsExported() || this.hasTag(this.getTags(field), "no_audit") {
+			continue
+		}
+
+		ormTag := field.Tag.Get("orm")
+
+		if ormTag == "-" || strings.Contains(ormTag, "auto_now") {
+			continue
+		}
+
+		var oldValue, newValue interface{}
+
+		if old != nil {
+			oldValue = auditValue(reflect.ValueOf(old).Elem().Field(i))
+		}
+
+		if current != nil {
+			newValue = auditValue(reflect.ValueOf(current).Elem().Field(i))
+		}
+
+		if reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+
+		changes[field.Name] = &AuditChange{Old: oldValue, New: newValue}
+	}
+
+	return changes
+}
+
+func (this *Session) getAuditTenantId(entity interface{}) int64 {
+
+	field := reflect.ValueOf(entity).Elem().FieldByName("Tenant")
+
+	if field.IsValid() && !(field.Kind() == reflect.Ptr && field.IsNil()) {
+		if tenant, ok := field.Interface().(TenantModel); ok {
+			return tenant.GetId()
+		}
+	}
+
+	if tenant, ok := this.Tenant.(TenantModel); ok && !this.isTenantNil() {
+		return tenant.GetId()
+	}
+
+	return 0
+}
+
+// value to audit. relations are audited by id
+func auditValue(value reflect.Value) interface{} {
+
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		if model, ok := value.Interface().(Model); ok {
+			id := value.Elem().FieldByName("Id")
+			if id.IsValid() {
+				return id.Interface()
+			}
+			return model.IsPersisted()
+		}
+		return auditValue(value.Elem())
+	}
+
+	switch value.Interface().(type) {
+	case time.Time:
+		t := value.Interface().(time.Time)
+		if t.IsZero() {
+			return nil
+		}
+		return t.Format(time.RFC3339)
+	}
+
+	switch value.Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Map, reflect.Interface, reflect.Func, reflect.Chan:
+		return nil
+	}
+
+	return value.Interface()
+}
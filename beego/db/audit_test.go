@@ -0,0 +1,82 @@
+package db_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mobilemindtech/go-utils/beego/db"
+)
+
+type memoryAuditStore struct {
+	entries []*db.AuditEntry
+}
+
+func (this *memoryAuditStore) Write(session *db.Session, entry *db.AuditEntry) error {
+	this.entries = append(this.entries, entry)
+	return nil
+}
+
+func (this *memoryAuditStore) History(session *db.Session, entityType string, entityId int64) ([]*db.AuditEntry, error) {
+	entries := []*db.AuditEntry{}
+	for _, it := range this.entries {
+		if it.EntityType == entityType && it.EntityId == entityId {
+			entries = append(entries, it)
+		}
+	}
+	return entries, nil
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestAudit
+func TestAudit(t *testing.T) {
+
+	store := new(memoryAuditStore)
+	db.SetAuditStore(store)
+	t.Cleanup(func() { db.SetAuditStore(nil) })
+
+	session, company := newTenantSession(t, "audit")
+	session.SetAuditUserId(7)
+
+	product := &Product{Code: "p1", Name: "old"}
+
+	saveProducts(t, session, product)
+
+	product.Name = "new"
+
+	if err := session.Update(product); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := session.Remove(product); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := session.AuditHistory(product)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actions := []db.AuditAction{db.AuditActionCreate, db.AuditActionUpdate, db.AuditActionRemove}
+
+	if len(entries) != len(actions) {
+		t.Fatalf("expected %v entries, got %v", len(actions), len(entries))
+	}
+
+	for i, it := range entries {
+		if it.Action != actions[i] || it.UserId != 7 || it.TenantId != company.Id || it.EntityId != product.Id {
+			t.Errorf("unexpected entry %+v", it)
+		}
+	}
+
+	changes := map[string]*db.AuditChange{}
+
+	if err := json.Unmarshal([]byte(entries[1].Changes), &changes); err != nil {
+		t.Fatal(err)
+	}
+
+	name, ok := changes["Name"]
+
+	if !ok || name.Old != "old" || name.New != "new" {
+		t.Errorf("expected Name change, got %v", entries[1].Changes)
+	}
+}
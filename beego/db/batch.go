@@ -0,0 +1,226 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/beego/beego/v2/core/logs"
+)
+
+const DefaultBatchChunkSize = 500
+
+// ErrBatchChunk error of one chunk of SaveBatch or UpdateBatch
+type ErrBatchChunk struct {
+	Chunk  int
+	Offset int
+	Size   int
+	Err    error
+}
+
+func (this *ErrBatchChunk) Error() string {
+	return fmt.Sprintf("chunk %v (offset %v, size %v): %v", this.Chunk, this.Offset, this.Size, this.Err)
+}
+
+func (this *ErrBatchChunk) Unwrap() error {
+	return this.Err
+}
+
+// ErrBatch is returned by SaveBatch and UpdateBatch when any chunk fails
+type ErrBatch struct {
+	Chunks []*ErrBatchChunk
+	Total  int
+}
+
+func (this *ErrBatch) Error() string {
+	return fmt.Sprintf("batch error: %v of %v chunks failed, first error: %v", len(this.Chunks), this.Total, this.Chunks[0])
+}
+
+func (this *ErrBatch) Unwrap() []error {
+	errs := []error{}
+	for _, it := range this.Chunks {
+		errs = append(errs, it)
+	}
+	return errs
+}
+
+// SaveBatch insert entities in chunks using InsertMulti. entities should be a slice of pointers of the same model.
+// Set tenant, check authorization and run hooks of each entity. On transaction, each chunk runs in a savepoint,
+// so a failed chunk does not rollback the others. Returns the count of inserted rows and *ErrBatch on chunk errors.
+// Audited entities or entities with after save hooks or listeners are inserted one by one, so the id is set
+// before audit and hooks. Otherwise the id depends of the driver support of InsertMulti.
+func (this *Session) SaveBatch(entities interface{}, chunkSize int) (int64, error) {
+	return this.runBatch("Session.SaveBatch", entities, chunkSize, this.saveChunk)
+}
+
+// UpdateBatch update entities in chunks. Each entity is updated with Session.Update rules (tenant,
+// authorization, hooks, version and audit). On transaction, each chunk runs in a savepoint.
+func (this *Session) UpdateBatch(entities interface{}, chunkSize int) (int64, error) {
+	return this.runBatch("Session.UpdateBatch", entities, chunkSize, this.updateChunk)
+}
+
+func (this *Session) runBatch(operation string, entities interface{}, chunkSize int, fn func(string, []interface{}) (int64, error)) (int64, error) {
+
+	items, err := toInterfaceSlice(entities)
+
+	if err != nil {
+		return 0, fmt.Errorf("%v: %v", operation, err)
+	}
+
+	if chunkSize <= 0 {
+		chunkSize = DefaultBatchChunkSize
+	}
+
+	var total int64
+	batchErr := &ErrBatch{}
+
+	for offset := 0; offset < len(items); offset += chunkSize {
+
+		end := offset + chunkSize
+		if end > len(items) {
+			end = len(items)
+		}
+
+		chunk := items[offset:end]
+		var count int64
+
+		run := func(s *Session) error {
+			c, err := fn(operation, chunk)
+			count = c
+			return err
+		}
+
+		if this.tx {
+			err = this.Nested(run)
+		} else {
+			err = run(this)
+		}
+
+		batchErr.Total++
+
+		if err != nil {
+			// out of transaction, the rows inserted before the error are kept
+			if !this.tx {
+				total += count
+			}
+			logs.Error("%v chunk %v error: %v", operation, batchErr.Total, err)
+			batchErr.Chunks = append(batchErr.Chunks, &ErrBatchChunk{
+				Chunk: batchErr.Total, Offset: offset, Size: len(chunk), Err: err})
+			continue
+		}
+
+		total += count
+	}
+
+	if len(batchErr.Chunks) > 0 {
+		return total, batchErr
+	}
+
+	return total, nil
+}
+
+func (this *Session) saveChunk(operation string, chunk []interface{}) (int64, error) {
+
+	values := reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(chunk[0])), 0, len(chunk))
+
+	for _, entity := range chunk {
+		if err := this.beforeSave(entity, operation); err != nil {
+			return 0, err
+		}
+		values = reflect.Append(values, reflect.ValueOf(entity))
+	}
+
+	var count int64
+	var err error
+	start := time.Now()
+
+	// audit and after hooks need the entity id
+	if this.IsAudited(chunk[0]) || this.hasAfterHooks(HookOperationSave, chunk[0]) {
+		for _, entity := range chunk {
+			if _, err = this.GetDb().Insert(entity); err != nil {
+				break
+			}
+			count++
+		}
+	} else {
+		count, err = this.GetDb().InsertMulti(len(chunk), values.Interface())
+	}
+
+	this.observeQuery("insert", chunk[0], start, count, err)
+
+	if this.Debug {
+		logs.Debug("## save batch %v: %v rows", getTypeName(chunk[0]), count)
+	}
+
+	if count > 0 {
+		this.invalidateQueryCache(chunk[0])
+	}
+
+	if err != nil {
+		this.SetError()
+		return count, err
+	}
+
+	for _, entity := range chunk {
+
+		if err := this.audit(AuditActionCreate, entity, nil); err != nil {
+			return count, err
+		}
+
+		if err := this.runAfterHooks(HookOperationSave, entity); err != nil {
+			return count, err
+		}
+	}
+
+	return count, nil
+}
+
+func (this *Session) updateChunk(operation string, chunk []interface{}) (int64, error) {
+
+	var count int64
+
+	for _, entity := range chunk {
+		if err := this.Update(entity); err != nil {
+			return 0, err
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+func toInterfaceSlice(entities interface{}) ([]interface{}, error) {
+
+	value := reflect.ValueOf(entities)
+
+	if value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+
+	if value.Kind() != reflect.Slice {
+		return nil, errors.New("entities should be a slice")
+	}
+
+	items := []interface{}{}
+	var itemType reflect.Type
+
+	for i := 0; i < value.Len(); i++ {
+
+		item := value.Index(i).Interface()
+
+		if item == nil || reflect.TypeOf(item).Kind() != reflect.Ptr {
+			return nil, fmt.Errorf("entity at index %v should be a pointer", i)
+		}
+
+		if itemType == nil {
+			itemType = reflect.TypeOf(item)
+		} else if itemType != reflect.TypeOf(item) {
+			return nil, fmt.Errorf("entity at index %v has type %v, expected %v", i, reflect.TypeOf(item), itemType)
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
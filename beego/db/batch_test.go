@@ -0,0 +1,100 @@
+package db_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mobilemindtech/go-utils/beego/db"
+)
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestSaveBatch
+func TestSaveBatch(t *testing.T) {
+
+	session, company := newTenantSession(t, "batch")
+
+	products := []*Product{}
+
+	for i := 0; i < 5; i++ {
+		products = append(products, &Product{Code: fmt.Sprintf("p%v", i)})
+	}
+
+	count, err := session.SaveBatch(products, 2)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 5 {
+		t.Errorf("expected 5 inserted, got %v", count)
+	}
+
+	for _, it := range products {
+		if it.Tenant == nil || it.Tenant.Id != company.Id || it.Version != 1 {
+			t.Errorf("expected tenant and version of %v", it.Code)
+		}
+	}
+
+	if total, _ := session.Count(new(Product)); total != 5 {
+		t.Errorf("expected 5 products, got %v", total)
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestSaveBatchChunkError
+func TestSaveBatchChunkError(t *testing.T) {
+
+	session, _ := newTenantSession(t, "batch error")
+
+	// second chunk has duplicated unique code
+	products := []*Product{{Code: "p1"}, {Code: "p2"}, {Code: "p3"}, {Code: "p3"}}
+
+	count, err := session.SaveBatch(products, 2)
+
+	var batchErr *db.ErrBatch
+
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("expected ErrBatch, got %v", err)
+	}
+
+	if len(batchErr.Chunks) != 1 || batchErr.Chunks[0].Chunk != 2 || batchErr.Total != 2 {
+		t.Errorf("expected error of second chunk, got %v", batchErr)
+	}
+
+	if count != 2 {
+		t.Errorf("expected 2 inserted, got %v", count)
+	}
+
+	// failed chunk rolled back to savepoint
+	if total, _ := session.Count(new(Product)); total != 2 {
+		t.Errorf("expected 2 products, got %v", total)
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestSaveBatchAfterHooks
+func TestSaveBatchAfterHooks(t *testing.T) {
+
+	session, _ := newTenantSession(t, "batch hooks")
+
+	ids := []int64{}
+
+	session.OnAfterSave(func(entity interface{}, s *db.Session) error {
+		ids = append(ids, entity.(*Product).Id)
+		return nil
+	})
+
+	products := []*Product{{Code: "p1"}, {Code: "p2"}, {Code: "p3"}}
+
+	if _, err := session.SaveBatch(products, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ids) != len(products) {
+		t.Fatalf("expected %v after hooks, got %v", len(products), len(ids))
+	}
+
+	for i, it := range products {
+		if it.Id == 0 || ids[i] != it.Id {
+			t.Errorf("expected id of %v on after hook, got %v", it.Code, ids[i])
+		}
+	}
+}
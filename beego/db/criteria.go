@@ -5,13 +5,14 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/beego/beego/v2/client/orm"
 	"github.com/beego/beego/v2/client/orm/clauses/order_clause"
 	"github.com/beego/beego/v2/core/logs"
 	"github.com/mobilemindtech/go-io/result"
-	"github.com/mobilemindtech/go-utils/v2/optional"
 	"github.com/mobilemindtech/go-utils/assert"
+	"github.com/mobilemindtech/go-utils/v2/optional"
 )
 
 type CriteriaExpression int
@@ -37,6 +38,11 @@ const (
 	OrAnd
 	AndOrAnd
 	Raw
+	InSubquery
+	NotInSubquery
+	ExistsSubquery
+	NotExistsSubquery
+	EqOuter
 )
 
 const (
@@ -137,6 +143,9 @@ type Criteria struct {
 
 	RelatedSelList []string
 
+	// relations loaded by Session.EagerList after List and One
+	eagerPaths []string
+
 	Any      bool
 	Empty    bool
 	HasError bool
@@ -149,6 +158,28 @@ type Criteria struct {
 
 	tenantCopy interface{}
 
+	softDeleteFilter SoftDeleteFilter
+
+	cursorMode    bool
+	cursorOrdered bool
+	cursor        string
+	NextCursor    string
+
+	cacheTTL time.Duration
+
+	// subquery criteria of InSubquery, NotInSubquery, ExistsSubquery and NotExistsSubquery
+	Subquery   *Criteria
+	selectPath string
+	sqlAlias   string
+	sqlOuter   *Criteria
+	sqlJoins   []*sqlJoin
+
+	filters []*Filter
+	// orm condition of json filter, with bound values. RawQuery is used by compiled sql
+	filterCondition *orm.Condition
+
+	readOnly bool
+
 	aggregate string
 	groupBy   string
 
@@ -229,6 +260,7 @@ func (this *Criteria) IsExists() bool {
 
 func (this *Criteria) SetDefaults() *Criteria {
 	this.RelatedSelList = []string{}
+	this.eagerPaths = nil
 	return this.clearConditions()
 }
 
@@ -274,12 +306,17 @@ func (this *Criteria) SetResults(results []interface{}) *Criteria {
 	return this
 }
 
+// SetPage limit, offset, sort and filters of page. Page with CursorMode or Cursor is a keyset page, listed by ListAndCount as PageCursor
 func (this *Criteria) SetPage(page *Page) *Criteria {
 	this.Page = page
 
 	this.Limit = page.Limit
 	this.Offset = page.Offset
 
+	if page.CursorMode || len(page.Cursor) > 0 {
+		this.AfterCursor(page.Cursor)
+	}
+
 	return this
 }
 
@@ -305,6 +342,12 @@ func (this *Criteria) SetRelatedsSel(relateds ...string) *Criteria {
 	return this
 }
 
+// EagerList load relations of results with one IN query by relation, by Session.EagerList. eg.: EagerList("User", "User__Role")
+func (this *Criteria) EagerList(paths ...string) *Criteria {
+	this.eagerPaths = append(this.eagerPaths, paths...)
+	return this
+}
+
 func (this *Criteria) SetDistinct() *Criteria {
 	this.Distinct = true
 	return this
@@ -326,6 +369,7 @@ func (this *Criteria) Eq(path string, value interface{}) *Criteria {
 	return this.add(path, value, Eq, false, false)
 }
 
+// Raw add sql after path column. eg.: Raw("Price", "> ?", 10). Values are inlined, so only numbers, bool and models (by id) are supported
 func (this *Criteria) Raw(path string, query string, args ...interface{}) *Criteria {
 	this.criterias = append(
 		this.criterias, &Criteria{Path: path,
@@ -387,6 +431,12 @@ func (this *Criteria) OrAnd(criteria *Criteria) *Criteria {
 	return this
 }
 
+// HasGroups returns true when criteria has or/and groups of conditions
+func (this *Criteria) HasGroups() bool {
+	return len(this.criteriasOr) > 0 || len(this.criteriasAnd) > 0 || len(this.criteriasAndOr) > 0 ||
+		len(this.criteriasAndOrAnd) > 0 || len(this.criteriasOrAnd) > 0
+}
+
 func (this *Criteria) Like(path string, value interface{}) *Criteria {
 	this.criterias = append(this.criterias, &Criteria{Path: path, Value: value, Expression: Like, Match: IAnywhare})
 	return this
@@ -489,6 +539,14 @@ func (this *Criteria) AsResult() *result.Result[bool] {
 }
 
 func (this *Criteria) ListAndCount() *Criteria {
+
+	// keyset page is not counted, the count would include the cursor condition
+	if this.cursorMode {
+		this.PageCursor()
+		this.criteriaType = CriteriaListAndCount
+		return this
+	}
+
 	this.execute(CriteriaList)
 	this.execute(CriteriaCount)
 	this.criteriaType = CriteriaListAndCount
@@ -517,6 +575,41 @@ func (this *Criteria) Delete() *Criteria {
 	return this.execute(CriteriaDelete)
 }
 
+// WithDeleted include soft removed rows
+func (this *Criteria) WithDeleted() *Criteria {
+	this.softDeleteFilter = SoftDeleteInclude
+	return this
+}
+
+// OnlyDeleted filter only soft removed rows
+func (this *Criteria) OnlyDeleted() *Criteria {
+	this.softDeleteFilter = SoftDeleteOnly
+	return this
+}
+
+// Restore unmark soft removed rows
+func (this *Criteria) Restore() *Criteria {
+
+	field, ok, err := this.Session.getSoftDeleteField(this.Result)
+
+	if err != nil {
+		this.SetError(err)
+		return this
+	}
+
+	if !ok {
+		this.SetError(errors.New("entity does not has soft delete field"))
+		return this
+	}
+
+	this.OnlyDeleted()
+
+	if field.isTime() {
+		return this.Update(map[string]interface{}{field.name: nil})
+	}
+	return this.Update(map[string]interface{}{field.name: false})
+}
+
 func (this *Criteria) Update(args map[string]interface{}) *Criteria {
 	this.UpdateParams = args
 	return this.execute(CriteriaUpdate)
@@ -532,7 +625,11 @@ func (this *Criteria) Query() orm.QuerySeter {
 
 		assert.Assert(ok, "entity [%v] should implements Model interface", reflect.TypeOf(this.Result))
 
-		this.query = this.Session.GetDb().QueryTable(model.TableName())
+		if this.readOnly {
+			this.query = this.Session.GetReadDb().QueryTable(model.TableName())
+		} else {
+			this.query = this.Session.GetDb().QueryTable(model.TableName())
+		}
 	}
 
 	return this.query
@@ -565,7 +662,9 @@ func (this *Criteria) buildPage() {
 
 	if this.Page != nil {
 
-		if len(strings.TrimSpace(this.Page.Sort)) > 0 {
+		if len(strings.TrimSpace(this.Page.Sort)) > 0 && !this.isSortAllowed(this.Page.Sort) {
+			this.SetError(fmt.Errorf("%v: sort %v", ErrFilterNotAllowed, this.Page.Sort))
+		} else if len(strings.TrimSpace(this.Page.Sort)) > 0 {
 			switch this.Page.Order {
 			case "asc":
 				this.OrderAsc(this.Page.Sort)
@@ -604,8 +703,18 @@ func (this *Criteria) buildPage() {
 				this.Eq(k, v)
 			}
 		}
+
+		if this.Page.filterErr != nil {
+			this.SetError(this.Page.filterErr)
+		}
 	}
 
+	filters := this.filters
+	if this.Page != nil && this.Page.Filter != nil && !this.Page.Filter.IsEmpty() {
+		filters = append(append([]*Filter{}, filters...), this.Page.Filter)
+	}
+	this.buildFilters(filters)
+
 }
 
 func (this *Criteria) buildCriterias(criterias []*Criteria) *orm.Condition {
@@ -634,8 +743,8 @@ func (this *Criteria) buildCriterias(criterias []*Criteria) *orm.Condition {
 			b = b.And(fmt.Sprintf("%v__gte", criteria.Path), criteria.Value)
 			b = b.And(fmt.Sprintf("%v__lte", criteria.Path), criteria.Value2)
 			cond = cond.AndCond(b)
-		case Raw:
-			cond = cond.Raw(pathName, criteria.RawQuery, criteria.RawValues...)
+		case Raw, InSubquery, NotInSubquery, ExistsSubquery, NotExistsSubquery, EqOuter:
+			cond = cond.AndCond(this.rawCondition(pathName, criteria))
 		default:
 			cond = cond.And(pathName, criteria.Value)
 		}
@@ -677,8 +786,12 @@ func (this *Criteria) buildConditionsOr(criterias []*Criteria, condition *orm.Co
 				b = b.And(fmt.Sprintf("%v__gte", criteria.Path), criteria.Value)
 				b = b.And(fmt.Sprintf("%v__lte", criteria.Path), criteria.Value2)
 				cond = cond.OrCond(b)
-			case Raw:
-				panic("raw not suported to OR condition")
+			case Raw, InSubquery, NotInSubquery, ExistsSubquery, NotExistsSubquery, EqOuter:
+				if criteria.ForceAnd {
+					cond = cond.AndCond(this.rawCondition(pathName, criteria))
+				} else {
+					cond = cond.OrCond(this.rawCondition(pathName, criteria))
+				}
 			default:
 				if criteria.ForceAnd {
 					cond = cond.And(pathName, criteria.Value)
@@ -713,8 +826,12 @@ func (this *Criteria) buildConditionsAnd(criterias []*Criteria, condition *orm.C
 			pathName := this.getPathName(criteria)
 
 			switch criteria.Expression {
-			case Ne, NotIn:
+			case In:
+				cond = cond.And(pathName, criteria.InValues)
+			case Ne, NotLike:
 				cond = cond.AndNot(pathName, criteria.Value)
+			case NotIn:
+				cond = cond.AndNot(pathName, criteria.InValues)
 			case IsNull:
 				cond = cond.And(pathName, true)
 			case IsNotNull:
@@ -722,8 +839,8 @@ func (this *Criteria) buildConditionsAnd(criterias []*Criteria, condition *orm.C
 			case Between:
 				cond = cond.And(fmt.Sprintf("%v__gte", criteria.Path), criteria.Value)
 				cond = cond.And(fmt.Sprintf("%v__lte", criteria.Path), criteria.Value2)
-			case Raw:
-				cond = cond.Raw(pathName, criteria.RawQuery, criteria.RawValues...)
+			case Raw, InSubquery, NotInSubquery, ExistsSubquery, NotExistsSubquery, EqOuter:
+				cond = cond.AndCond(this.rawCondition(pathName, criteria))
 			default:
 				cond = cond.And(pathName, criteria.Value)
 			}
@@ -751,8 +868,12 @@ func (this *Criteria) buildConditionsAndOr(criterias []*Criteria, condition *orm
 			pathName := this.getPathName(criteria)
 
 			switch criteria.Expression {
-			case Ne, NotIn:
+			case In:
+				cond = cond.Or(pathName, criteria.InValues)
+			case Ne, NotLike:
 				cond = cond.OrNot(pathName, criteria.Value)
+			case NotIn:
+				cond = cond.OrNot(pathName, criteria.InValues)
 			case IsNull:
 				cond = cond.Or(pathName, true)
 			case IsNotNull:
@@ -762,8 +883,12 @@ func (this *Criteria) buildConditionsAndOr(criterias []*Criteria, condition *orm
 				b = b.And(fmt.Sprintf("%v__gte", criteria.Path), criteria.Value)
 				b = b.And(fmt.Sprintf("%v__lte", criteria.Path), criteria.Value2)
 				cond = cond.OrCond(b)
-			case Raw:
-				panic("raw not suported to OR condition")
+			case Raw, InSubquery, NotInSubquery, ExistsSubquery, NotExistsSubquery, EqOuter:
+				if criteria.ForceAnd {
+					cond = cond.AndCond(this.rawCondition(pathName, criteria))
+				} else {
+					cond = cond.OrCond(this.rawCondition(pathName, criteria))
+				}
 			default:
 				if criteria.ForceAnd {
 					cond = cond.And(pathName, criteria.Value)
@@ -793,49 +918,50 @@ func (this *Criteria) buildConditionsAndOr(criterias []*Criteria, condition *orm
 
 func (this *Criteria) buildConditionsAndOrAnd(criterias []*CriteriaSet, condition *orm.Condition) *orm.Condition {
 
-	if len(this.criteriasAndOrAnd) > 0 {
+	// each set is one AND group, of criterias joined by OR
+	for _, criteriaSet := range criterias {
 
 		cond := orm.NewCondition()
 
-		for _, criteriaSet := range criterias {
-
-			for _, ct := range criteriaSet.Criterias {
-
-				other := orm.NewCondition()
-
-				for _, criteria := range ct.criterias {
-					pathName := this.getPathName(criteria)
-
-					switch criteria.Expression {
-					case Ne, NotIn:
-						other = other.AndNot(pathName, criteria.Value)
-					case IsNull:
-						other = other.And(pathName, true)
-					case IsNotNull:
-						other = other.And(pathName, false)
-					case Between:
-						b := orm.NewCondition()
-						b = b.And(fmt.Sprintf("%v__gte", criteria.Path), criteria.Value)
-						b = b.And(fmt.Sprintf("%v__lte", criteria.Path), criteria.Value2)
-						other = other.AndCond(b)
-					case Raw:
-						cond = cond.Raw(pathName, criteria.RawQuery, criteria.RawValues...)
-					default:
-						other = other.And(pathName, criteria.Value)
-					}
-
-					if this.Debug {
-						logs.Debug("*********************************************************")
-						logs.Debug("** set condition and or %v ", pathName)
-						logs.Debug("*********************************************************")
-					}
-
+		for _, ct := range criteriaSet.Criterias {
+
+			other := orm.NewCondition()
+
+			for _, criteria := range ct.criterias {
+				pathName := this.getPathName(criteria)
+
+				switch criteria.Expression {
+				case In:
+					other = other.And(pathName, criteria.InValues)
+				case Ne, NotLike:
+					other = other.AndNot(pathName, criteria.Value)
+				case NotIn:
+					other = other.AndNot(pathName, criteria.InValues)
+				case IsNull:
+					other = other.And(pathName, true)
+				case IsNotNull:
+					other = other.And(pathName, false)
+				case Between:
+					b := orm.NewCondition()
+					b = b.And(fmt.Sprintf("%v__gte", criteria.Path), criteria.Value)
+					b = b.And(fmt.Sprintf("%v__lte", criteria.Path), criteria.Value2)
+					other = other.AndCond(b)
+				case Raw, InSubquery, NotInSubquery, ExistsSubquery, NotExistsSubquery, EqOuter:
+					other = other.AndCond(this.rawCondition(pathName, criteria))
+				default:
+					other = other.And(pathName, criteria.Value)
 				}
 
-				cond = cond.OrCond(other)
+				if this.Debug {
+					logs.Debug("*********************************************************")
+					logs.Debug("** set condition and or %v ", pathName)
+					logs.Debug("*********************************************************")
+				}
 
 			}
 
+			cond = cond.OrCond(other)
+
 		}
 
 		condition = condition.AndCond(cond)
@@ -853,8 +979,12 @@ func (this *Criteria) buildConditionsOrAnd(criterias []*Criteria, condition *orm
 			pathName := this.getPathName(criteria)
 
 			switch criteria.Expression {
-			case Ne, NotIn:
+			case In:
+				cond = cond.And(pathName, criteria.InValues)
+			case Ne, NotLike:
 				cond = cond.AndNot(pathName, criteria.Value)
+			case NotIn:
+				cond = cond.AndNot(pathName, criteria.InValues)
 			case IsNull:
 				cond = cond.And(pathName, true)
 			case IsNotNull:
@@ -864,8 +994,8 @@ func (this *Criteria) buildConditionsOrAnd(criterias []*Criteria, condition *orm
 				b = b.And(fmt.Sprintf("%v__gte", criteria.Path), criteria.Value)
 				b = b.And(fmt.Sprintf("%v__lte", criteria.Path), criteria.Value2)
 				cond = cond.AndCond(b)
-			case Raw:
-				cond = cond.Raw(pathName, criteria.RawQuery, criteria.RawValues...)
+			case Raw, InSubquery, NotInSubquery, ExistsSubquery, NotExistsSubquery, EqOuter:
+				cond = cond.AndCond(this.rawCondition(pathName, criteria))
 			default:
 				cond = cond.And(pathName, criteria.Value)
 			}
@@ -902,12 +1032,46 @@ func (this *Criteria) build(query orm.QuerySeter) orm.QuerySeter {
 
 	condition = this.buildConditionsOrAnd(this.criteriasOrAnd, condition)
 
+	condition = this.buildCursor(condition)
+
+	condition = this.buildSoftDelete(condition)
+
 	query = query.SetCond(condition)
 
 	return query
 
 }
 
+// filter soft removed rows, wrapping all conditions
+func (this *Criteria) buildSoftDelete(condition *orm.Condition) *orm.Condition {
+
+	if this.softDeleteFilter == SoftDeleteInclude || this.Session.IgnoreSoftDeleteFilter {
+		return condition
+	}
+
+	field, ok, err := this.Session.getSoftDeleteField(this.Result)
+
+	if err != nil {
+		this.SetError(err)
+	}
+
+	if !ok {
+		return condition
+	}
+
+	expr, value := field.notDeletedExpr()
+
+	if this.softDeleteFilter == SoftDeleteOnly {
+		expr, value = field.deletedExpr()
+	}
+
+	if condition.IsEmpty() {
+		return orm.NewCondition().And(expr, value)
+	}
+
+	return orm.NewCondition().AndCond(condition).And(expr, value)
+}
+
 func (this *Criteria) getPathName(criteria *Criteria) string {
 	pathName := criteria.Path
 
@@ -981,6 +1145,27 @@ func (this *Criteria) execute(resultType CriteriaResult) *Criteria {
 		hook.BeforeCriteria(this)
 	}
 
+	cacheKey := this.getQueryCacheKey(resultType)
+
+	// cached rows are stored before eager loading and hooks, that run on cache hit too
+	if len(cacheKey) > 0 && this.loadQueryCache(cacheKey, resultType) {
+		this.afterResults(resultType)
+		return this
+	}
+
+	defer this.observeQuery(resultType, time.Now(), this.observeSQL(resultType))
+
+	// read only operations can use read replica
+	readOnly := resultType != CriteriaUpdate && resultType != CriteriaDelete
+	if this.readOnly != readOnly {
+		this.readOnly = readOnly
+		this.query = nil
+	}
+
+	if readOnly {
+		defer this.Session.observeRead(time.Now())
+	}
+
 	query := this.Query()
 
 	if this.Limit > 0 {
@@ -991,10 +1176,17 @@ func (this *Criteria) execute(resultType CriteriaResult) *Criteria {
 		this.Eq("Tenant", this.Session.Tenant)
 	}
 
+	hasError := this.HasError
+
 	this.buildSearchPaths(this.searchPaths, this.searchValue)
 	this.buildPage()
 	query = this.build(query)
 
+	// invalid filter, subquery or cursor
+	if this.HasError && !hasError {
+		return this
+	}
+
 	if this.Distinct {
 		query = query.Distinct()
 	}
@@ -1094,9 +1286,8 @@ func (this *Criteria) execute(resultType CriteriaResult) *Criteria {
 		this.Any = reflect.ValueOf(this.Results).Elem().Len() > 0
 		this.Empty = !this.Any
 
-		if hook, ok := this.Result.(ModelHookAfterList); ok {
-			hook.AfterList(this.Results)
-		}
+		this.putQueryCache(cacheKey, resultType)
+		this.afterResults(resultType)
 
 	case CriteriaOne:
 
@@ -1148,28 +1339,14 @@ func (this *Criteria) execute(resultType CriteriaResult) *Criteria {
 			this.SetError(nil)
 		}
 
-		if !this.HasError {
-			if hook, ok := this.Result.(ModelHookAfterLoad); ok {
-				if next, err := hook.AfterLoad(this.Result); !next || err != nil {
-
-					if err != nil {
-						this.SetError(err)
-						this.Result = nil
-					}
-
-					if !next {
-						this.Result = nil
-					}
-
-				}
-			}
-		}
-
 		if model, ok := this.Result.(Model); ok {
 			this.Any = model.IsPersisted()
 		}
 		this.Empty = !this.Any
 
+		this.putQueryCache(cacheKey, resultType)
+		this.afterResults(resultType)
+
 	case CriteriaCount, CriteriaExists:
 
 		count, err := this.Session.ToCount(query)
@@ -1182,9 +1359,25 @@ func (this *Criteria) execute(resultType CriteriaResult) *Criteria {
 
 		this.SetError(err)
 
+		this.putQueryCache(cacheKey, resultType)
+
 	case CriteriaDelete:
 
-		count, err := this.Session.ExecuteDelete(query)
+		var count int64
+
+		field, ok, err := this.Session.getSoftDeleteField(this.Result)
+
+		if err == nil {
+			if ok {
+				count, err = this.Session.ExecuteUpdate(query, map[string]interface{}{field.name: field.deletedValue()})
+			} else {
+				count, err = this.Session.ExecuteDelete(query)
+			}
+		}
+
+		if err == nil {
+			this.Session.invalidateQueryCache(this.Result)
+		}
 
 		this.Count64 = count
 		this.Count32 = int(count)
@@ -1197,6 +1390,10 @@ func (this *Criteria) execute(resultType CriteriaResult) *Criteria {
 
 		count, err := this.Session.ExecuteUpdate(query, this.UpdateParams)
 
+		if err == nil {
+			this.Session.invalidateQueryCache(this.Result)
+		}
+
 		this.Count64 = count
 		this.Count32 = int(count)
 
@@ -1210,6 +1407,105 @@ func (this *Criteria) execute(resultType CriteriaResult) *Criteria {
 
 }
 
+// compiled sql of criteria conditions to query log, nil if disabled
+func (this *Criteria) observeSQL(resultType CriteriaResult) *querySQL {
+
+	if queryLogConfig == nil {
+		return nil
+	}
+
+	selects := ""
+
+	switch resultType {
+	case CriteriaList, CriteriaOne:
+		selects = fmt.Sprintf("%v.*", this.getSQLAlias())
+	case CriteriaCount, CriteriaExists:
+		selects = "COUNT(*)"
+	default:
+		return nil
+	}
+
+	query, args, err := this.SelectSQL(selects)
+
+	if err != nil {
+		return nil
+	}
+
+	return &querySQL{sql: query, args: args}
+}
+
+// observe query of execute, when db.EnableQueryLog
+func (this *Criteria) observeQuery(resultType CriteriaResult, start time.Time, statement *querySQL) {
+
+	if queryLogConfig == nil {
+		return
+	}
+
+	var operation string
+	var rows int64 = 1
+
+	switch resultType {
+	case CriteriaList:
+		operation = "list"
+		if this.Results != nil {
+			rows = int64(reflect.ValueOf(this.Results).Elem().Len())
+		}
+	case CriteriaOne:
+		operation = "one"
+		if !this.Any {
+			rows = 0
+		}
+	case CriteriaCount, CriteriaExists:
+		operation = "count"
+	case CriteriaUpdate:
+		operation, rows = "update", this.Count64
+	case CriteriaDelete:
+		operation, rows = "delete", this.Count64
+	default:
+		operation = "aggregate"
+	}
+
+	this.Session.observeSQL(operation, getModelTableName(this.Result), statement, start, rows, this.Error)
+}
+
+// eager loading and after hooks of loaded results
+func (this *Criteria) afterResults(resultType CriteriaResult) {
+
+	switch resultType {
+
+	case CriteriaList:
+
+		if !this.HasError && this.Any && len(this.eagerPaths) > 0 {
+			this.SetError(this.Session.EagerList(this.Results, this.eagerPaths...))
+		}
+
+		if hook, ok := this.Result.(ModelHookAfterList); ok {
+			hook.AfterList(this.Results)
+		}
+
+	case CriteriaOne:
+
+		if !this.HasError && this.Any && len(this.eagerPaths) > 0 {
+			this.SetError(this.Session.EagerList(this.Result, this.eagerPaths...))
+		}
+
+		if !this.HasError {
+			if hook, ok := this.Result.(ModelHookAfterLoad); ok {
+				if next, err := hook.AfterLoad(this.Result); !next || err != nil {
+
+					if err != nil {
+						this.SetError(err)
+					}
+
+					this.Result = nil
+					this.Any = false
+					this.Empty = true
+				}
+			}
+		}
+	}
+}
+
 func (this *Criteria) SetError(err error) {
 	if err != nil && this.Error == nil {
 		this.HasError = true
@@ -0,0 +1,472 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// criteria operators, same of beego orm
+var criteriaSqlOperators = map[string]bool{
+	"exact": true, "iexact": true, "contains": true, "icontains": true,
+	"gt": true, "gte": true, "lt": true, "lte": true,
+	"startswith": true, "endswith": true, "istartswith": true, "iendswith": true,
+	"in": true, "isnull": true,
+}
+
+// sql condition, with the same semantic of orm.Condition (first connector is ignored)
+type sqlCondition struct {
+	sql  strings.Builder
+	args []interface{}
+}
+
+func (this *sqlCondition) IsEmpty() bool {
+	return this.sql.Len() == 0
+}
+
+func (this *sqlCondition) add(or bool, not bool, expr string, args ...interface{}) *sqlCondition {
+
+	if !this.IsEmpty() {
+		if or {
+			this.sql.WriteString(" OR ")
+		} else {
+			this.sql.WriteString(" AND ")
+		}
+	}
+
+	if not {
+		this.sql.WriteString("NOT ")
+	}
+
+	this.sql.WriteString(expr)
+	this.args = append(this.args, args...)
+	return this
+}
+
+func (this *sqlCondition) addCond(or bool, other *sqlCondition) *sqlCondition {
+	if other.IsEmpty() {
+		return this
+	}
+	return this.add(or, false, fmt.Sprintf("(%v)", other.sql.String()), other.args...)
+}
+
+func (this *sqlCondition) String() string {
+	return this.sql.String()
+}
+
+// left join of relation path, to conditions and aggregates of relation fields
+type sqlJoin struct {
+	path  string
+	alias string
+	sql   string
+}
+
+// compile criteria conditions, tenant filter, search, page filters and soft delete to sql where
+// of table alias. Fields of relations are joined (eg.: Name, Tenant, Tenant__Id, Customer__Name)
+func (this *Criteria) compileSQL() (string, []interface{}, error) {
+
+	state := this.snapshot()
+	defer this.restore(state)
+
+	if this.Session.HasTenant() && this.Session.HasFilterTenant(this.Result) {
+		this.Eq("Tenant", this.Session.Tenant)
+	}
+
+	this.buildSearchPaths(this.searchPaths, this.searchValue)
+	this.buildPage()
+
+	var err error
+
+	expr := func(criteria *Criteria) (string, []interface{}, bool) {
+		if err != nil {
+			return "", nil, false
+		}
+		sql, args, not, e := this.compileCriteriaSQL(criteria)
+		if e != nil {
+			err = e
+		}
+		return sql, args, not
+	}
+
+	tenantCond := func(cond *sqlCondition) {
+		if this.Session.HasTenant() && this.Session.HasFilterTenant(this.Result) && !this.Session.IgnoreTenantFilter {
+			sql, args, not := expr(&Criteria{Path: "Tenant", Value: this.Session.Tenant, Expression: Eq})
+			cond.add(false, not, sql, args...)
+		}
+	}
+
+	condition := new(sqlCondition)
+
+	for _, criteria := range this.criterias {
+		sql, args, not := expr(criteria)
+		condition.addCond(false, new(sqlCondition).add(false, not, sql, args...))
+	}
+
+	for _, c := range this.criteriasOr {
+		cond := new(sqlCondition)
+		for _, criteria := range c.criterias {
+			sql, args, not := expr(criteria)
+			cond.add(!criteria.ForceAnd, not, sql, args...)
+		}
+		tenantCond(cond)
+		condition.addCond(true, cond)
+	}
+
+	for _, c := range this.criteriasAnd {
+		cond := new(sqlCondition)
+		for _, criteria := range c.criterias {
+			sql, args, not := expr(criteria)
+			cond.add(false, not, sql, args...)
+		}
+		condition.addCond(false, cond)
+	}
+
+	for _, c := range this.criteriasAndOr {
+		cond := new(sqlCondition)
+		for _, criteria := range c.criterias {
+			sql, args, not := expr(criteria)
+			cond.add(!criteria.ForceAnd, not, sql, args...)
+		}
+		tenantCond(cond)
+		condition.addCond(false, cond)
+	}
+
+	for _, set := range this.criteriasAndOrAnd {
+		cond := new(sqlCondition)
+		for _, ct := range set.Criterias {
+			other := new(sqlCondition)
+			for _, criteria := range ct.criterias {
+				sql, args, not := expr(criteria)
+				other.add(false, not, sql, args...)
+			}
+			cond.addCond(true, other)
+		}
+		condition.addCond(false, cond)
+	}
+
+	for _, c := range this.criteriasOrAnd {
+		cond := new(sqlCondition)
+		for _, criteria := range c.criterias {
+			sql, args, not := expr(criteria)
+			cond.add(false, not, sql, args...)
+		}
+		tenantCond(cond)
+		condition.addCond(true, cond)
+	}
+
+	if this.softDeleteFilter != SoftDeleteInclude && !this.Session.IgnoreSoftDeleteFilter {
+		field, ok, fieldErr := this.Session.getSoftDeleteField(this.Result)
+		if fieldErr != nil {
+			return "", nil, fieldErr
+		}
+		if ok {
+			path, value := field.notDeletedExpr()
+			if this.softDeleteFilter == SoftDeleteOnly {
+				path, value = field.deletedExpr()
+			}
+			sql, args, not := expr(&Criteria{Path: path, Value: value, Expression: Eq})
+			wrapped := new(sqlCondition).addCond(false, condition).add(false, not, sql, args...)
+			condition = wrapped
+		}
+	}
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	if condition.IsEmpty() {
+		return "", nil, nil
+	}
+
+	return fmt.Sprintf(" WHERE %v", condition.String()), condition.args, nil
+}
+
+// compile one criteria. returns sql, args and if should be negated
+func (this *Criteria) compileCriteriaSQL(criteria *Criteria) (string, []interface{}, bool, error) {
+
+	pathName := this.getPathName(criteria)
+	exprs := strings.Split(pathName, "__")
+	operator := "exact"
+
+	if len(exprs) > 1 && criteriaSqlOperators[exprs[len(exprs)-1]] {
+		operator = exprs[len(exprs)-1]
+		exprs = exprs[:len(exprs)-1]
+	}
+
+	column, err := this.resolveSQLColumn(strings.Join(exprs, "__"))
+
+	if err != nil {
+		return "", nil, false, err
+	}
+
+	if criteria.Subquery != nil {
+		kind, sql, args, err := this.subquerySQL(criteria)
+		if kind == InSubquery || kind == NotInSubquery {
+			sql = fmt.Sprintf("%v %v", column, sql)
+		}
+		return sql, args, false, err
+	}
+
+	if criteria.Expression == EqOuter {
+		outer, err := this.outerSQLColumn(fmt.Sprintf("%v", criteria.Value))
+		if err != nil {
+			return "", nil, false, err
+		}
+		return fmt.Sprintf("%v = %v", column, outer), nil, false, nil
+	}
+
+	not := false
+
+	switch criteria.Expression {
+	case Ne, NotLike, NotIn:
+		not = true
+	}
+
+	switch criteria.Expression {
+	case In, NotIn:
+		values := flatSQLValues(criteria.InValues)
+		if len(values) == 0 {
+			return fmt.Sprintf("%v IN (NULL)", column), nil, not, nil
+		}
+		marks := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+		return fmt.Sprintf("%v IN (%v)", column, marks), values, not, nil
+	case IsNull:
+		return fmt.Sprintf("%v IS NULL", column), nil, not, nil
+	case IsNotNull:
+		return fmt.Sprintf("%v IS NOT NULL", column), nil, not, nil
+	case Between:
+		return fmt.Sprintf("%v BETWEEN ? AND ?", column), []interface{}{sqlValue(criteria.Value), sqlValue(criteria.Value2)}, not, nil
+	case Raw:
+		return fmt.Sprintf("%v %v", column, criteria.RawQuery), criteria.RawValues, not, nil
+	}
+
+	value := sqlValue(criteria.Value)
+
+	switch operator {
+	case "exact":
+		if value == nil {
+			return fmt.Sprintf("%v IS NULL", column), nil, not, nil
+		}
+		return fmt.Sprintf("%v = ?", column), []interface{}{value}, not, nil
+	case "iexact":
+		return fmt.Sprintf("UPPER(%v) = UPPER(?)", column), []interface{}{value}, not, nil
+	case "gt":
+		return fmt.Sprintf("%v > ?", column), []interface{}{value}, not, nil
+	case "gte":
+		return fmt.Sprintf("%v >= ?", column), []interface{}{value}, not, nil
+	case "lt":
+		return fmt.Sprintf("%v < ?", column), []interface{}{value}, not, nil
+	case "lte":
+		return fmt.Sprintf("%v <= ?", column), []interface{}{value}, not, nil
+	case "isnull":
+		if b, ok := value.(bool); ok && !b {
+			return fmt.Sprintf("%v IS NOT NULL", column), nil, not, nil
+		}
+		return fmt.Sprintf("%v IS NULL", column), nil, not, nil
+	case "in":
+		values := flatSQLValues([]interface{}{value})
+		marks := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+		return fmt.Sprintf("%v IN (%v)", column, marks), values, not, nil
+	}
+
+	// like operators, wildcards of value are escaped
+	param := likeEscaper.Replace(fmt.Sprintf("%v", value))
+	escape := `'\'`
+
+	// mysql strings use backslash escapes
+	if this.driverType() == orm.DRMySQL {
+		escape = `'\\'`
+	}
+
+	switch operator {
+	case "contains", "icontains":
+		param = fmt.Sprintf("%%%v%%", param)
+	case "startswith", "istartswith":
+		param = fmt.Sprintf("%v%%", param)
+	case "endswith", "iendswith":
+		param = fmt.Sprintf("%%%v", param)
+	}
+
+	if strings.HasPrefix(operator, "i") {
+		return fmt.Sprintf("UPPER(%v) LIKE UPPER(?) ESCAPE %v", column, escape), []interface{}{param}, not, nil
+	}
+
+	return fmt.Sprintf("%v LIKE ? ESCAPE %v", column, escape), []interface{}{param}, not, nil
+}
+
+// column of path with table alias. eg.: Name > T0.name, Tenant or Tenant__Id > T0.tenant_id,
+// Customer__Name > T0_1.name, with T0_1 left join of customer
+func (this *Criteria) resolveSQLColumn(path string) (string, error) {
+
+	parts := strings.Split(path, "__")
+	modelType := reflect.TypeOf(this.Result)
+	alias := this.getSQLAlias()
+
+	for i, part := range parts {
+
+		field := findModelField(GetModelFields(modelType), part)
+
+		if field == nil {
+			return "", fmt.Errorf("field %v of path %v not found on %v", part, path, getTypeName(this.Result))
+		}
+
+		if i == len(parts)-1 {
+			return fmt.Sprintf("%v.%v", alias, field.Column), nil
+		}
+
+		if !field.Rel {
+			return "", fmt.Errorf("path %v is not supported, %v is not a relation", path, part)
+		}
+
+		// relation id is the foreign key column
+		if i == len(parts)-2 && parts[i+1] == "Id" {
+			return fmt.Sprintf("%v.%v", alias, field.Column), nil
+		}
+
+		var err error
+		modelType = field.Type
+		alias, err = this.joinSQL(strings.Join(parts[:i+1], "__"), alias, field)
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return "", fmt.Errorf("invalid path %v", path)
+}
+
+// alias of relation join, created once by path
+func (this *Criteria) joinSQL(path string, parentAlias string, field *ModelField) (string, error) {
+
+	for _, it := range this.sqlJoins {
+		if it.path == path {
+			return it.alias, nil
+		}
+	}
+
+	relType := field.Type
+	if relType.Kind() == reflect.Ptr {
+		relType = relType.Elem()
+	}
+
+	model, ok := reflect.New(relType).Interface().(Model)
+
+	if !ok {
+		return "", fmt.Errorf("relation %v does not implements of Model", path)
+	}
+
+	var pk *ModelField
+	for _, it := range GetModelFields(relType) {
+		if it.Pk {
+			pk = it
+			break
+		}
+	}
+
+	if pk == nil {
+		return "", fmt.Errorf("primary key of relation %v not found", path)
+	}
+
+	alias := fmt.Sprintf("%v_%v", this.getSQLAlias(), len(this.sqlJoins)+1)
+
+	this.sqlJoins = append(this.sqlJoins, &sqlJoin{
+		path:  path,
+		alias: alias,
+		sql:   fmt.Sprintf(" LEFT JOIN %v %v ON %v.%v = %v.%v", model.TableName(), alias, alias, pk.Column, parentAlias, field.Column),
+	})
+
+	return alias, nil
+}
+
+func findModelField(fields []*ModelField, name string) *ModelField {
+	for _, it := range fields {
+		if it.Name == name || it.Column == name {
+			return it
+		}
+	}
+	return nil
+}
+
+// table alias, T0 to main query (same of beego orm), S1, S2.. to subqueries
+func (this *Criteria) getSQLAlias() string {
+	if len(this.sqlAlias) == 0 {
+		return "T0"
+	}
+	return this.sqlAlias
+}
+
+// resolve column of path, to use in aggregate expressions. relations of path are joined by SelectSQL
+func (this *Criteria) SQLColumn(path string) (string, error) {
+	return this.resolveSQLColumn(path)
+}
+
+// value to sql arg. relations are converted to id
+func sqlValue(value interface{}) interface{} {
+
+	if value == nil {
+		return nil
+	}
+
+	if _, ok := value.(Model); ok {
+		v := reflect.ValueOf(value)
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return nil
+			}
+			if id := v.Elem().FieldByName("Id"); id.IsValid() {
+				return id.Interface()
+			}
+		}
+	}
+
+	return value
+}
+
+// escape of like wildcards, with ESCAPE '\'
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func flatSQLValues(values []interface{}) []interface{} {
+	flat := []interface{}{}
+	for _, it := range values {
+		v := reflect.ValueOf(it)
+		if it != nil && v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+			for i := 0; i < v.Len(); i++ {
+				flat = append(flat, sqlValue(v.Index(i).Interface()))
+			}
+		} else {
+			flat = append(flat, sqlValue(it))
+		}
+	}
+	return flat
+}
+
+// SelectSQL build select of criteria conditions. eg.: SelectSQL("SUM(T0.value)") > SELECT SUM(T0.value) FROM table T0 WHERE ...
+func (this *Criteria) SelectSQL(selects string) (string, []interface{}, error) {
+
+	model, ok := this.Result.(Model)
+
+	if !ok {
+		return "", nil, errors.New("entity does not implements of Model")
+	}
+
+	// joins of select columns are kept
+	defer func() {
+		this.sqlJoins = nil
+	}()
+
+	where, args, err := this.compileSQL()
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	var joins strings.Builder
+	for _, it := range this.sqlJoins {
+		joins.WriteString(it.sql)
+	}
+
+	return fmt.Sprintf("SELECT %v FROM %v %v%v%v", selects, model.TableName(), this.getSQLAlias(), joins.String(), where), args, nil
+}
@@ -0,0 +1,45 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/mobilemindtech/go-utils/beego/db"
+)
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestAndOrAnd
+func TestAndOrAnd(t *testing.T) {
+
+	session, _ := newTenantSession(t, "and or and")
+
+	saveProducts(t, session,
+		&Product{Code: "p1", Name: "a", Price: 1},
+		&Product{Code: "p2", Name: "b", Price: 2},
+		&Product{Code: "p3", Name: "a", Price: 3},
+		&Product{Code: "p4", Name: "b", Price: 4})
+
+	// (Name = a AND Price = 1) OR Name = b
+	byName := db.NewCriteriaSetWithConditions(
+		db.NewCondition().Eq("Name", "a").Eq("Price", 1),
+		db.NewCondition().Eq("Name", "b"))
+
+	// Price = 1 OR Price = 2
+	byPrice := db.NewCriteriaSetWithConditions(
+		db.NewCondition().Eq("Price", 1),
+		db.NewCondition().Eq("Price", 2))
+
+	c := db.NewCriteria(session, new(Product), &[]*Product{}).AndOrAnd(byName).AndOrAnd(byPrice)
+
+	if !c.HasGroups() {
+		t.Errorf("expected criteria with groups")
+	}
+
+	products := listProducts(t, c.OrderAsc("Code"))
+
+	if len(products) != 2 || products[0].Code != "p1" || products[1].Code != "p2" {
+		t.Errorf("expected p1 and p2, got %v", len(products))
+	}
+
+	if db.NewCondition().Eq("Name", "a").HasGroups() {
+		t.Errorf("expected condition without groups")
+	}
+}
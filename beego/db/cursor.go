@@ -0,0 +1,292 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+const DefaultCursorLimit = 25
+
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+// cursor token content. o = ordering paths, v = last row values
+type cursorToken struct {
+	Orders []string       `json:"o"`
+	Values []*cursorValue `json:"v"`
+}
+
+type cursorValue struct {
+	Type  string `json:"t"`
+	Value string `json:"v"`
+}
+
+// AfterCursor enable keyset pagination, returning rows after token. Use empty token to first page.
+// Ordering columns should be not null, Id is used as tiebreaker
+func (this *Criteria) AfterCursor(token string) *Criteria {
+	this.cursorMode = true
+	this.cursor = token
+	return this
+}
+
+// PageCursor list next page of keyset pagination and set NextCursor, empty on last page
+func (this *Criteria) PageCursor() *Criteria {
+
+	this.cursorMode = true
+	this.NextCursor = ""
+
+	limit := this.Limit
+
+	if limit <= 0 {
+		limit = DefaultCursorLimit
+	}
+
+	// fetch one more row to know if has next page
+	this.Limit = limit + 1
+	this.Offset = 0
+
+	this.execute(CriteriaList)
+
+	this.Limit = limit
+
+	if this.HasError {
+		return this
+	}
+
+	results := reflect.ValueOf(this.Results).Elem()
+
+	if results.Len() > int(limit) {
+
+		results.Set(results.Slice(0, int(limit)))
+
+		token, err := this.encodeCursor(results.Index(results.Len() - 1).Interface())
+
+		if err != nil {
+			this.SetError(err)
+			return this
+		}
+
+		this.NextCursor = token
+	}
+
+	return this
+}
+
+// normalize ordering and build keyset condition
+func (this *Criteria) buildCursor(condition *orm.Condition) *orm.Condition {
+
+	if !this.cursorMode {
+		return condition
+	}
+
+	if !this.cursorOrdered {
+		this.cursorOrdered = true
+
+		hasId := false
+		desc := false
+
+		for _, it := range this.orderBy {
+			if it.IsRaw {
+				this.SetError(errors.New("raw order is not supported by cursor pagination"))
+				return condition
+			}
+			if it.Path == "Id" {
+				hasId = true
+			}
+			desc = it.Desc
+		}
+
+		if !hasId {
+			this.orderBy = append(this.orderBy, &CriteriaOrder{Path: "Id", Desc: desc})
+		}
+	}
+
+	if len(this.cursor) == 0 {
+		return condition
+	}
+
+	values, err := this.decodeCursor(this.cursor)
+
+	if err != nil {
+		this.SetError(err)
+		return condition
+	}
+
+	// (a > va) OR (a = va AND b > vb) OR ...
+	keyset := orm.NewCondition()
+
+	for i, order := range this.orderBy {
+
+		cond := orm.NewCondition()
+
+		for j := 0; j < i; j++ {
+			cond = cond.And(this.orderBy[j].Path, values[j])
+		}
+
+		op := "gt"
+		if order.Desc {
+			op = "lt"
+		}
+
+		cond = cond.And(fmt.Sprintf("%v__%v", order.Path, op), values[i])
+		keyset = keyset.OrCond(cond)
+	}
+
+	if condition.IsEmpty() {
+		return keyset
+	}
+
+	return orm.NewCondition().AndCond(condition).AndCond(keyset)
+}
+
+func (this *Criteria) cursorOrders() []string {
+	orders := []string{}
+	for _, it := range this.orderBy {
+		if it.Desc {
+			orders = append(orders, "-"+it.Path)
+		} else {
+			orders = append(orders, it.Path)
+		}
+	}
+	return orders
+}
+
+func (this *Criteria) encodeCursor(entity interface{}) (string, error) {
+
+	token := &cursorToken{Orders: this.cursorOrders()}
+
+	for _, order := range this.orderBy {
+
+		value, err := getCursorFieldValue(entity, order.Path)
+
+		if err != nil {
+			return "", err
+		}
+
+		token.Values = append(token.Values, value)
+	}
+
+	data, err := json.Marshal(token)
+
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func (this *Criteria) decodeCursor(cursor string) ([]interface{}, error) {
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	token := new(cursorToken)
+
+	if err := json.Unmarshal(data, token); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	// token of another ordering
+	if strings.Join(token.Orders, ",") != strings.Join(this.cursorOrders(), ",") || len(token.Values) != len(token.Orders) {
+		return nil, ErrInvalidCursor
+	}
+
+	values := []interface{}{}
+
+	for _, it := range token.Values {
+
+		var value interface{}
+		var err error
+
+		switch it.Type {
+		case "int":
+			value, err = strconv.ParseInt(it.Value, 10, 64)
+		case "uint":
+			value, err = strconv.ParseUint(it.Value, 10, 64)
+		case "float":
+			value, err = strconv.ParseFloat(it.Value, 64)
+		case "bool":
+			value, err = strconv.ParseBool(it.Value)
+		case "time":
+			value, err = time.Parse(time.RFC3339Nano, it.Value)
+		case "string":
+			value = it.Value
+		default:
+			err = ErrInvalidCursor
+		}
+
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
+// value of path, eg.: Name, Tenant, Tenant__Id. relations use the Id value
+func getCursorFieldValue(entity interface{}, path string) (*cursorValue, error) {
+
+	value := reflect.ValueOf(entity)
+
+	for _, name := range strings.Split(path, "__") {
+
+		for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+			if value.IsNil() {
+				return nil, fmt.Errorf("cursor field %v can't be null", path)
+			}
+			value = value.Elem()
+		}
+
+		if value.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("cursor field %v not found", path)
+		}
+
+		value = value.FieldByName(name)
+
+		if !value.IsValid() {
+			return nil, fmt.Errorf("cursor field %v not found", path)
+		}
+	}
+
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil, fmt.Errorf("cursor field %v can't be null", path)
+		}
+		if _, ok := value.Interface().(Model); ok {
+			value = value.Elem().FieldByName("Id")
+		} else {
+			value = value.Elem()
+		}
+	}
+
+	if t, ok := value.Interface().(time.Time); ok {
+		return &cursorValue{Type: "time", Value: t.Format(time.RFC3339Nano)}, nil
+	}
+
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &cursorValue{Type: "int", Value: strconv.FormatInt(value.Int(), 10)}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &cursorValue{Type: "uint", Value: strconv.FormatUint(value.Uint(), 10)}, nil
+	case reflect.Float32, reflect.Float64:
+		return &cursorValue{Type: "float", Value: strconv.FormatFloat(value.Float(), 'f', -1, 64)}, nil
+	case reflect.Bool:
+		return &cursorValue{Type: "bool", Value: strconv.FormatBool(value.Bool())}, nil
+	case reflect.String:
+		return &cursorValue{Type: "string", Value: value.String()}, nil
+	}
+
+	return nil, fmt.Errorf("cursor field %v type %v is not supported", path, value.Type())
+}
@@ -0,0 +1,95 @@
+package db_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mobilemindtech/go-utils/beego/db"
+)
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestPageCursor
+func TestPageCursor(t *testing.T) {
+
+	session, _ := newTenantSession(t, "cursor")
+
+	for i := 1; i <= 5; i++ {
+		saveProducts(t, session, &Product{Code: fmt.Sprintf("p%v", i), Price: float64(i % 2)})
+	}
+
+	codes := []string{}
+	cursor := ""
+
+	for pages := 0; pages < 5; pages++ {
+
+		c := db.NewCriteria(session, new(Product), &[]*Product{}).
+			OrderAsc("Price").
+			SetLimit(2).
+			AfterCursor(cursor).
+			PageCursor()
+
+		if c.HasError {
+			t.Fatal(c.Error)
+		}
+
+		for _, it := range *c.Results.(*[]*Product) {
+			codes = append(codes, it.Code)
+		}
+
+		if cursor = c.NextCursor; len(cursor) == 0 {
+			break
+		}
+	}
+
+	expected := "[p2 p4 p1 p3 p5]"
+
+	if fmt.Sprint(codes) != expected {
+		t.Errorf("expected %v, got %v", expected, codes)
+	}
+
+	c := db.NewCriteria(session, new(Product), &[]*Product{}).AfterCursor("invalid").PageCursor()
+
+	if !c.HasError {
+		t.Errorf("expected error of invalid cursor")
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestSetPageCursor
+func TestSetPageCursor(t *testing.T) {
+
+	session, _ := newTenantSession(t, "cursor page")
+
+	for i := 1; i <= 5; i++ {
+		saveProducts(t, session, &Product{Code: fmt.Sprintf("p%v", i)})
+	}
+
+	first := db.NewCriteria(session, new(Product), &[]*Product{}).
+		SetPage(&db.Page{Limit: 2, CursorMode: true}).
+		ListAndCount()
+
+	if first.HasError {
+		t.Fatal(first.Error)
+	}
+
+	if products := *first.Results.(*[]*Product); len(products) != 2 || products[0].Code != "p1" || len(first.NextCursor) == 0 {
+		t.Fatalf("expected first cursor page with next cursor, got %v", len(products))
+	}
+
+	// offset of page is ignored by cursor page
+	page := &db.Page{Limit: 2, Offset: 4, Cursor: first.NextCursor}
+
+	c := db.NewCriteria(session, new(Product), &[]*Product{}).SetPage(page).ListAndCount()
+
+	if c.HasError {
+		t.Fatal(c.Error)
+	}
+
+	products := *c.Results.(*[]*Product)
+
+	if len(products) != 2 || products[0].Code != "p3" || len(c.NextCursor) == 0 {
+		t.Errorf("expected p3 and p4 with next cursor, got %v", len(products))
+	}
+
+	if c.Count64 != 0 {
+		t.Errorf("expected cursor page without count, got %v", c.Count64)
+	}
+}
@@ -1,7 +1,9 @@
 package db
 
 import (
-	_ "fmt"
+	"errors"
+	"fmt"
+	"regexp"
 
 	"database/sql"
 
@@ -120,6 +122,46 @@ func (this *DataBase) Rollback() (err error) {
 	return err
 }
 
+var savepointNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// DriverType return the driver type of registered db alias
+func (this *DataBase) DriverType() orm.DriverType {
+	if this.db == nil {
+		this.Open()
+	}
+	return this.db.Driver().Type()
+}
+
+func (this *DataBase) Savepoint(name string) error {
+	return this.execSavepoint("SAVEPOINT %v", name)
+}
+
+func (this *DataBase) RollbackTo(name string) error {
+	return this.execSavepoint("ROLLBACK TO SAVEPOINT %v", name)
+}
+
+func (this *DataBase) ReleaseSavepoint(name string) error {
+	// oracle does not support release savepoint, is released on commit
+	if this.DriverType() == orm.DROracle {
+		return nil
+	}
+	return this.execSavepoint("RELEASE SAVEPOINT %v", name)
+}
+
+func (this *DataBase) execSavepoint(format string, name string) error {
+
+	if this.tx == nil {
+		return errors.New("savepoint requires an open transaction")
+	}
+
+	if !savepointNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid savepoint name: %v", name)
+	}
+
+	_, err := this.tx.Raw(fmt.Sprintf(format, name)).Exec()
+	return err
+}
+
 func (this *DataBase) Raw(query string, args ...interface{}) orm.RawSeter {
 
 	if this.tx != nil {
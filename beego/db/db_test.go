@@ -0,0 +1,101 @@
+package db_test
+
+import (
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/mobilemindtech/go-utils/beego/db"
+	"github.com/mobilemindtech/go-utils/beego/dbtest"
+)
+
+type Company struct {
+	Id   int64  `json:",string,omitempty"`
+	Name string `orm:"size(50)"`
+}
+
+func (this *Company) TableName() string {
+	return "companies"
+}
+
+func (this *Company) IsPersisted() bool {
+	return this.Id > 0
+}
+
+func (this *Company) GetId() int64 {
+	return this.Id
+}
+
+type Category struct {
+	Id   int64  `json:",string,omitempty"`
+	Name string `orm:"size(50)"`
+
+	Tenant *Company `orm:"rel(fk)" goutils:"tenant"`
+
+	Products []*Product `orm:"reverse(many)" json:",omitempty"`
+}
+
+func (this *Category) TableName() string {
+	return "categories"
+}
+
+func (this *Category) IsPersisted() bool {
+	return this.Id > 0
+}
+
+type Product struct {
+	Id        int64      `json:",string,omitempty"`
+	CreatedAt time.Time  `orm:"auto_now_add;type(datetime)"`
+	DeletedAt *time.Time `orm:"null;type(datetime)" goutils:"soft_delete"`
+	Version   int64      `goutils:"version"`
+
+	Code  string  `orm:"size(20);unique"`
+	Name  string  `orm:"size(50)" goutils:"audit"`
+	Price float64 `orm:"digits(10);decimals(2)"`
+
+	Category *Category `orm:"rel(fk);null"`
+	Tenant   *Company  `orm:"rel(fk)" goutils:"tenant"`
+
+	Session *db.Session `orm:"-" json:"-"`
+}
+
+func (this *Product) TableName() string {
+	return "products"
+}
+
+func (this *Product) IsPersisted() bool {
+	return this.Id > 0
+}
+
+func TestMain(m *testing.M) {
+	if err := dbtest.Setup(new(Company), new(Category), new(Product)); err != nil {
+		log.Fatal(err)
+	}
+	os.Exit(m.Run())
+}
+
+// session of new tenant, rolled back on test cleanup
+func newTenantSession(t *testing.T, name string) (*db.Session, *Company) {
+
+	t.Helper()
+
+	session := dbtest.NewSession(t)
+	company := &Company{Name: name}
+
+	if err := session.Save(company); err != nil {
+		t.Fatal(err)
+	}
+
+	return session.SetTenant(company), company
+}
+
+func saveProducts(t *testing.T, session *db.Session, products ...*Product) {
+	t.Helper()
+	for _, it := range products {
+		if err := session.Save(it); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
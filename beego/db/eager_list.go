@@ -0,0 +1,345 @@
+package db
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/beego/beego/v2/core/logs"
+)
+
+// max ids by IN query
+const eagerListBatchSize = 500
+
+// max nested relations of Session.Eager on slices
+const eagerListMaxDepth = 5
+
+// EagerList load relations of all entities with one IN query by relation, instead of one query by row.
+// entities can be a slice, pointer to slice or pointer to entity. Nested paths are separated by __.
+// Relations are filtered by tenant and soft delete, filtered relations are kept with id only.
+// rel(fk), rel(one), reverse(one) and reverse(many) are supported, rel(m2m) returns error.
+// eg.: session.EagerList(&tenantUsers, "User", "Tenant", "User__Role")
+func (this *Session) EagerList(entities interface{}, paths ...string) error {
+
+	if entities == nil || len(paths) == 0 {
+		return nil
+	}
+
+	start := eagerListValues(reflect.ValueOf(entities))
+
+	// loaded entities by path prefix, to load nested paths once
+	loaded := map[string][]reflect.Value{}
+
+	for _, path := range paths {
+
+		current := start
+		prefix := ""
+
+		for _, name := range strings.Split(path, "__") {
+
+			if len(prefix) > 0 {
+				prefix += "__"
+			}
+			prefix += name
+
+			if values, ok := loaded[prefix]; ok {
+				current = values
+				continue
+			}
+
+			values, err := this.eagerListField(current, name)
+
+			if err != nil {
+				return fmt.Errorf("eager %v: %v", prefix, err)
+			}
+
+			loaded[prefix] = values
+			current = values
+		}
+	}
+
+	return nil
+}
+
+// load relation field of entities, returns loaded related entities
+func (this *Session) eagerListField(entities []reflect.Value, name string) ([]reflect.Value, error) {
+
+	if len(entities) == 0 {
+		return nil, nil
+	}
+
+	field, ok := entities[0].Elem().Type().FieldByName(name)
+
+	if !ok {
+		return nil, fmt.Errorf("field %v not found on %v", name, entities[0].Elem().Type())
+	}
+
+	ormTag := field.Tag.Get("orm")
+
+	switch {
+	case strings.Contains(ormTag, "rel(m2m)"):
+		return nil, fmt.Errorf("m2m field %v of %v is not supported, load it by entity with Session.Eager", name, entities[0].Elem().Type())
+	case strings.Contains(ormTag, "reverse("):
+		return this.eagerListReverse(entities, field)
+	case isEagerListRelation(field.Type):
+		return this.eagerListForeign(entities, field)
+	}
+
+	return nil, fmt.Errorf("field %v of %v is not a relation", name, entities[0].Elem().Type())
+}
+
+// load rel(fk) and rel(one) field by ids of related entities
+func (this *Session) eagerListForeign(entities []reflect.Value, field reflect.StructField) ([]reflect.Value, error) {
+
+	ids := []interface{}{}
+	seen := map[interface{}]bool{}
+
+	for _, it := range entities {
+
+		related := it.Elem().FieldByIndex(field.Index)
+
+		if related.IsNil() {
+			continue
+		}
+
+		id := related.Elem().FieldByName("Id")
+
+		if !id.IsValid() || id.IsZero() || seen[id.Interface()] {
+			continue
+		}
+
+		seen[id.Interface()] = true
+		ids = append(ids, id.Interface())
+	}
+
+	values, err := this.eagerListQuery(field.Type, "Id", ids)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if this.Debug {
+		logs.Debug("## eager list %v: %v ids, %v loaded", field.Name, len(ids), len(values))
+	}
+
+	byId := map[interface{}]reflect.Value{}
+
+	for _, item := range values {
+		byId[item.Elem().FieldByName("Id").Interface()] = item
+	}
+
+	for _, it := range entities {
+
+		related := it.Elem().FieldByIndex(field.Index)
+
+		if related.IsNil() {
+			continue
+		}
+
+		if item, ok := byId[related.Elem().FieldByName("Id").Interface()]; ok {
+			related.Set(item)
+		}
+	}
+
+	return values, nil
+}
+
+// load reverse(one) and reverse(many) field by ids of entities
+func (this *Session) eagerListReverse(entities []reflect.Value, field reflect.StructField) ([]reflect.Value, error) {
+
+	itemType := field.Type
+	many := itemType.Kind() == reflect.Slice
+
+	if many {
+		itemType = itemType.Elem()
+	}
+
+	if !isEagerListRelation(itemType) {
+		return nil, fmt.Errorf("field %v of %v is not a relation", field.Name, entities[0].Elem().Type())
+	}
+
+	fk, ok := reverseRelationField(itemType.Elem(), entities[0].Type())
+
+	if !ok {
+		return nil, fmt.Errorf("field %v of %v has no rel(fk) or rel(one) to %v", field.Name, entities[0].Elem().Type(), itemType.Elem())
+	}
+
+	ids := []interface{}{}
+	seen := map[interface{}]bool{}
+
+	for _, it := range entities {
+
+		id := it.Elem().FieldByName("Id")
+
+		if !id.IsValid() || id.IsZero() || seen[id.Interface()] {
+			continue
+		}
+
+		seen[id.Interface()] = true
+		ids = append(ids, id.Interface())
+	}
+
+	values, err := this.eagerListQuery(itemType, fk.Name, ids)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if this.Debug {
+		logs.Debug("## eager list %v: %v ids, %v loaded", field.Name, len(ids), len(values))
+	}
+
+	byParent := map[interface{}][]reflect.Value{}
+
+	for _, item := range values {
+		parent := item.Elem().FieldByIndex(fk.Index)
+		if parent.IsNil() {
+			continue
+		}
+		id := parent.Elem().FieldByName("Id").Interface()
+		byParent[id] = append(byParent[id], item)
+	}
+
+	for _, it := range entities {
+
+		id := it.Elem().FieldByName("Id")
+
+		if !id.IsValid() || id.IsZero() {
+			continue
+		}
+
+		items := byParent[id.Interface()]
+		related := it.Elem().FieldByIndex(field.Index)
+
+		if many {
+			slice := reflect.MakeSlice(field.Type, 0, len(items))
+			related.Set(reflect.Append(slice, items...))
+		} else if len(items) > 0 {
+			related.Set(items[0])
+		}
+	}
+
+	return values, nil
+}
+
+// query entities of type by column IN ids, in batches, filtered by tenant and soft delete
+func (this *Session) eagerListQuery(entityType reflect.Type, column string, ids []interface{}) ([]reflect.Value, error) {
+
+	values := []reflect.Value{}
+
+	for i := 0; i < len(ids); i += eagerListBatchSize {
+
+		end := i + eagerListBatchSize
+
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		results := reflect.New(reflect.SliceOf(entityType))
+		entity := reflect.New(entityType.Elem()).Interface()
+		query := this.GetReadDb().QueryTable(entity)
+
+		if !this.IgnoreTenantFilter {
+			query = this.setTenantFilter(entity, query)
+		}
+
+		query = this.setSoftDeleteFilter(entity, query).
+			Filter(fmt.Sprintf("%v__in", column), ids[i:end]...).
+			OrderBy("Id")
+
+		if _, err := query.All(results.Interface()); err != nil {
+			return nil, err
+		}
+
+		for j := 0; j < results.Elem().Len(); j++ {
+			values = append(values, results.Elem().Index(j))
+		}
+	}
+
+	return values, nil
+}
+
+// field of related type with rel(fk) or rel(one) to parent type
+func reverseRelationField(relatedType reflect.Type, parentType reflect.Type) (reflect.StructField, bool) {
+
+	for i := 0; i < relatedType.NumField(); i++ {
+
+		field := relatedType.Field(i)
+		ormTag := field.Tag.Get("orm")
+
+		if field.Type == parentType && (strings.Contains(ormTag, "rel(fk)") || strings.Contains(ormTag, "rel(one)")) {
+			return field, true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
+
+// pointer to struct
+func isEagerListRelation(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Ptr && typ.Elem().Kind() == reflect.Struct
+}
+
+// eager tagged relations of struct type, nested by __, up to eagerListMaxDepth
+func (this *Session) eagerListPaths(typ reflect.Type, ignoreTag bool, prefix string, depth int) []string {
+
+	paths := []string{}
+
+	if depth >= eagerListMaxDepth {
+		return paths
+	}
+
+	modelType := reflect.TypeOf((*Model)(nil)).Elem()
+
+	for i := 0; i < typ.NumField(); i++ {
+
+		field := typ.Field(i)
+		tags := this.getTags(field)
+
+		if !ignoreTag && !this.hasTag(tags, "eager") {
+			continue
+		}
+
+		if this.hasTag(tags, "ignore_eager") || !isEagerListRelation(field.Type) || !field.Type.Implements(modelType) {
+			continue
+		}
+
+		path := prefix + field.Name
+		paths = append(paths, path)
+
+		if this.hasTag(tags, "ignore_eager_child") {
+			continue
+		}
+
+		paths = append(paths, this.eagerListPaths(field.Type.Elem(), ignoreTag, path+"__", depth+1)...)
+	}
+
+	return paths
+}
+
+// pointers to struct of slice, pointer to slice or pointer to struct
+func eagerListValues(value reflect.Value) []reflect.Value {
+
+	values := []reflect.Value{}
+
+	if value.Kind() == reflect.Ptr && value.Elem().Kind() == reflect.Slice {
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			values = append(values, eagerListValues(value.Index(i))...)
+		}
+	case reflect.Ptr:
+		if !value.IsNil() && value.Elem().Kind() == reflect.Struct {
+			values = append(values, value)
+		}
+	case reflect.Interface:
+		if !value.IsNil() {
+			values = append(values, eagerListValues(value.Elem())...)
+		}
+	}
+
+	return values
+}
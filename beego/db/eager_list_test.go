@@ -0,0 +1,140 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/mobilemindtech/go-utils/beego/db"
+)
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestEagerList
+func TestEagerList(t *testing.T) {
+
+	session, _ := newTenantSession(t, "eager")
+
+	category := &Category{Name: "own"}
+
+	if err := session.Save(category); err != nil {
+		t.Fatal(err)
+	}
+
+	other := &Company{Name: "eager other"}
+
+	if err := session.Save(other); err != nil {
+		t.Fatal(err)
+	}
+
+	otherCategory := &Category{Name: "other"}
+
+	if err := db.NewSessionWithTenant(other).SetDatabase(session.GetDb()).Save(otherCategory); err != nil {
+		t.Fatal(err)
+	}
+
+	saveProducts(t, session,
+		&Product{Code: "p1", Category: category},
+		&Product{Code: "p2", Category: otherCategory})
+
+	c := db.NewCriteria(session, new(Product), &[]*Product{}).OrderAsc("Code").EagerList("Category").List()
+
+	if c.HasError {
+		t.Fatal(c.Error)
+	}
+
+	products := *c.Results.(*[]*Product)
+
+	if len(products) != 2 {
+		t.Fatalf("expected 2 products, got %v", len(products))
+	}
+
+	if products[0].Category.Name != "own" {
+		t.Errorf("expected category of tenant loaded, got %v", products[0].Category.Name)
+	}
+
+	// category of other tenant is kept with id only
+	if products[1].Category.Id != otherCategory.Id || len(products[1].Category.Name) > 0 {
+		t.Errorf("expected category of other tenant not loaded, got %v", products[1].Category.Name)
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestEagerListReverse
+func TestEagerListReverse(t *testing.T) {
+
+	session, _ := newTenantSession(t, "eager reverse")
+
+	books := &Category{Name: "books"}
+	empty := &Category{Name: "empty"}
+
+	for _, it := range []*Category{books, empty} {
+		if err := session.Save(it); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	saveProducts(t, session,
+		&Product{Code: "p1", Category: books},
+		&Product{Code: "p2", Category: books})
+
+	categories := []*Category{books, empty}
+
+	if err := session.EagerList(&categories, "Products", "Products__Tenant"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(books.Products) != 2 || books.Products[0].Code != "p1" || books.Products[1].Code != "p2" {
+		t.Fatalf("expected products of category loaded, got %v", books.Products)
+	}
+
+	if books.Products[0].Tenant == nil || len(books.Products[0].Tenant.Name) == 0 {
+		t.Errorf("expected nested tenant of products loaded")
+	}
+
+	if empty.Products == nil || len(empty.Products) != 0 {
+		t.Errorf("expected empty products, got %v", empty.Products)
+	}
+}
+
+type m2mCategory struct {
+	Id   int64
+	Tags []*Category `orm:"rel(m2m)"`
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestEagerListM2M
+func TestEagerListM2M(t *testing.T) {
+
+	session, _ := newTenantSession(t, "eager m2m")
+
+	if err := session.EagerList([]*m2mCategory{{Id: 1}}, "Tags"); err == nil {
+		t.Errorf("expected m2m not supported error")
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestEagerSlice
+func TestEagerSlice(t *testing.T) {
+
+	session, company := newTenantSession(t, "eager slice")
+
+	category := &Category{Name: "books"}
+
+	if err := session.Save(category); err != nil {
+		t.Fatal(err)
+	}
+
+	saveProducts(t, session,
+		&Product{Code: "p1", Category: category},
+		&Product{Code: "p2", Category: category})
+
+	var products []*Product
+
+	if err := db.NewCriteria(session, new(Product), &products).OrderAsc("Code").List().Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := session.EagerForce(&products); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, it := range products {
+		if it.Category.Name != "books" || it.Category.Tenant.Name != company.Name || it.Tenant.Name != company.Name {
+			t.Errorf("expected relations of %v loaded", it.Code)
+		}
+	}
+}
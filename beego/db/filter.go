@@ -0,0 +1,410 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+const (
+	filterMaxDepth = 8
+	filterMaxNodes = 100
+)
+
+var ErrFilterNotAllowed = errors.New("filter not allowed")
+
+// filterable paths of entity, used by json filter DSL. Relations are filtered by id (eg.: Customer or Customer__Id)
+type ModelFilterable interface {
+	FilterPaths() []string
+}
+
+// sortable paths of entity, to Page.Sort. If not implemented, FilterPaths is used. Models without both
+// are sorted by any path, except when json filter DSL is used
+type ModelSortable interface {
+	SortPaths() []string
+}
+
+// Filter json filter DSL. A node is a group (and, or) or a condition (path, op, value).
+// eg.: {"and":[{"path":"Name","op":"icontains","value":"x"},{"or":[{"path":"Age","op":"gte","value":18},{"path":"Admin","op":"eq","value":true}]}]}
+//
+// Operators: eq, ne, lt, lte, gt, gte, in, nin, between ([from, to]), isnull, notnull,
+// iexact, contains, icontains, startswith, istartswith, endswith, iendswith
+type Filter struct {
+	And   []*Filter   `json:"and,omitempty"`
+	Or    []*Filter   `json:"or,omitempty"`
+	Path  string      `json:"path,omitempty"`
+	Op    string      `json:"op,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+func FilterAnd(filters ...*Filter) *Filter {
+	return &Filter{And: filters}
+}
+
+func FilterOr(filters ...*Filter) *Filter {
+	return &Filter{Or: filters}
+}
+
+func FilterOf(path string, op string, value interface{}) *Filter {
+	return &Filter{Path: path, Op: op, Value: value}
+}
+
+// ParseFilter parse json filter DSL. Numbers are kept as json.Number and converted to field type
+func ParseFilter(data []byte) (*Filter, error) {
+
+	filter := new(Filter)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(filter); err != nil {
+		return nil, fmt.Errorf("invalid filter: %v", err)
+	}
+
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+func (this *Filter) IsGroup() bool {
+	return len(this.And) > 0 || len(this.Or) > 0
+}
+
+func (this *Filter) IsEmpty() bool {
+	return !this.IsGroup() && len(this.Path) == 0
+}
+
+// Validate check filter structure, depth and size. Paths are validated by criteria with entity whitelist
+func (this *Filter) Validate() error {
+	nodes := 0
+	return this.validate(0, &nodes)
+}
+
+func (this *Filter) validate(depth int, nodes *int) error {
+
+	*nodes++
+
+	if depth > filterMaxDepth {
+		return fmt.Errorf("invalid filter: max depth is %v", filterMaxDepth)
+	}
+
+	if *nodes > filterMaxNodes {
+		return fmt.Errorf("invalid filter: max conditions is %v", filterMaxNodes)
+	}
+
+	if len(this.And) > 0 && len(this.Or) > 0 {
+		return errors.New("invalid filter: use and or or on the same node")
+	}
+
+	if this.IsGroup() {
+		if len(this.Path) > 0 || len(this.Op) > 0 {
+			return errors.New("invalid filter: group can't have path or op")
+		}
+		for _, it := range append(this.And, this.Or...) {
+			if it == nil {
+				return errors.New("invalid filter: empty node")
+			}
+			if err := it.validate(depth+1, nodes); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if len(this.Path) == 0 && len(this.Op) == 0 {
+		// empty filter
+		return nil
+	}
+
+	if len(this.Path) == 0 {
+		return errors.New("invalid filter: path is required")
+	}
+
+	if _, ok := filterOperators[strings.ToLower(this.Op)]; !ok && len(this.Op) > 0 {
+		return fmt.Errorf("invalid filter: operator %v not supported", this.Op)
+	}
+
+	return nil
+}
+
+func (this *Filter) String() string {
+	data, _ := json.Marshal(this)
+	return string(data)
+}
+
+var filterOperators = map[string]*Criteria{
+	"":            {Expression: Eq},
+	"eq":          {Expression: Eq},
+	"ne":          {Expression: Ne},
+	"lt":          {Expression: Lt},
+	"lte":         {Expression: Le},
+	"gt":          {Expression: Gt},
+	"gte":         {Expression: Ge},
+	"in":          {Expression: In},
+	"nin":         {Expression: NotIn},
+	"between":     {Expression: Between},
+	"isnull":      {Expression: IsNull},
+	"notnull":     {Expression: IsNotNull},
+	"iexact":      {Expression: Like, Match: IExact},
+	"contains":    {Expression: Like, Match: Anywhare},
+	"icontains":   {Expression: Like, Match: IAnywhare},
+	"startswith":  {Expression: Like, Match: StartsWith},
+	"istartswith": {Expression: Like, Match: IStartsWith},
+	"endswith":    {Expression: Like, Match: EndsWith},
+	"iendswith":   {Expression: Like, Match: IEndsWith},
+}
+
+// Filter add json filter DSL conditions. Paths should be allowed by entity ModelFilterable
+func (this *Criteria) Filter(filter *Filter) *Criteria {
+	if filter != nil && !filter.IsEmpty() {
+		this.filters = append(this.filters, filter)
+	}
+	return this
+}
+
+// compile filters to a condition of Id, with orm condition to criteria queries and raw sql to compiled sql.
+// eg.: T0.id IS NOT NULL AND (T0.name LIKE ? OR ...)
+func (this *Criteria) buildFilters(filters []*Filter) {
+
+	if len(filters) == 0 {
+		return
+	}
+
+	filterable, ok := this.Result.(ModelFilterable)
+
+	if !ok {
+		this.SetError(fmt.Errorf("%v: %v does not implements ModelFilterable", ErrFilterNotAllowed, getTypeName(this.Result)))
+		this.Raw("Id", "IS NULL")
+		return
+	}
+
+	allowed := filterable.FilterPaths()
+	columns := getModelColumns(this.Result)
+	cond := new(sqlCondition)
+	condition := orm.NewCondition()
+
+	for _, filter := range filters {
+
+		if err := filter.Validate(); err != nil {
+			this.SetError(err)
+			this.Raw("Id", "IS NULL")
+			return
+		}
+
+		other, otherCondition, err := this.compileFilterSQL(columns, allowed, filter)
+
+		if err != nil {
+			this.SetError(err)
+			this.Raw("Id", "IS NULL")
+			return
+		}
+
+		cond.addCond(false, other)
+		if !otherCondition.IsEmpty() {
+			condition = condition.AndCond(otherCondition)
+		}
+	}
+
+	if !cond.IsEmpty() {
+		this.criterias = append(this.criterias, &Criteria{
+			Path:            "Id",
+			RawQuery:        fmt.Sprintf("IS NOT NULL AND (%v)", cond.String()),
+			RawValues:       cond.args,
+			Expression:      Raw,
+			filterCondition: condition,
+		})
+	}
+}
+
+// compile filter to sql condition and orm condition
+func (this *Criteria) compileFilterSQL(columns []*modelColumn, allowed []string, filter *Filter) (*sqlCondition, *orm.Condition, error) {
+
+	cond := new(sqlCondition)
+	condition := orm.NewCondition()
+
+	if filter.IsGroup() {
+
+		or := len(filter.Or) > 0
+		items := filter.And
+		if or {
+			items = filter.Or
+		}
+
+		for _, it := range items {
+			other, otherCondition, err := this.compileFilterSQL(columns, allowed, it)
+			if err != nil {
+				return nil, nil, err
+			}
+			cond.addCond(or, other)
+			if otherCondition.IsEmpty() {
+				continue
+			}
+			if or {
+				condition = condition.OrCond(otherCondition)
+			} else {
+				condition = condition.AndCond(otherCondition)
+			}
+		}
+
+		return cond, condition, nil
+	}
+
+	if filter.IsEmpty() {
+		return cond, condition, nil
+	}
+
+	if !isFilterPathAllowed(allowed, filter.Path) {
+		return nil, nil, fmt.Errorf("%v: path %v", ErrFilterNotAllowed, filter.Path)
+	}
+
+	// filter condition is a raw condition of main table, relations are filtered by id only
+	if strings.Contains(strings.TrimSuffix(filter.Path, "__Id"), "__") {
+		return nil, nil, fmt.Errorf("%v: path %v of relation field", ErrFilterNotAllowed, filter.Path)
+	}
+
+	column := findModelColumn(columns, strings.Split(filter.Path, "__")[0])
+
+	if column == nil {
+		return nil, nil, fmt.Errorf("%v: path %v", ErrFilterNotAllowed, filter.Path)
+	}
+
+	op := filterOperators[strings.ToLower(filter.Op)]
+	criteria := &Criteria{Path: filter.Path, Expression: op.Expression, Match: op.Match}
+
+	switch op.Expression {
+	case In, NotIn:
+		values, ok := filter.Value.([]interface{})
+		if !ok {
+			values = []interface{}{filter.Value}
+		}
+		for _, it := range values {
+			value, err := filterValue(column, it)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid filter value of %v: %v", filter.Path, err)
+			}
+			criteria.InValues = append(criteria.InValues, value)
+		}
+	case Between:
+		values, ok := filter.Value.([]interface{})
+		if !ok || len(values) != 2 {
+			return nil, nil, fmt.Errorf("invalid filter value of %v: between expects [from, to]", filter.Path)
+		}
+		from, err := filterValue(column, values[0])
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid filter value of %v: %v", filter.Path, err)
+		}
+		to, err := filterValue(column, values[1])
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid filter value of %v: %v", filter.Path, err)
+		}
+		criteria.Value, criteria.Value2 = from, to
+	case IsNull, IsNotNull:
+	case Like:
+		// like operators compare text
+		criteria.Value = fmt.Sprintf("%v", filter.Value)
+	default:
+		value, err := filterValue(column, filter.Value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid filter value of %v: %v", filter.Path, err)
+		}
+		criteria.Value = value
+	}
+
+	sql, args, not, err := this.compileCriteriaSQL(criteria)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cond.add(false, not, sql, args...), this.buildCriterias([]*Criteria{criteria}), nil
+}
+
+// check sort path with entity ModelSortable or ModelFilterable. Without whitelist, sort is allowed
+// only if json filter DSL is not used
+func (this *Criteria) isSortAllowed(path string) bool {
+	if sortable, ok := this.Result.(ModelSortable); ok {
+		return isFilterPathAllowed(sortable.SortPaths(), path)
+	}
+	if filterable, ok := this.Result.(ModelFilterable); ok {
+		return isFilterPathAllowed(filterable.FilterPaths(), path)
+	}
+	return len(this.filters) == 0 && (this.Page == nil || this.Page.Filter == nil)
+}
+
+// path is allowed if is on list. Relation allowed is allowed by id too (Customer > Customer__Id)
+func isFilterPathAllowed(allowed []string, path string) bool {
+	for _, it := range allowed {
+		if it == path || it+"__Id" == path {
+			return true
+		}
+	}
+	return false
+}
+
+// convert json value to field type. relations are converted to id
+func filterValue(column *modelColumn, value interface{}) (interface{}, error) {
+
+	if value == nil {
+		return nil, nil
+	}
+
+	typ := column.value.Type()
+
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	if column.rel {
+		typ = reflect.TypeOf(int64(0))
+	}
+
+	text := fmt.Sprintf("%v", value)
+
+	if typ == reflect.TypeOf(time.Time{}) {
+		for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, text); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid date %v", text)
+	}
+
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %v", text)
+		}
+		return v, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := strconv.ParseUint(text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %v", text)
+		}
+		return v, nil
+	case reflect.Float32, reflect.Float64:
+		v, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %v", text)
+		}
+		return v, nil
+	case reflect.Bool:
+		v, err := strconv.ParseBool(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid boolean %v", text)
+		}
+		return v, nil
+	case reflect.String:
+		return text, nil
+	}
+
+	return nil, fmt.Errorf("field %v can't be filtered", column.field)
+}
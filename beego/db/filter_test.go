@@ -0,0 +1,113 @@
+package db_test
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/mobilemindtech/go-utils/beego/db"
+)
+
+func (this *Product) FilterPaths() []string {
+	return []string{"Code", "Name", "Price", "Category", "Category__Name"}
+}
+
+func (this *Product) SortPaths() []string {
+	return []string{"Price"}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestFilter
+func TestFilter(t *testing.T) {
+
+	session, _, _ := subqueryFixtures(t)
+
+	tests := []struct {
+		name     string
+		filter   string
+		expected []string
+		err      bool
+	}{
+		{"eq with quote and placeholder", `{"path":"Name","op":"eq","value":"o'reilly ?"}`, []string{"p1"}, false},
+		{"or", `{"or":[{"path":"Code","value":"p2"},{"path":"Price","op":"gte","value":100}]}`, []string{"p2", "p3"}, false},
+		{"and of or", `{"and":[{"path":"Price","op":"lt","value":50},{"or":[{"path":"Code","value":"p1"},{"path":"Code","value":"p2"}]}]}`, []string{"p1", "p2"}, false},
+		{"in", `{"path":"Code","op":"in","value":["p1","p3"]}`, []string{"p1", "p3"}, false},
+		{"like", `{"path":"Name","op":"icontains","value":"REILLY"}`, []string{"p1"}, false},
+		{"relation", `{"path":"Category","op":"isnull"}`, []string{"p3"}, false},
+		{"path not allowed", `{"path":"Version","value":1}`, nil, true},
+		{"relation field", `{"path":"Category__Name","value":"books"}`, nil, true},
+	}
+
+	for _, it := range tests {
+		t.Run(it.name, func(t *testing.T) {
+
+			filter, err := db.ParseFilter([]byte(it.filter))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c := db.NewCriteria(session, new(Product), &[]*Product{}).Filter(filter).OrderAsc("Code").List()
+
+			if it.err {
+				if !c.HasError {
+					t.Errorf("expected error of filter")
+				}
+				return
+			}
+
+			if c.HasError {
+				t.Fatal(c.Error)
+			}
+
+			codes := []string{}
+			for _, p := range *c.Results.(*[]*Product) {
+				codes = append(codes, p.Code)
+			}
+
+			if len(codes) != len(it.expected) {
+				t.Fatalf("expected %v, got %v", it.expected, codes)
+			}
+
+			for i := range codes {
+				if codes[i] != it.expected[i] {
+					t.Errorf("expected %v, got %v", it.expected, codes)
+				}
+			}
+		})
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestPageSort
+func TestPageSort(t *testing.T) {
+
+	session, _, _ := subqueryFixtures(t)
+
+	c := db.NewCriteria(session, new(Product), &[]*Product{}).
+		SetPage(&db.Page{Sort: "Price", Order: "desc"}).
+		List()
+
+	if c.HasError {
+		t.Fatal(c.Error)
+	}
+
+	if products := *c.Results.(*[]*Product); len(products) != 3 || products[0].Code != "p3" {
+		t.Errorf("expected p3 first")
+	}
+
+	c = db.NewCriteria(session, new(Product), &[]*Product{}).SetPage(&db.Page{Sort: "Name", Order: "asc"}).List()
+
+	if !c.HasError {
+		t.Errorf("expected error of sort not allowed")
+	}
+
+	// entity without whitelist is sorted by any path
+	categories := listCategories(t, db.NewCriteria(session, new(Category), &[]*Category{}).SetPage(&db.Page{Sort: "Name", Order: "desc"}))
+
+	names := []string{}
+	for _, it := range categories {
+		names = append(names, it.Name)
+	}
+
+	if len(names) < 2 || !sort.SliceIsSorted(names, func(i, j int) bool { return names[i] > names[j] }) {
+		t.Errorf("expected categories sorted by name desc, got %v", names)
+	}
+}
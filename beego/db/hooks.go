@@ -0,0 +1,229 @@
+package db
+
+import (
+	"sync"
+)
+
+type HookOperation string
+
+const (
+	HookOperationSave   HookOperation = "save"
+	HookOperationUpdate HookOperation = "update"
+	HookOperationRemove HookOperation = "remove"
+)
+
+// SessionListener is called before or after entity save, update or remove. Return error to abort the operation
+type SessionListener func(entity interface{}, session *Session) error
+
+var (
+	globalListenersLock   sync.RWMutex
+	globalBeforeListeners = map[HookOperation][]SessionListener{}
+	globalAfterListeners  = map[HookOperation][]SessionListener{}
+)
+
+func addGlobalListener(listeners map[HookOperation][]SessionListener, op HookOperation, fn SessionListener) {
+	globalListenersLock.Lock()
+	defer globalListenersLock.Unlock()
+	listeners[op] = append(listeners[op], fn)
+}
+
+func getGlobalListeners(listeners map[HookOperation][]SessionListener, op HookOperation) []SessionListener {
+	globalListenersLock.RLock()
+	defer globalListenersLock.RUnlock()
+	return listeners[op]
+}
+
+// global listeners, called for all sessions. Should be registered on app init
+
+func OnBeforeSave(fn SessionListener) {
+	addGlobalListener(globalBeforeListeners, HookOperationSave, fn)
+}
+
+func OnBeforeUpdate(fn SessionListener) {
+	addGlobalListener(globalBeforeListeners, HookOperationUpdate, fn)
+}
+
+func OnBeforeRemove(fn SessionListener) {
+	addGlobalListener(globalBeforeListeners, HookOperationRemove, fn)
+}
+
+func OnAfterSave(fn SessionListener) {
+	addGlobalListener(globalAfterListeners, HookOperationSave, fn)
+}
+
+func OnAfterUpdate(fn SessionListener) {
+	addGlobalListener(globalAfterListeners, HookOperationUpdate, fn)
+}
+
+func OnAfterRemove(fn SessionListener) {
+	addGlobalListener(globalAfterListeners, HookOperationRemove, fn)
+}
+
+// session listeners, called only for this session, after global listeners
+
+func (this *Session) OnBeforeSave(fn SessionListener) *Session {
+	return this.addListener(true, HookOperationSave, fn)
+}
+
+func (this *Session) OnBeforeUpdate(fn SessionListener) *Session {
+	return this.addListener(true, HookOperationUpdate, fn)
+}
+
+func (this *Session) OnBeforeRemove(fn SessionListener) *Session {
+	return this.addListener(true, HookOperationRemove, fn)
+}
+
+func (this *Session) OnAfterSave(fn SessionListener) *Session {
+	return this.addListener(false, HookOperationSave, fn)
+}
+
+func (this *Session) OnAfterUpdate(fn SessionListener) *Session {
+	return this.addListener(false, HookOperationUpdate, fn)
+}
+
+func (this *Session) OnAfterRemove(fn SessionListener) *Session {
+	return this.addListener(false, HookOperationRemove, fn)
+}
+
+func (this *Session) addListener(before bool, op HookOperation, fn SessionListener) *Session {
+	if before {
+		if this.beforeListeners == nil {
+			this.beforeListeners = map[HookOperation][]SessionListener{}
+		}
+		this.beforeListeners[op] = append(this.beforeListeners[op], fn)
+	} else {
+		if this.afterListeners == nil {
+			this.afterListeners = map[HookOperation][]SessionListener{}
+		}
+		this.afterListeners[op] = append(this.afterListeners[op], fn)
+	}
+	return this
+}
+
+// run model hooks and listeners before persist.
+// order: model hook, model hook with session, model persist hook, global listeners, session listeners
+func (this *Session) runBeforeHooks(op HookOperation, entity interface{}) error {
+
+	var err error
+
+	switch op {
+	case HookOperationSave:
+		if hook, ok := entity.(ModelHookBeforeSave); ok {
+			err = hook.BeforeSave()
+		}
+		if hook, ok := entity.(ModelSessionHookBeforeSave); ok && err == nil {
+			err = hook.BeforeSaveWithSession(this)
+		}
+	case HookOperationUpdate:
+		if hook, ok := entity.(ModelHookBeforeUpdate); ok {
+			err = hook.BeforeUpdate()
+		}
+		if hook, ok := entity.(ModelSessionHookBeforeUpdate); ok && err == nil {
+			err = hook.BeforeUpdateWithSession(this)
+		}
+	case HookOperationRemove:
+		if hook, ok := entity.(ModelHookBeforeRemove); ok {
+			err = hook.BeforeRemove()
+		}
+		if hook, ok := entity.(ModelSessionHookBeforeRemove); ok && err == nil {
+			err = hook.BeforeRemoveWithSession(this)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if hook, ok := entity.(ModelSessionHookBeforePersist); ok {
+		if err := hook.BeforePersist(this, op); err != nil {
+			return err
+		}
+	}
+
+	return this.runListeners(entity, getGlobalListeners(globalBeforeListeners, op), this.beforeListeners[op])
+}
+
+// run model hooks and listeners after persist.
+// order: model hook, model hook with session, model persist hook, global listeners, session listeners
+func (this *Session) runAfterHooks(op HookOperation, entity interface{}) error {
+
+	var err error
+
+	switch op {
+	case HookOperationSave:
+		if hook, ok := entity.(ModelHookAfterSave); ok {
+			err = hook.AfterSave()
+		}
+		if hook, ok := entity.(ModelSessionHookAfterSave); ok && err == nil {
+			err = hook.AfterSaveWithSession(this)
+		}
+	case HookOperationUpdate:
+		if hook, ok := entity.(ModelHookAfterUpdate); ok {
+			err = hook.AfterUpdate()
+		}
+		if hook, ok := entity.(ModelSessionHookAfterUpdate); ok && err == nil {
+			err = hook.AfterUpdateWithSession(this)
+		}
+	case HookOperationRemove:
+		if hook, ok := entity.(ModelHookAfterRemove); ok {
+			err = hook.AfterRemove()
+		}
+		if hook, ok := entity.(ModelSessionHookAfterRemove); ok && err == nil {
+			err = hook.AfterRemoveWithSession(this)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if hook, ok := entity.(ModelSessionHookAfterPersist); ok {
+		if err := hook.AfterPersist(this, op); err != nil {
+			return err
+		}
+	}
+
+	return this.runListeners(entity, getGlobalListeners(globalAfterListeners, op), this.afterListeners[op])
+}
+
+// check if entity has any after hook or listener of operation
+func (this *Session) hasAfterHooks(op HookOperation, entity interface{}) bool {
+
+	if _, ok := entity.(ModelSessionHookAfterPersist); ok {
+		return true
+	}
+
+	switch op {
+	case HookOperationSave:
+		_, hook := entity.(ModelHookAfterSave)
+		_, sessionHook := entity.(ModelSessionHookAfterSave)
+		if hook || sessionHook {
+			return true
+		}
+	case HookOperationUpdate:
+		_, hook := entity.(ModelHookAfterUpdate)
+		_, sessionHook := entity.(ModelSessionHookAfterUpdate)
+		if hook || sessionHook {
+			return true
+		}
+	case HookOperationRemove:
+		_, hook := entity.(ModelHookAfterRemove)
+		_, sessionHook := entity.(ModelSessionHookAfterRemove)
+		if hook || sessionHook {
+			return true
+		}
+	}
+
+	return len(getGlobalListeners(globalAfterListeners, op)) > 0 || len(this.afterListeners[op]) > 0
+}
+
+func (this *Session) runListeners(entity interface{}, listeners ...[]SessionListener) error {
+	for _, items := range listeners {
+		for _, fn := range items {
+			if err := fn(entity, this); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
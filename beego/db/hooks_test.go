@@ -0,0 +1,76 @@
+package db_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mobilemindtech/go-utils/beego/db"
+)
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestSessionListeners
+func TestSessionListeners(t *testing.T) {
+
+	session, _ := newTenantSession(t, "hooks")
+
+	calls := []string{}
+
+	listener := func(name string) db.SessionListener {
+		return func(entity interface{}, s *db.Session) error {
+			if s != session {
+				t.Errorf("expected listener session")
+			}
+			calls = append(calls, name)
+			return nil
+		}
+	}
+
+	session.
+		OnBeforeSave(listener("before save")).
+		OnAfterSave(listener("after save")).
+		OnBeforeUpdate(listener("before update")).
+		OnAfterUpdate(listener("after update")).
+		OnBeforeRemove(listener("before remove")).
+		OnAfterRemove(listener("after remove"))
+
+	product := &Product{Code: "p1"}
+
+	saveProducts(t, session, product)
+
+	if err := session.Update(product); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := session.Remove(product); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"before save", "after save", "before update", "after update", "before remove", "after remove"}
+
+	if len(calls) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, calls)
+	}
+
+	for i := range expected {
+		if calls[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected, calls)
+		}
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestBeforeSaveError
+func TestBeforeSaveError(t *testing.T) {
+
+	session, _ := newTenantSession(t, "hooks error")
+
+	session.OnBeforeSave(func(entity interface{}, s *db.Session) error {
+		return errors.New("invalid product")
+	})
+
+	if err := session.Save(&Product{Code: "p1"}); err == nil || err.Error() != "invalid product" {
+		t.Fatalf("expected listener error, got %v", err)
+	}
+
+	if count, _ := session.Count(new(Product)); count != 0 {
+		t.Errorf("expected product not saved, got %v", count)
+	}
+}
@@ -0,0 +1,221 @@
+package db
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ormTagRegex = regexp.MustCompile(`^([a-z_0-9]+)\((.*)\)$`)
+
+// ModelField persisted field of a model, following beego orm naming rules. Used by Session and migrate
+type ModelField struct {
+	Name   string
+	Column string
+	// struct field index
+	Index int
+	Type  reflect.Type
+	// orm tag options, eg.: null, unique
+	Attrs map[string]bool
+	// orm tag options with value, eg.: size(50), rel(fk)
+	Values     map[string]string
+	Pk         bool
+	Auto       bool
+	AutoNow    bool
+	AutoNowAdd bool
+	Unique     bool
+	Null       bool
+	Rel        bool
+}
+
+// modelColumn model field with value of an entity
+type modelColumn struct {
+	field      string
+	column     string
+	value      reflect.Value
+	pk         bool
+	auto       bool
+	autoNow    bool
+	autoNowAdd bool
+	unique     bool
+	null       bool
+	rel        bool
+}
+
+var modelFieldsCache sync.Map
+
+// ParseOrmTag options of orm tag, without and with value. eg.: "size(50);null" > {null}, {size: 50}
+func ParseOrmTag(tag string) (map[string]bool, map[string]string) {
+	attrs := map[string]bool{}
+	values := map[string]string{}
+	for _, it := range strings.Split(tag, ";") {
+		it = strings.TrimSpace(it)
+		if len(it) == 0 {
+			continue
+		}
+		if m := ormTagRegex.FindStringSubmatch(it); m != nil {
+			values[m[1]] = m[2]
+		} else {
+			attrs[it] = true
+		}
+	}
+	return attrs, values
+}
+
+// GetModelFields fields persisted on table of model struct type. ignore `orm:"-"`, reverse and m2m relations
+func GetModelFields(typ reflect.Type) []*ModelField {
+
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	if fields, ok := modelFieldsCache.Load(typ); ok {
+		return fields.([]*ModelField)
+	}
+
+	fields := []*ModelField{}
+	timeType := reflect.TypeOf(time.Time{})
+
+	for i := 0; i < typ.NumField(); i++ {
+
+		field := typ.Field(i)
+		tag := field.Tag.Get("orm")
+
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+
+		attrs, values := ParseOrmTag(tag)
+
+		if _, ok := values["reverse"]; ok || values["rel"] == "m2m" {
+			continue
+		}
+
+		it := &ModelField{
+			Name:       field.Name,
+			Index:      i,
+			Type:       field.Type,
+			Attrs:      attrs,
+			Values:     values,
+			Pk:         attrs["pk"] || field.Name == "Id",
+			AutoNow:    attrs["auto_now"],
+			AutoNowAdd: attrs["auto_now_add"],
+			Unique:     attrs["unique"] || values["rel"] == "one",
+			Null:       attrs["null"],
+			Rel:        values["rel"] == "fk" || values["rel"] == "one",
+		}
+
+		it.Auto = it.Pk && (attrs["auto"] || field.Name == "Id")
+
+		// struct field that is not time or relation is not a column
+		switch fieldType := field.Type; fieldType.Kind() {
+		case reflect.Interface, reflect.Func, reflect.Chan, reflect.Map:
+			continue
+		case reflect.Slice:
+			if fieldType.Elem().Kind() != reflect.Uint8 {
+				continue
+			}
+		case reflect.Struct:
+			if !it.Rel && fieldType != timeType {
+				continue
+			}
+		case reflect.Ptr:
+			if fieldType.Elem().Kind() == reflect.Struct && !it.Rel && fieldType.Elem() != timeType {
+				continue
+			}
+		}
+
+		if column, ok := values["column"]; ok {
+			it.Column = column
+		} else if it.Rel {
+			it.Column = SnakeString(field.Name) + "_id"
+		} else {
+			it.Column = SnakeString(field.Name)
+		}
+
+		fields = append(fields, it)
+	}
+
+	modelFieldsCache.Store(typ, fields)
+
+	return fields
+}
+
+// model columns with values of entity
+func getModelColumns(entity interface{}) []*modelColumn {
+
+	fullValue := reflect.ValueOf(entity)
+
+	if fullValue.Kind() == reflect.Ptr {
+		fullValue = fullValue.Elem()
+	}
+
+	columns := []*modelColumn{}
+
+	for _, it := range GetModelFields(fullValue.Type()) {
+		columns = append(columns, &modelColumn{
+			field:      it.Name,
+			column:     it.Column,
+			value:      fullValue.Field(it.Index),
+			pk:         it.Pk,
+			auto:       it.Auto,
+			autoNow:    it.AutoNow,
+			autoNowAdd: it.AutoNowAdd,
+			unique:     it.Unique,
+			null:       it.Null,
+			rel:        it.Rel,
+		})
+	}
+
+	return columns
+}
+
+// value to write on database. relations are written by id
+func (this *modelColumn) dbValue() interface{} {
+
+	if this.value.Kind() == reflect.Ptr {
+
+		if this.value.IsNil() {
+			return nil
+		}
+
+		if this.rel {
+			id := this.value.Elem().FieldByName("Id")
+			if id.IsValid() {
+				return id.Interface()
+			}
+		}
+
+		return this.value.Elem().Interface()
+	}
+
+	return this.value.Interface()
+}
+
+func findModelColumn(columns []*modelColumn, name string) *modelColumn {
+	for _, it := range columns {
+		if it.field == name || it.column == name {
+			return it
+		}
+	}
+	return nil
+}
+
+// SnakeString same of beego orm snakeString. eg.: EntityId > entity_id
+func SnakeString(s string) string {
+	data := make([]byte, 0, len(s)*2)
+	j := false
+	for i := 0; i < len(s); i++ {
+		d := s[i]
+		if i > 0 && d >= 'A' && d <= 'Z' && j {
+			data = append(data, '_')
+		}
+		if d != '_' {
+			j = true
+		}
+		data = append(data, d)
+	}
+	return strings.ToLower(string(data))
+}
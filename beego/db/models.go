@@ -57,4 +57,38 @@ type ModelHookAfterList interface {
 
 type TenantModel interface {
   GetId() int64
-}
\ No newline at end of file
+}
+
+// hooks with session, called after hooks without session
+type ModelSessionHookBeforeSave interface {
+  BeforeSaveWithSession(session *Session) error
+}
+
+type ModelSessionHookBeforeUpdate interface {
+  BeforeUpdateWithSession(session *Session) error
+}
+
+type ModelSessionHookBeforeRemove interface {
+  BeforeRemoveWithSession(session *Session) error
+}
+
+type ModelSessionHookAfterSave interface {
+  AfterSaveWithSession(session *Session) error
+}
+
+type ModelSessionHookAfterUpdate interface {
+  AfterUpdateWithSession(session *Session) error
+}
+
+type ModelSessionHookAfterRemove interface {
+  AfterRemoveWithSession(session *Session) error
+}
+
+// hooks with session and operation kind, called on save, update and remove
+type ModelSessionHookBeforePersist interface {
+  BeforePersist(session *Session, operation HookOperation) error
+}
+
+type ModelSessionHookAfterPersist interface {
+  AfterPersist(session *Session, operation HookOperation) error
+}
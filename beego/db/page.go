@@ -1,6 +1,7 @@
 package db
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 )
@@ -11,6 +12,13 @@ type Page struct {
   Search	 string
   Order string
   Sort string  
+  // keyset pagination cursor, see Criteria.AfterCursor
+  Cursor string
+  // keyset pagination, with empty Cursor to first page
+  CursorMode bool
+  // json filter DSL, see Filter
+  Filter *Filter
+  filterErr error
   FilterColumns map[string]interface{} 
   AndFilterColumns map[string]interface{}
   TenantColumnFilter map[string]interface{}
@@ -20,6 +28,22 @@ func NewPage(offset int64, limit int64, sort string, order string) *Page{
 	return &Page{ Offset: offset, Limit: limit, Sort: sort, Order: order }
 }
 
+// SetFilterJSON parse json filter DSL. parse error is returned by criteria execution
+func (this *Page) SetFilterJSON(data []byte) *Page {
+	this.Filter, this.filterErr = ParseFilter(data)
+	return this
+}
+
+// SetFilterValue set json filter DSL of decoded json value (map or array)
+func (this *Page) SetFilterValue(value interface{}) *Page {
+	data, err := json.Marshal(value)
+	if err != nil {
+		this.filterErr = fmt.Errorf("invalid filter: %v", err)
+		return this
+	}
+	return this.SetFilterJSON(data)
+}
+
 /* deprecated */
 func (this *Page) AddFilter(columnName string, value interface{}) *Page{
 	
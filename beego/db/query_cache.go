@@ -0,0 +1,562 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/beego/beego/v2/core/logs"
+	"github.com/mobilemindtech/go-utils/cache"
+	"github.com/mobilemindtech/go-utils/support"
+	"github.com/mobilemindtech/go-utils/v2/optional"
+)
+
+var queryCache *cache.CacheService
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// cached rows, encoded without json tags, so fields with json:"-" are cached too
+type cachedQuery struct {
+	Rows  []json.RawMessage `json:"rows"`
+	Count int64             `json:"count"`
+	Any   bool              `json:"any"`
+}
+
+// SetQueryCache enable Criteria.Cached
+func SetQueryCache(srv *cache.CacheService) {
+	queryCache = srv
+}
+
+func GetQueryCache() *cache.CacheService {
+	return queryCache
+}
+
+// Cached cache results of List, One, Count and Exists for ttl. The cache is invalidated when any table of query
+// is written by Session Save, Update, Remove and Criteria Update, Delete. Requires db.SetQueryCache.
+// Eager relations and after hooks are not cached, they run on each cache hit
+func (this *Criteria) Cached(ttl time.Duration) *Criteria {
+	this.cacheTTL = ttl
+	return this
+}
+
+// cache key of entity type, conditions, tenant, ordering and paging. returns empty if cache is disabled.
+// The key has the generation of each table of query, so a write on a related table invalidates it too
+func (this *Criteria) getQueryCacheKey(resultType CriteriaResult) string {
+
+	if this.cacheTTL <= 0 || queryCache == nil || queryCache.IsCacheDisabled() {
+		return ""
+	}
+
+	switch resultType {
+	case CriteriaList, CriteriaOne, CriteriaCount, CriteriaExists:
+	default:
+		return ""
+	}
+
+	name := getModelTableName(this.Result)
+	tables := this.getQueryCacheTables()
+	gens := []string{}
+
+	for _, table := range tables {
+
+		// uncommitted writes of transaction should not be cached
+		if this.Session.queryCacheTouched[table] {
+			return ""
+		}
+
+		gen, err := queryCache.GetInt64(getQueryCacheGenKey(table))
+
+		if err != nil {
+			logs.Error("query cache error: %v", err)
+			return ""
+		}
+
+		gens = append(gens, fmt.Sprint(gen))
+	}
+
+	return fmt.Sprintf("criteria_%v_%v_%v", name, strings.Join(gens, "."),
+		support.TextToSha1(this.describeQueryCache(name, resultType)))
+}
+
+// tables of query: entity table first, then tables of relations, subqueries and joined paths
+func (this *Criteria) getQueryCacheTables() []string {
+
+	name := getModelTableName(this.Result)
+	found := map[string]bool{}
+
+	this.collectQueryCacheTables(found)
+	delete(found, name)
+
+	tables := []string{}
+	for table := range found {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	return append([]string{name}, tables...)
+}
+
+func (this *Criteria) collectQueryCacheTables(found map[string]bool) {
+
+	found[getModelTableName(this.Result)] = true
+
+	paths := []string{}
+	paths = append(paths, this.RelatedSelList...)
+	paths = append(paths, this.eagerPaths...)
+	paths = append(paths, this.searchPaths...)
+
+	for _, it := range this.orderBy {
+		if !it.IsRaw {
+			paths = append(paths, it.Path)
+		}
+	}
+
+	var filterPaths func(filter *Filter)
+	filterPaths = func(filter *Filter) {
+		if filter == nil {
+			return
+		}
+		if len(filter.Path) > 0 {
+			paths = append(paths, filter.Path)
+		}
+		for _, it := range append(filter.And, filter.Or...) {
+			filterPaths(it)
+		}
+	}
+
+	for _, it := range this.filters {
+		filterPaths(it)
+	}
+
+	if this.Page != nil {
+		filterPaths(this.Page.Filter)
+	}
+
+	var criteriaPaths func(criterias []*Criteria)
+	criteriaPaths = func(criterias []*Criteria) {
+		for _, it := range criterias {
+			if len(it.Path) > 0 {
+				paths = append(paths, it.Path)
+			}
+			criteriaPaths(it.criterias)
+			if it.Subquery != nil {
+				it.Subquery.collectQueryCacheTables(found)
+			}
+		}
+	}
+
+	criteriaPaths(this.criterias)
+	criteriaPaths(this.criteriasOr)
+	criteriaPaths(this.criteriasAnd)
+	criteriaPaths(this.criteriasAndOr)
+	criteriaPaths(this.criteriasOrAnd)
+
+	for _, set := range this.criteriasAndOrAnd {
+		criteriaPaths(set.Criterias)
+	}
+
+	for _, path := range paths {
+		collectRelationTables(this.Result, path, found)
+	}
+}
+
+// tables of relations of path. eg.: Category__Tenant__Name
+func collectRelationTables(entity interface{}, path string, found map[string]bool) {
+
+	current := reflect.TypeOf(entity)
+
+	for _, name := range strings.Split(path, "__") {
+
+		if current.Kind() == reflect.Ptr {
+			current = current.Elem()
+		}
+
+		if current.Kind() != reflect.Struct {
+			return
+		}
+
+		field, ok := current.FieldByName(name)
+
+		if !ok {
+			return
+		}
+
+		current = field.Type
+
+		if current.Kind() == reflect.Slice {
+			current = current.Elem()
+		}
+
+		if current.Kind() == reflect.Ptr {
+			current = current.Elem()
+		}
+
+		if current.Kind() != reflect.Struct || current == timeType {
+			return
+		}
+
+		model, ok := reflect.New(current).Interface().(Model)
+
+		if !ok {
+			return
+		}
+
+		found[model.TableName()] = true
+	}
+}
+
+// description of query, hashed on cache key
+func (this *Criteria) describeQueryCache(name string, resultType CriteriaResult) string {
+
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "type=%v;tenant=%v;result=%v;", name, this.getQueryCacheTenant(), resultType)
+	fmt.Fprintf(&sb, "ignoreTenant=%v;ignoreDeleted=%v;", this.Session.IgnoreTenantFilter, this.Session.IgnoreSoftDeleteFilter)
+	fmt.Fprintf(&sb, "limit=%v;offset=%v;distinct=%v;deleted=%v;cursor=%v;", this.Limit, this.Offset, this.Distinct, this.softDeleteFilter, this.cursor)
+	fmt.Fprintf(&sb, "related=%v;eager=%v;search=%v:%v;", this.RelatedSelList, this.eagerPaths, this.searchPaths, this.searchValue)
+	fmt.Fprintf(&sb, "group=%v;aggregate=%v;", this.groupBy, this.aggregate)
+
+	for _, it := range this.orderBy {
+		fmt.Fprintf(&sb, "order=%v:%v:%v;", it.Path, it.Desc, it.IsRaw)
+	}
+
+	if this.Page != nil {
+		fmt.Fprintf(&sb, "page=%v:%v:%v:%v:%v;", this.Page.Sort, this.Page.Order, this.Page.Search, this.Page.Cursor, len(this.Page.FilterColumns))
+		describeQueryCacheMap(&sb, this.Page.FilterColumns)
+		describeQueryCacheMap(&sb, this.Page.AndFilterColumns)
+		describeQueryCacheMap(&sb, this.Page.TenantColumnFilter)
+		if this.Page.Filter != nil {
+			fmt.Fprintf(&sb, "filter=%v;", this.Page.Filter)
+		}
+	}
+
+	for _, it := range this.filters {
+		fmt.Fprintf(&sb, "filter=%v;", it)
+	}
+
+	describeQueryCacheCriterias(&sb, "and", this.criterias)
+	describeQueryCacheCriterias(&sb, "or", this.criteriasOr)
+	describeQueryCacheCriterias(&sb, "and2", this.criteriasAnd)
+	describeQueryCacheCriterias(&sb, "andor", this.criteriasAndOr)
+	describeQueryCacheCriterias(&sb, "orand", this.criteriasOrAnd)
+
+	for _, set := range this.criteriasAndOrAnd {
+		describeQueryCacheCriterias(&sb, "andorand", set.Criterias)
+	}
+
+	return sb.String()
+}
+
+func (this *Criteria) getQueryCacheTenant() interface{} {
+	if !this.Session.HasTenant() {
+		return nil
+	}
+	if tenant, ok := this.Session.Tenant.(TenantModel); ok {
+		return tenant.GetId()
+	}
+	return fmt.Sprintf("%+v", this.Session.Tenant)
+}
+
+func describeQueryCacheCriterias(sb *strings.Builder, group string, criterias []*Criteria) {
+	for _, it := range criterias {
+		fmt.Fprintf(sb, "%v(%v:%v:%v:%#v:%#v:%#v:%v:%#v:%v:%v)", group, it.Path, it.Expression, it.Match,
+			describeQueryCacheValue(it.Value), describeQueryCacheValue(it.Value2), it.InValues, it.RawQuery, it.RawValues, it.ForceAnd, it.ForceOr)
+		describeQueryCacheCriterias(sb, group+".sub", it.criterias)
+		if it.Subquery != nil {
+			fmt.Fprintf(sb, "subquery(%v:%v:%v:%v)", getModelTableName(it.Subquery.Result), it.Expression, it.Subquery.selectPath, it.Subquery.softDeleteFilter)
+			describeQueryCacheCriterias(sb, group+".subquery", it.Subquery.criterias)
+		}
+	}
+}
+
+// relations are described by id
+func describeQueryCacheValue(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	if _, ok := value.(Model); ok {
+		v := reflect.ValueOf(value)
+		if v.Kind() == reflect.Ptr && !v.IsNil() {
+			if id := v.Elem().FieldByName("Id"); id.IsValid() {
+				return id.Interface()
+			}
+		}
+	}
+	return value
+}
+
+func describeQueryCacheMap(sb *strings.Builder, values map[string]interface{}) {
+	keys := []string{}
+	for k := range values {
+		keys = append(keys, k)
+	}
+	// map order is random
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(sb, "%v=%#v;", k, describeQueryCacheValue(values[k]))
+	}
+}
+
+// load cached results. returns false if not cached
+func (this *Criteria) loadQueryCache(key string, resultType CriteriaResult) bool {
+
+	cached := new(cachedQuery)
+
+	if _, ok := queryCache.Get(key, cached).(*optional.Some); !ok {
+		return false
+	}
+
+	switch resultType {
+	case CriteriaList:
+
+		results := reflect.ValueOf(this.Results).Elem()
+		items := reflect.MakeSlice(results.Type(), len(cached.Rows), len(cached.Rows))
+
+		for i, row := range cached.Rows {
+			if err := decodeCacheValue(row, items.Index(i)); err != nil {
+				logs.Error("query cache decode error: %v", err)
+				return false
+			}
+		}
+
+		results.Set(items)
+
+	case CriteriaOne:
+
+		if cached.Any && len(cached.Rows) > 0 {
+			if err := decodeCacheValue(cached.Rows[0], reflect.ValueOf(this.Result).Elem()); err != nil {
+				logs.Error("query cache decode error: %v", err)
+				return false
+			}
+		}
+
+	case CriteriaCount, CriteriaExists:
+		this.Count64 = cached.Count
+		this.Count32 = int(cached.Count)
+	}
+
+	if this.Debug {
+		logs.Debug("## criteria result from cache: %v", key)
+	}
+
+	this.Any = cached.Any
+	this.Empty = !this.Any
+	this.SetError(nil)
+	return true
+}
+
+func (this *Criteria) putQueryCache(key string, resultType CriteriaResult) {
+
+	if len(key) == 0 || this.HasError {
+		return
+	}
+
+	cached := &cachedQuery{Any: this.Any, Count: this.Count64}
+
+	var values []reflect.Value
+
+	switch resultType {
+	case CriteriaList:
+		results := reflect.ValueOf(this.Results).Elem()
+		for i := 0; i < results.Len(); i++ {
+			values = append(values, results.Index(i))
+		}
+	case CriteriaOne:
+		if this.Any {
+			values = append(values, reflect.ValueOf(this.Result))
+		}
+	}
+
+	for _, it := range values {
+		row, err := json.Marshal(encodeCacheValue(it, 0))
+		if err != nil {
+			logs.Error("query cache encode error: %v", err)
+			return
+		}
+		cached.Rows = append(cached.Rows, row)
+	}
+
+	queryCache.NewExpiresMill(int(this.cacheTTL/time.Millisecond)).Put(key, cached)
+}
+
+// invalidate all cached queries of entity type
+func (this *Session) invalidateQueryCache(entity interface{}) {
+
+	if queryCache == nil || queryCache.IsCacheDisabled() {
+		return
+	}
+
+	name := getModelTableName(entity)
+
+	if _, err := queryCache.Incr(getQueryCacheGenKey(name)); err != nil {
+		logs.Error("query cache invalidate error: %v", err)
+	}
+
+	// invalidate again after commit, other sessions can cache old data before commit
+	if this.tx {
+		if this.queryCacheTouched == nil {
+			this.queryCacheTouched = map[string]bool{}
+		}
+		this.queryCacheTouched[name] = true
+	}
+}
+
+// invalidate tables written by transaction, after commit
+func (this *Session) invalidateQueryCacheTouched() {
+
+	if queryCache != nil && !queryCache.IsCacheDisabled() {
+		for name := range this.queryCacheTouched {
+			if _, err := queryCache.Incr(getQueryCacheGenKey(name)); err != nil {
+				logs.Error("query cache invalidate error: %v", err)
+			}
+		}
+	}
+
+	this.queryCacheTouched = nil
+}
+
+// table name of model, or type name if entity is not a Model
+func getModelTableName(entity interface{}) string {
+	if model, ok := entity.(Model); ok {
+		return model.TableName()
+	}
+	return getTypeName(entity)
+}
+
+func getQueryCacheGenKey(name string) string {
+	return fmt.Sprintf("criteria_gen_%v", name)
+}
+
+func isCacheStructType(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != timeType &&
+		!t.Implements(jsonMarshalerType) && !reflect.PtrTo(t).Implements(jsonMarshalerType)
+}
+
+// encode value ignoring json tags. relations are encoded recursively
+func encodeCacheValue(value reflect.Value, depth int) interface{} {
+
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			return nil
+		}
+		if isCacheStructType(value.Type().Elem()) {
+			return encodeCacheStruct(value.Elem(), depth)
+		}
+		return value.Interface()
+	case reflect.Struct:
+		if isCacheStructType(value.Type()) {
+			return encodeCacheStruct(value, depth)
+		}
+		return value.Interface()
+	case reflect.Slice:
+		if value.IsNil() || value.Type().Elem().Kind() == reflect.Uint8 {
+			return value.Interface()
+		}
+		items := []interface{}{}
+		for i := 0; i < value.Len(); i++ {
+			items = append(items, encodeCacheValue(value.Index(i), depth))
+		}
+		return items
+	case reflect.Interface, reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return nil
+	}
+
+	return value.Interface()
+}
+
+func encodeCacheStruct(value reflect.Value, depth int) interface{} {
+
+	// avoid cyclic relations
+	if depth > 5 {
+		return nil
+	}
+
+	data := map[string]interface{}{}
+	fullType := value.Type()
+
+	for i := 0; i < fullType.NumField(); i++ {
+		field := fullType.Field(i)
+
+		if !field.IsExported() || field.Tag.Get("orm") == "-" {
+			continue
+		}
+
+		switch field.Type.Kind() {
+		case reflect.Interface, reflect.Func, reflect.Chan, reflect.UnsafePointer:
+			continue
+		}
+
+		data[field.Name] = encodeCacheValue(value.Field(i), depth+1)
+	}
+
+	return data
+}
+
+func decodeCacheValue(raw json.RawMessage, value reflect.Value) error {
+
+	if string(raw) == "null" {
+		value.Set(reflect.Zero(value.Type()))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr:
+		if isCacheStructType(value.Type().Elem()) {
+			item := reflect.New(value.Type().Elem())
+			if err := decodeCacheStruct(raw, item.Elem()); err != nil {
+				return err
+			}
+			value.Set(item)
+			return nil
+		}
+	case reflect.Struct:
+		if isCacheStructType(value.Type()) {
+			return decodeCacheStruct(raw, value)
+		}
+	case reflect.Slice:
+		if value.Type().Elem().Kind() != reflect.Uint8 {
+			var items []json.RawMessage
+			if err := json.Unmarshal(raw, &items); err != nil {
+				return err
+			}
+			slice := reflect.MakeSlice(value.Type(), len(items), len(items))
+			for i, it := range items {
+				if err := decodeCacheValue(it, slice.Index(i)); err != nil {
+					return err
+				}
+			}
+			value.Set(slice)
+			return nil
+		}
+	}
+
+	return json.Unmarshal(raw, value.Addr().Interface())
+}
+
+func decodeCacheStruct(raw json.RawMessage, value reflect.Value) error {
+
+	var data map[string]json.RawMessage
+
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return err
+	}
+
+	for name, it := range data {
+		field := value.FieldByName(name)
+		if !field.IsValid() || !field.CanSet() {
+			continue
+		}
+		if err := decodeCacheValue(it, field); err != nil {
+			return fmt.Errorf("field %v: %v", name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mobilemindtech/go-utils/cache"
+)
+
+type cachedEntity struct {
+	Id int64
+}
+
+func (this *cachedEntity) TableName() string {
+	return "cached_entities"
+}
+
+func (this *cachedEntity) IsPersisted() bool {
+	return this.Id > 0
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestDescribeQueryCache
+func TestDescribeQueryCache(t *testing.T) {
+
+	describe := func(configure func(s *Session)) string {
+		session := NewSession()
+		configure(session)
+		c := NewCriteria(session, new(cachedEntity), &[]*cachedEntity{}).Eq("Id", 1)
+		return c.describeQueryCache("cached_entities", CriteriaList)
+	}
+
+	base := describe(func(s *Session) {})
+
+	tests := []struct {
+		name      string
+		configure func(s *Session)
+	}{
+		{"ignore tenant filter", func(s *Session) { s.IgnoreTenantFilter = true }},
+		{"ignore soft delete filter", func(s *Session) { s.IgnoreSoftDeleteFilter = true }},
+	}
+
+	for _, it := range tests {
+		t.Run(it.name, func(t *testing.T) {
+			if describe(it.configure) == base {
+				t.Errorf("expected query cache key of %v different", it.name)
+			}
+		})
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestInvalidateDisabledQueryCache
+func TestInvalidateDisabledQueryCache(t *testing.T) {
+
+	SetQueryCache(cache.New(0))
+	defer SetQueryCache(nil)
+
+	session := NewSession()
+	session.tx = true
+
+	session.invalidateQueryCache(new(cachedEntity))
+
+	if len(session.queryCacheTouched) > 0 {
+		t.Errorf("expected disabled query cache not invalidated")
+	}
+}
+
+type cachedCategory struct {
+	Id   int64
+	Name string
+}
+
+func (this *cachedCategory) TableName() string {
+	return "cached_categories"
+}
+
+func (this *cachedCategory) IsPersisted() bool {
+	return this.Id > 0
+}
+
+type cachedProduct struct {
+	Id       int64
+	Category *cachedCategory
+	Items    []*cachedEntity
+}
+
+func (this *cachedProduct) TableName() string {
+	return "cached_products"
+}
+
+func (this *cachedProduct) IsPersisted() bool {
+	return this.Id > 0
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestQueryCacheTables
+func TestQueryCacheTables(t *testing.T) {
+
+	session := NewSession()
+
+	tests := []struct {
+		name     string
+		criteria *Criteria
+		expected string
+	}{
+		{"entity", NewCriteria(session, new(cachedProduct), nil).Eq("Id", 1), "[cached_products]"},
+		{"condition path", NewCriteria(session, new(cachedProduct), nil).Eq("Category__Name", "a"), "[cached_products cached_categories]"},
+		{"related", NewCriteria(session, new(cachedProduct), nil).SetRelatedSel("Category"), "[cached_products cached_categories]"},
+		{"eager", NewCriteria(session, new(cachedProduct), nil).EagerList("Category"), "[cached_products cached_categories]"},
+		{"order", NewCriteria(session, new(cachedProduct), nil).OrderAsc("Items__Id"), "[cached_products cached_entities]"},
+		{"subquery", NewCriteria(session, new(cachedProduct), nil).
+			InSubquery("Id", NewCriteria(session, new(cachedEntity), nil).Eq("Id", 1)), "[cached_products cached_entities]"},
+	}
+
+	for _, it := range tests {
+		t.Run(it.name, func(t *testing.T) {
+			if tables := fmt.Sprint(it.criteria.getQueryCacheTables()); tables != it.expected {
+				t.Errorf("expected tables %v, got %v", it.expected, tables)
+			}
+		})
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestQueryCacheRollback
+func TestQueryCacheRollback(t *testing.T) {
+
+	SetQueryCache(cache.New())
+	defer SetQueryCache(nil)
+
+	session := NewSession()
+	session.tx = true
+	session.queryCacheTouched = map[string]bool{"cached_categories": true}
+
+	c := NewCriteria(session, new(cachedProduct), &[]*cachedProduct{}).Eq("Category__Name", "a").Cached(time.Minute)
+
+	if len(c.getQueryCacheKey(CriteriaList)) > 0 {
+		t.Errorf("expected no query cache after write of transaction")
+	}
+
+	session.Rollback()
+
+	if len(session.queryCacheTouched) > 0 {
+		t.Errorf("expected touched tables cleared on rollback")
+	}
+}
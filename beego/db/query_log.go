@@ -0,0 +1,498 @@
+package db
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/core/logs"
+)
+
+const redactedValue = "***"
+
+// QueryEvent executed query. Events of session queries have Operation, Table, Rows and TenantId, SQL and Args
+// of criteria (compiled sql) and raw queries. Events of QueryLogConfig.LogSQL have SQL, Args, Caller and TenantId
+// (value of tenant_id column). Rows is -1 when unknown
+type QueryEvent struct {
+	Alias     string
+	Operation string
+	Table     string
+	SQL       string
+	Args      []string
+	Duration  time.Duration
+	Rows      int64
+	TenantId  interface{}
+	// caller of slow query and of LogSQL events
+	Caller string
+	Err    error
+	Slow   bool
+}
+
+type QuerySink interface {
+	Query(event *QueryEvent)
+}
+
+type QuerySinkFunc func(event *QueryEvent)
+
+func (this QuerySinkFunc) Query(event *QueryEvent) {
+	this(event)
+}
+
+// LogQuerySink log queries with logs.Debug
+var LogQuerySink = QuerySinkFunc(func(event *QueryEvent) {
+	if len(event.SQL) > 0 {
+		logs.Debug("## sql [%v] %v %v - %v", event.Duration, event.SQL, event.Args, event.Err)
+	} else {
+		logs.Debug("## query [%v] %v %v - %v rows - tenant %v - %v", event.Duration, event.Operation, event.Table, event.Rows, event.TenantId, event.Err)
+	}
+})
+
+type QueryLogConfig struct {
+	Sink QuerySink
+	// queries slower than threshold are logged with logs.Warn. zero disable
+	SlowThreshold time.Duration
+	// same sql (normalized, or operation and table) executed at least N times on scope is reported as N+1 suspicion. default is 5
+	NPlusOneThreshold int
+	// send sql of all orm queries to Sink. beego orm reports sql only in debug mode, so orm.Debug is enabled
+	LogSQL bool
+	// redact all args of sql, not only redacted columns
+	RedactAllArgs bool
+	// keep beego orm debug log on stdout, with LogSQL
+	OrmVerbose bool
+}
+
+var (
+	queryLogConfig *QueryLogConfig
+	redactedCols   = map[string]bool{}
+	redactedColsMu sync.RWMutex
+	// orm debug state before LogSQL, restored by DisableQueryLog
+	ormDebugSaved   bool
+	ormDebug        bool
+	ormDebugLog     = orm.DebugLog
+	ormDebugLogFunc func(query map[string]interface{})
+)
+
+// sql of session query, with args
+type querySQL struct {
+	sql  string
+	args []interface{}
+}
+
+// EnableQueryLog instrument queries of session methods and criteria. Should be called on app init,
+// before open sessions. eg.: db.EnableQueryLog(&db.QueryLogConfig{Sink: db.LogQuerySink, SlowThreshold: time.Second})
+func EnableQueryLog(config *QueryLogConfig) {
+
+	if config.NPlusOneThreshold <= 0 {
+		config.NPlusOneThreshold = 5
+	}
+
+	DisableQueryLog()
+
+	queryLogConfig = config
+
+	if !config.LogSQL {
+		return
+	}
+
+	ormDebugSaved, ormDebug, ormDebugLog, ormDebugLogFunc = true, orm.Debug, orm.DebugLog, orm.LogFunc
+
+	orm.Debug = true
+
+	if !config.OrmVerbose {
+		orm.DebugLog = orm.NewLog(io.Discard)
+	} else {
+		orm.DebugLog = orm.NewLog(os.Stdout)
+	}
+
+	previous := ormDebugLogFunc
+
+	orm.LogFunc = func(query map[string]interface{}) {
+		onOrmQuery(query)
+		if previous != nil {
+			previous(query)
+		}
+	}
+}
+
+// DisableQueryLog stop instrument queries. orm debug state changed by LogSQL is restored
+func DisableQueryLog() {
+
+	queryLogConfig = nil
+
+	if ormDebugSaved {
+		orm.Debug, orm.DebugLog, orm.LogFunc = ormDebug, ormDebugLog, ormDebugLogFunc
+		ormDebugSaved = false
+	}
+}
+
+func IsQueryLogEnabled() bool {
+	return queryLogConfig != nil
+}
+
+// RedactColumns args of columns are logged as ***
+func RedactColumns(columns ...string) {
+	redactedColsMu.Lock()
+	defer redactedColsMu.Unlock()
+	for _, it := range columns {
+		redactedCols[strings.ToLower(it)] = true
+	}
+}
+
+// RedactModels redact columns of fields with tag `goutils:"redact"`. eg.: Password string `goutils:"redact"`
+func RedactModels(models ...interface{}) {
+	for _, model := range models {
+		typ := reflect.TypeOf(model)
+		if typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		for _, column := range getModelColumns(model) {
+			if field, ok := typ.FieldByName(column.field); ok {
+				for _, tag := range strings.Split(field.Tag.Get("goutils"), ";") {
+					if strings.TrimSpace(tag) == "redact" {
+						RedactColumns(column.column)
+					}
+				}
+			}
+		}
+	}
+}
+
+// QueryStats summary of queries of a session scope
+type QueryStats struct {
+	Count    int
+	Slow     int
+	Errors   int
+	Duration time.Duration
+	// executions by normalized sql, or by operation and table without sql. eg.: insert products
+	Repeated map[string]int
+}
+
+// NPlusOne queries executed at least threshold times, ordered by count
+func (this *QueryStats) NPlusOne(threshold int) []string {
+	queries := []string{}
+	for query, count := range this.Repeated {
+		if count >= threshold {
+			queries = append(queries, query)
+		}
+	}
+	sort.Slice(queries, func(i, j int) bool {
+		return this.Repeated[queries[i]] > this.Repeated[queries[j]]
+	})
+	return queries
+}
+
+func (this *QueryStats) String() string {
+	return fmt.Sprintf("%v queries, %v db time, %v slow, %v errors", this.Count, this.Duration, this.Slow, this.Errors)
+}
+
+// BeginQueryScope collect stats of queries of session, until EndQueryScope
+func (this *Session) BeginQueryScope() *Session {
+	if queryLogConfig != nil {
+		this.queryStats = &QueryStats{Repeated: map[string]int{}}
+	}
+	return this
+}
+
+// EndQueryScope returns the stats of session scope, nil if there is no scope
+func (this *Session) EndQueryScope() *QueryStats {
+	stats := this.queryStats
+	this.queryStats = nil
+	return stats
+}
+
+// LogQueryStats log request summary and N+1 suspicions
+func LogQueryStats(name string, stats *QueryStats) {
+
+	if stats == nil || stats.Count == 0 || queryLogConfig == nil {
+		return
+	}
+
+	logs.Info("## sql summary %v: %v", name, stats)
+
+	for _, it := range stats.NPlusOne(queryLogConfig.NPlusOneThreshold) {
+		logs.Warn("## sql N+1 suspicion %v: %v times: %v", name, stats.Repeated[it], it)
+	}
+}
+
+// observe query of entity started at start
+func (this *Session) observeQuery(operation string, entity interface{}, start time.Time, rows int64, err error) {
+	this.observeSQL(operation, getModelTableName(entity), nil, start, rows, err)
+}
+
+// observe query of table with sql, nil if unknown
+func (this *Session) observeSQL(operation string, table string, statement *querySQL, start time.Time, rows int64, err error) {
+
+	config := queryLogConfig
+
+	if config == nil {
+		return
+	}
+
+	event := &QueryEvent{
+		Operation: operation,
+		Table:     table,
+		Duration:  time.Since(start),
+		Rows:      rows,
+		Err:       err,
+	}
+
+	if statement != nil {
+		event.SQL = statement.sql
+		event.Args = redactArgs(statement.sql, formatQueryArgs(statement.args), config.RedactAllArgs)
+	}
+
+	if this.database != nil {
+		event.Alias = this.database.dbName
+	}
+
+	if tenant, ok := this.Tenant.(TenantModel); ok && this.HasTenant() {
+		event.TenantId = tenant.GetId()
+	}
+
+	event.Slow = config.SlowThreshold > 0 && event.Duration >= config.SlowThreshold
+
+	if event.Slow {
+		event.Caller = queryCaller()
+		logSlowQuery(event)
+	}
+
+	if config.Sink != nil {
+		config.Sink.Query(event)
+	}
+
+	if stats := this.queryStats; stats != nil {
+
+		stats.Count++
+		stats.Duration += event.Duration
+		stats.Repeated[queryStatsKey(event)]++
+
+		if event.Slow {
+			stats.Slow++
+		}
+
+		if event.Err != nil {
+			stats.Errors++
+		}
+	}
+}
+
+// rows of single entity query
+func queryRows(err error) int64 {
+	if err != nil {
+		return 0
+	}
+	return 1
+}
+
+func logSlowQuery(event *QueryEvent) {
+	query := event.SQL
+	if len(query) == 0 {
+		query = fmt.Sprintf("%v %v", event.Operation, event.Table)
+	}
+	logs.Warn("## slow query [%v] tenant %v: %v - %v", event.Duration, event.TenantId, query, event.Caller)
+}
+
+// N+1 key of event, normalized sql or operation and table
+func queryStatsKey(event *QueryEvent) string {
+	if len(event.SQL) > 0 {
+		return normalizeSQL(event.SQL)
+	}
+	return fmt.Sprintf("%v %v", event.Operation, event.Table)
+}
+
+var (
+	sqlStringRegex     = regexp.MustCompile(`'(?:[^']|'')*'`)
+	sqlNumberRegex     = regexp.MustCompile(`\$\d+|\b\d+(?:\.\d+)?\b`)
+	sqlPlaceholderList = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	sqlWhitespaceRegex = regexp.MustCompile(`\s+`)
+)
+
+// sql with values and placeholders as ?, lists of placeholders as (?) and single spaces.
+// eg.: WHERE id IN ($1, $2) AND name = 'a' > WHERE id IN (?) AND name = ?
+func normalizeSQL(query string) string {
+	query = sqlStringRegex.ReplaceAllString(query, "?")
+	query = sqlNumberRegex.ReplaceAllString(query, "?")
+	query = sqlPlaceholderList.ReplaceAllString(query, "(?)")
+	return strings.TrimSpace(sqlWhitespaceRegex.ReplaceAllString(query, " "))
+}
+
+func formatQueryArgs(args []interface{}) []string {
+	values := []string{}
+	for _, it := range flattenSQLArgs(args) {
+		values = append(values, fmt.Sprintf("%v", sqlValue(it)))
+	}
+	return values
+}
+
+func onOrmQuery(query map[string]interface{}) {
+
+	config := queryLogConfig
+
+	if config == nil || config.Sink == nil {
+		return
+	}
+
+	event := &QueryEvent{Rows: -1}
+	event.Alias, _ = query["alias_name"].(string)
+	event.Operation, _ = query["operation"].(string)
+	event.SQL, _ = query["query"].(string)
+	event.Err, _ = query["err"].(error)
+
+	// milliseconds
+	if cost, ok := query["cost_time"].(float64); ok {
+		event.Duration = time.Duration(cost * float64(time.Millisecond))
+	}
+
+	if args, ok := query["cons"].([]string); ok {
+		event.TenantId = tenantArg(event.SQL, args)
+		event.Args = redactArgs(event.SQL, args, config.RedactAllArgs)
+	}
+
+	event.Caller = queryCaller()
+	event.Slow = config.SlowThreshold > 0 && event.Duration >= config.SlowThreshold
+
+	if event.Slow {
+		logSlowQuery(event)
+	}
+
+	config.Sink.Query(event)
+}
+
+// tenant of sql, value of tenant_id column
+func tenantArg(sql string, args []string) interface{} {
+	for index, column := range placeholderColumns(sql, len(args)) {
+		if strings.EqualFold(column, "tenant_id") {
+			if id, err := strconv.ParseInt(args[index], 10, 64); err == nil {
+				return id
+			}
+			return args[index]
+		}
+	}
+	return nil
+}
+
+var (
+	placeholderRegex = regexp.MustCompile(`\?|\$\d+`)
+	// column before placeholder. eg.: "name" = ?, T0."id" IN (?, ?, name LIKE ?, age BETWEEN ? AND ?
+	placeholderColumnRegex = regexp.MustCompile(`(?i)["` + "`" + `]?([a-z_][a-z0-9_]*)["` + "`" + `]?\)?\s*(?:=|<>|!=|<=|>=|<|>|\bLIKE\b|\bIN\b|\bBETWEEN\s+(?:\?|\$\d+)\s+AND\b|\bBETWEEN\b)\s*(?:UPPER\()?\(?[\s?$0-9,]*$`)
+	insertColumnsRegex     = regexp.MustCompile(`(?is)^\s*INSERT\s+INTO\s+\S+\s*\(([^)]*)\)\s*VALUES`)
+)
+
+// redact args of redacted columns. the column of arg is detected by sql, unknown columns are not redacted
+func redactArgs(sql string, args []string, all bool) []string {
+
+	redactedColsMu.RLock()
+	hasRedacted := len(redactedCols) > 0
+	redactedColsMu.RUnlock()
+
+	if !all && !hasRedacted {
+		return args
+	}
+
+	result := make([]string, len(args))
+
+	if all {
+		for i := range args {
+			result[i] = redactedValue
+		}
+		return result
+	}
+
+	copy(result, args)
+
+	redactedColsMu.RLock()
+	defer redactedColsMu.RUnlock()
+
+	for index, column := range placeholderColumns(sql, len(args)) {
+		if redactedCols[strings.ToLower(column)] {
+			result[index] = redactedValue
+		}
+	}
+
+	return result
+}
+
+// column of each arg, detected by sql. unknown columns are not returned
+func placeholderColumns(sql string, count int) map[int]string {
+
+	columns := map[int]string{}
+
+	var insertColumns []string
+
+	if m := insertColumnsRegex.FindStringSubmatch(sql); m != nil {
+		for _, it := range strings.Split(m[1], ",") {
+			insertColumns = append(insertColumns, strings.Trim(strings.TrimSpace(it), "\"`"))
+		}
+	}
+
+	for i, loc := range placeholderRegex.FindAllStringIndex(sql, -1) {
+
+		index := i
+
+		// postgres $n
+		if sql[loc[0]] == '$' {
+			if n, err := strconv.Atoi(sql[loc[0]+1 : loc[1]]); err == nil {
+				index = n - 1
+			}
+		}
+
+		if index >= count {
+			continue
+		}
+
+		if len(insertColumns) > 0 {
+			columns[index] = insertColumns[i%len(insertColumns)]
+		} else if m := placeholderColumnRegex.FindStringSubmatch(sql[:loc[0]]); m != nil {
+			columns[index] = m[1]
+		}
+	}
+
+	return columns
+}
+
+// first caller out of orm and db packages
+func queryCaller() string {
+
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	for {
+		frame, more := frames.Next()
+
+		if !isQueryInternalFrame(frame.Function) {
+			return fmt.Sprintf("%v:%v", frame.File, frame.Line)
+		}
+
+		if !more {
+			break
+		}
+	}
+
+	return ""
+}
+
+func isQueryInternalFrame(function string) bool {
+	for _, it := range []string{
+		"github.com/beego/beego/v2/client/orm",
+		"github.com/mobilemindtech/go-utils/beego/db.",
+		"github.com/mobilemindtech/go-utils/v2/criteria.",
+		"github.com/mobilemindtech/go-utils/v2/session.",
+		"database/sql.",
+		"runtime.",
+	} {
+		if strings.HasPrefix(function, it) {
+			return true
+		}
+	}
+	return false
+}
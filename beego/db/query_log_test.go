@@ -0,0 +1,157 @@
+package db_test
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/mobilemindtech/go-utils/beego/db"
+)
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestQueryScope
+func TestQueryScope(t *testing.T) {
+
+	var mu sync.Mutex
+	events := []*db.QueryEvent{}
+
+	db.EnableQueryLog(&db.QueryLogConfig{
+		Sink: db.QuerySinkFunc(func(event *db.QueryEvent) {
+			mu.Lock()
+			events = append(events, event)
+			mu.Unlock()
+		}),
+		NPlusOneThreshold: 3,
+	})
+
+	defer db.DisableQueryLog()
+
+	if orm.Debug {
+		t.Errorf("expected orm debug disabled without LogSQL")
+	}
+
+	session, company := newTenantSession(t, "query log")
+
+	// events out of scope are sent to sink too
+	mu.Lock()
+	events = events[:0]
+	mu.Unlock()
+
+	session.BeginQueryScope()
+
+	saveProducts(t, session, &Product{Code: "p1"}, &Product{Code: "p2"})
+
+	for _, code := range []string{"p1", "p2", "p3"} {
+		db.NewCriteria(session, new(Product), nil).Eq("Code", code).One()
+	}
+
+	stats := session.EndQueryScope()
+
+	if stats == nil || stats.Count != 5 {
+		t.Fatalf("expected 5 queries, got %v", stats)
+	}
+
+	if nplusone := stats.NPlusOne(3); len(nplusone) != 1 || !strings.Contains(nplusone[0], "FROM products") {
+		t.Errorf("expected N+1 of products select, got %v", nplusone)
+	}
+
+	if session.EndQueryScope() != nil {
+		t.Errorf("expected scope ended")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(events) != 5 {
+		t.Fatalf("expected 5 events, got %v", len(events))
+	}
+
+	last := events[len(events)-1]
+
+	if last.Operation != "one" || last.Table != "products" || last.Rows != 0 || last.TenantId != company.Id {
+		t.Errorf("unexpected event %+v", last)
+	}
+
+	if !strings.Contains(last.SQL, "code = ?") || len(last.Args) != 2 || last.Args[0] != "p3" {
+		t.Errorf("expected sql and args of event, got %v %v", last.SQL, last.Args)
+	}
+
+	if events[0].Operation != "insert" || events[0].Rows != 1 {
+		t.Errorf("unexpected insert event %+v", events[0])
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestQueryLogRaw
+func TestQueryLogRaw(t *testing.T) {
+
+	var mu sync.Mutex
+	events := []*db.QueryEvent{}
+
+	debug := orm.Debug
+
+	db.EnableQueryLog(&db.QueryLogConfig{
+		Sink: db.QuerySinkFunc(func(event *db.QueryEvent) {
+			mu.Lock()
+			events = append(events, event)
+			mu.Unlock()
+		}),
+		LogSQL: true,
+	})
+
+	if !orm.Debug {
+		t.Errorf("expected orm debug enabled with LogSQL")
+	}
+
+	db.DisableQueryLog()
+
+	if orm.Debug != debug {
+		t.Errorf("expected orm debug restored")
+	}
+
+	db.EnableQueryLog(&db.QueryLogConfig{
+		Sink: db.QuerySinkFunc(func(event *db.QueryEvent) {
+			mu.Lock()
+			events = append(events, event)
+			mu.Unlock()
+		}),
+		NPlusOneThreshold: 3,
+	})
+
+	defer db.DisableQueryLog()
+
+	session, _ := newTenantSession(t, "query log raw")
+
+	saveProducts(t, session, &Product{Code: "p1", Price: 1}, &Product{Code: "p2", Price: 2})
+
+	session.BeginQueryScope()
+
+	for _, price := range []int{1, 2, 3} {
+		if _, err := session.Rows(fmt.Sprintf("select id from products where price = %v", price)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := session.RawExec("update products set version = version where price = ?", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := session.EndQueryScope()
+
+	if stats == nil || stats.Count != 4 {
+		t.Fatalf("expected 4 queries, got %v", stats)
+	}
+
+	if nplusone := stats.NPlusOne(3); len(nplusone) != 1 || nplusone[0] != "select id from products where price = ?" {
+		t.Errorf("expected N+1 of normalized raw sql, got %v", nplusone)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	last := events[len(events)-1]
+
+	if last.Operation != "raw" || last.Rows < 1 || len(last.Args) != 1 {
+		t.Errorf("unexpected raw event %+v", last)
+	}
+}
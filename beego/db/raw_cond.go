@@ -0,0 +1,164 @@
+package db
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// criteria compiled as raw condition of beego orm: Raw, subqueries and EqOuter
+func isRawCriteria(criteria *Criteria) bool {
+	switch criteria.Expression {
+	case Raw, InSubquery, NotInSubquery, ExistsSubquery, NotExistsSubquery, EqOuter:
+		return true
+	}
+	return criteria.Subquery != nil
+}
+
+// rawCondition beego orm raw condition of criteria. beego orm Condition.Raw has no args and writes
+// sql after path column (T0.column <sql>), so values are inlined. Only numbers, bool, nil and models
+// (by id) are inlined, text values are rejected
+func (this *Criteria) rawCondition(pathName string, criteria *Criteria) *orm.Condition {
+
+	if criteria.filterCondition != nil {
+		return criteria.filterCondition
+	}
+
+	query, args := criteria.RawQuery, criteria.RawValues
+	var err error
+
+	switch {
+	case criteria.Expression == EqOuter:
+		err = fmt.Errorf("EqOuter should be used on subquery: %v", criteria.Path)
+	case criteria.Subquery != nil:
+		var kind CriteriaExpression
+		kind, query, args, err = this.subquerySQL(criteria)
+		// primary key is never null, EXISTS does not use the column
+		if kind == ExistsSubquery || kind == NotExistsSubquery {
+			query = "IS NOT NULL AND " + query
+		}
+	}
+
+	if err == nil {
+		query, err = bindSQLValues(query, args)
+	}
+
+	if err != nil {
+		this.SetError(err)
+		// never match on error
+		return orm.NewCondition().Raw(pathName, "IS NULL AND 1 = 0")
+	}
+
+	return orm.NewCondition().Raw(pathName, query)
+}
+
+func (this *Criteria) driverType() orm.DriverType {
+	return this.Session.GetDb().DriverType()
+}
+
+// bindSQLValues replace ? of query, out of quotes, by sql literal of values. slices are expanded as lists
+func bindSQLValues(query string, values []interface{}) (string, error) {
+
+	var sb strings.Builder
+	index := 0
+
+	for i := 0; i < len(query); i++ {
+
+		c := query[i]
+
+		switch c {
+		case '\'', '"', '`':
+			// quoted text is copied, '' is escaped quote
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				return "", fmt.Errorf("unterminated quote on raw sql: %v", query)
+			}
+			sb.WriteString(query[i : i+end+2])
+			i += end + 1
+		case '?':
+			if index >= len(values) {
+				return "", fmt.Errorf("raw sql has more placeholders than values: %v", query)
+			}
+			literal, err := sqlLiteral(values[index])
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(literal)
+			index++
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	if index != len(values) {
+		return "", fmt.Errorf("raw sql has %v placeholders and %v values: %v", index, len(values), query)
+	}
+
+	return sb.String(), nil
+}
+
+// sqlLiteral value as sql literal. supports nil, bool, numbers, models (by id), driver.Valuer
+// and slices of them (as comma separated list). text and time are not inlined, use Session.RawQuery
+func sqlLiteral(value interface{}) (string, error) {
+
+	value = sqlValue(value)
+
+	if valuer, ok := value.(driver.Valuer); ok {
+		v, err := valuer.Value()
+		if err != nil {
+			return "", err
+		}
+		value = v
+	}
+
+	if value == nil {
+		return "NULL", nil
+	}
+
+	v := reflect.ValueOf(value)
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return "NULL", nil
+		}
+		return sqlLiteral(v.Elem().Interface())
+	case reflect.Bool:
+		if v.Bool() {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		if math.IsNaN(v.Float()) || math.IsInf(v.Float(), 0) {
+			return "", fmt.Errorf("invalid sql number: %v", value)
+		}
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64), nil
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		if v.Len() == 0 {
+			return "NULL", nil
+		}
+		items := []string{}
+		for i := 0; i < v.Len(); i++ {
+			item, err := sqlLiteral(v.Index(i).Interface())
+			if err != nil {
+				return "", err
+			}
+			items = append(items, item)
+		}
+		return strings.Join(items, ", "), nil
+	}
+
+	return "", fmt.Errorf("type %T not supported as raw criteria or subquery value, only numbers, bool and models", value)
+}
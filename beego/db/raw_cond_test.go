@@ -0,0 +1,55 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestBindSQLValues
+func TestBindSQLValues(t *testing.T) {
+
+	id := int64(7)
+
+	tests := []struct {
+		name     string
+		query    string
+		values   []interface{}
+		expected string
+		fail     bool
+	}{
+		{"int", "= ?", []interface{}{10}, "= 10", false},
+		{"float", "> ?", []interface{}{1.5}, "> 1.5", false},
+		{"quoted placeholder", "= '?' and b = ?", []interface{}{1}, "= '?' and b = 1", false},
+		{"slice", "in (?)", []interface{}{[]int64{1, 2, 3}}, "in (1, 2, 3)", false},
+		{"empty slice", "in (?)", []interface{}{[]int64{}}, "in (NULL)", false},
+		{"nil", "= ?", []interface{}{nil}, "= NULL", false},
+		{"pointer", "= ?", []interface{}{&id}, "= 7", false},
+		{"bool", "= ?", []interface{}{true}, "= TRUE", false},
+		{"model", "= ?", []interface{}{&cachedEntity{Id: 3}}, "= 3", false},
+		{"string", "= ?", []interface{}{"o'hara"}, "", true},
+		{"string slice", "in (?)", []interface{}{[]string{"a", "b"}}, "", true},
+		{"time", "> ?", []interface{}{time.Now()}, "", true},
+		{"missing value", "= ? and ?", []interface{}{1}, "", true},
+		{"extra value", "= ?", []interface{}{1, 2}, "", true},
+		{"unterminated quote", "= 'a", nil, "", true},
+		{"bytes", "= ?", []interface{}{[]byte("a")}, "", true},
+	}
+
+	for _, it := range tests {
+		t.Run(it.name, func(t *testing.T) {
+			query, err := bindSQLValues(it.query, it.values)
+			if it.fail {
+				if err == nil {
+					t.Fatalf("expected error, got %v", query)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if query != it.expected {
+				t.Errorf("expected %v, got %v", it.expected, query)
+			}
+		})
+	}
+}
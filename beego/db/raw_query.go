@@ -58,12 +58,34 @@ func (this *RawQuery) WithArgs(args ...interface{}) *RawQuery {
 	return this
 }
 
+// RawSeter returns nil when query is rejected by tenant guard, see Error
 func (this *RawQuery) RawSeter() orm.RawSeter {
-	return this.Session.GetDb().Raw(this.Query, this.Args...)
+	raw, err := this.raw()
+	if err != nil {
+		this.Error = err
+		return nil
+	}
+	return raw
+}
+
+// raw seter of query guarded by session tenant guard
+func (this *RawQuery) raw() (orm.RawSeter, error) {
+	query, args, err := this.Session.guardRaw(this.Query, this.Args)
+	if err != nil {
+		return nil, err
+	}
+	return this.Session.GetDb().Raw(query, args...), nil
 }
 
 func (this *RawQuery) Execute() (int64, error) {
-	res, err := this.Session.GetDb().Raw(this.Query, this.Args...).Exec()
+	raw, err := this.raw()
+
+	if err != nil {
+		this.Error = err
+		return 0, err
+	}
+
+	res, err := raw.Exec()
 
 	if err != nil {
 		this.Error = err
@@ -76,17 +98,32 @@ func (this *RawQuery) Execute() (int64, error) {
 }
 
 func (this *RawQuery) ToResutl(result interface{}) error {
-	this.Error = this.Session.GetDb().Raw(this.Query, this.Args...).QueryRow(result)
+	raw, err := this.raw()
+	if err != nil {
+		this.Error = err
+		return err
+	}
+	this.Error = raw.QueryRow(result)
 	return this.Error
 }
 
 func (this *RawQuery) ToResutls(results interface{}) error {
-	this.RowsAffected, this.Error = this.Session.GetDb().Raw(this.Query, this.Args...).QueryRows(results)
+	raw, err := this.raw()
+	if err != nil {
+		this.Error = err
+		return err
+	}
+	this.RowsAffected, this.Error = raw.QueryRows(results)
 	return this.Error
 }
 
 func (this *RawQuery) Values() *RawQuery {
-	this.RowsAffected, this.Error = this.Session.GetDb().Raw(this.Query, this.Args...).Values(&this.values)
+	raw, err := this.raw()
+	if err != nil {
+		this.Error = err
+		return this
+	}
+	this.RowsAffected, this.Error = raw.Values(&this.values)
 	return this
 }
 
@@ -99,7 +136,12 @@ func (this *RawQuery) ExecAsValuesMap() ([]map[string]interface{}, error) {
 }
 
 func (this *RawQuery) ValuesList() *RawQuery {
-	this.RowsAffected, this.Error = this.Session.GetDb().Raw(this.Query, this.Args...).ValuesList(&this.valuesList)
+	raw, err := this.raw()
+	if err != nil {
+		this.Error = err
+		return this
+	}
+	this.RowsAffected, this.Error = raw.ValuesList(&this.valuesList)
 	return this
 }
 
@@ -112,7 +154,12 @@ func (this *RawQuery) ExecAsValuesList() ([][]interface{}, error) {
 }
 
 func (this *RawQuery) ValuesFlat() *RawQuery {
-	this.RowsAffected, this.Error = this.Session.GetDb().Raw(this.Query, this.Args...).ValuesFlat(&this.valuesFlat)
+	raw, err := this.raw()
+	if err != nil {
+		this.Error = err
+		return this
+	}
+	this.RowsAffected, this.Error = raw.ValuesFlat(&this.valuesFlat)
 	return this
 }
 func (this *RawQuery) ExecAsValuesFlat() ([]interface{}, error) {
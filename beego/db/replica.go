@@ -0,0 +1,162 @@
+package db
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/beego/beego/v2/core/logs"
+)
+
+type ReplicaStrategy int
+
+const (
+	ReplicaRoundRobin ReplicaStrategy = iota + 1
+	// replica with lowest average read latency. replicas without reads are selected first
+	ReplicaLeastLatency
+)
+
+// ReplicaPool read replicas of a primary database alias
+type ReplicaPool struct {
+	Primary  string
+	Aliases  []string
+	Strategy ReplicaStrategy
+
+	next    uint64
+	mu      sync.Mutex
+	latency map[string]time.Duration
+}
+
+var (
+	replicaPools   = map[string]*ReplicaPool{}
+	replicaPoolsMu sync.RWMutex
+)
+
+// RegisterReplicas register replica aliases of primary alias. The aliases should be registered on beego orm.
+// eg.: db.RegisterReplicas("default", db.ReplicaRoundRobin, "replica1", "replica2")
+func RegisterReplicas(primary string, strategy ReplicaStrategy, aliases ...string) *ReplicaPool {
+	pool := &ReplicaPool{Primary: primary, Aliases: aliases, Strategy: strategy, latency: map[string]time.Duration{}}
+	replicaPoolsMu.Lock()
+	replicaPools[primary] = pool
+	replicaPoolsMu.Unlock()
+	return pool
+}
+
+func GetReplicaPool(primary string) *ReplicaPool {
+	replicaPoolsMu.RLock()
+	defer replicaPoolsMu.RUnlock()
+	return replicaPools[primary]
+}
+
+// Next replica alias by pool strategy
+func (this *ReplicaPool) Next() string {
+
+	if len(this.Aliases) == 0 {
+		return this.Primary
+	}
+
+	if this.Strategy == ReplicaLeastLatency {
+
+		this.mu.Lock()
+		defer this.mu.Unlock()
+
+		selected := ""
+		var min time.Duration
+
+		for _, it := range this.Aliases {
+			latency, ok := this.latency[it]
+			if !ok {
+				return it
+			}
+			if len(selected) == 0 || latency < min {
+				selected, min = it, latency
+			}
+		}
+
+		return selected
+	}
+
+	n := atomic.AddUint64(&this.next, 1)
+	return this.Aliases[(n-1)%uint64(len(this.Aliases))]
+}
+
+// Observe read latency of replica, as moving average
+func (this *ReplicaPool) Observe(alias string, latency time.Duration) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if last, ok := this.latency[alias]; ok {
+		latency = (last*4 + latency) / 5
+	}
+	this.latency[alias] = latency
+}
+
+func (this *ReplicaPool) Latency(alias string) time.Duration {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.latency[alias]
+}
+
+// SetReadReplicas send read only operations (Criteria List, One, Count, Exists and aggregates, Load, Get, List,
+// Count, Rows and FirstRow) to a replica of session database alias. Transactions and writes always use primary
+func (this *Session) SetReadReplicas() *Session {
+	this.readReplicas = true
+	return this
+}
+
+// Primary force reads on primary, to read after write. eg.: session.Primary().Load(entity)
+func (this *Session) Primary() *Session {
+	this.primary = true
+	return this
+}
+
+// Replica undo Primary
+func (this *Session) Replica() *Session {
+	this.primary = false
+	return this
+}
+
+func (this *Session) IsReadReplica() bool {
+	return this.getReplicaPool() != nil
+}
+
+func (this *Session) getReplicaPool() *ReplicaPool {
+
+	if !this.readReplicas || this.primary || this.tx || this.database == nil {
+		return nil
+	}
+
+	pool := GetReplicaPool(this.database.dbName)
+
+	if pool == nil || len(pool.Aliases) == 0 {
+		return nil
+	}
+
+	return pool
+}
+
+// GetReadDb database to read only operations. The replica is selected once by session
+func (this *Session) GetReadDb() *DataBase {
+
+	pool := this.getReplicaPool()
+
+	if pool == nil {
+		return this.GetDb()
+	}
+
+	if this.readDatabase == nil {
+		this.readDatabase = NewDataBase(pool.Next())
+		this.readDatabase.Open()
+		if this.Debug {
+			logs.Debug("## session read replica: %v", this.readDatabase.dbName)
+		}
+	}
+
+	return this.readDatabase
+}
+
+// observe latency of read started at start, if read on replica
+func (this *Session) observeRead(start time.Time) {
+	if pool := this.getReplicaPool(); pool != nil && this.readDatabase != nil {
+		pool.Observe(this.readDatabase.dbName, time.Since(start))
+	}
+}
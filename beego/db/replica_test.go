@@ -0,0 +1,84 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mobilemindtech/go-utils/beego/db"
+)
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestReplicaPool
+func TestReplicaPool(t *testing.T) {
+
+	pool := db.RegisterReplicas("test_primary", db.ReplicaRoundRobin, "r1", "r2")
+
+	if db.GetReplicaPool("test_primary") != pool {
+		t.Fatal("expected registered pool")
+	}
+
+	for i, expected := range []string{"r1", "r2", "r1"} {
+		if alias := pool.Next(); alias != expected {
+			t.Errorf("round robin %v: expected %v, got %v", i, expected, alias)
+		}
+	}
+
+	pool = db.RegisterReplicas("test_primary", db.ReplicaLeastLatency, "r1", "r2")
+
+	pool.Observe("r1", time.Millisecond*10)
+
+	// replica without reads first
+	if alias := pool.Next(); alias != "r2" {
+		t.Errorf("expected r2, got %v", alias)
+	}
+
+	pool.Observe("r2", time.Millisecond*20)
+
+	if alias := pool.Next(); alias != "r1" {
+		t.Errorf("expected r1, got %v", alias)
+	}
+
+	empty := db.RegisterReplicas("test_empty", db.ReplicaRoundRobin)
+
+	if alias := empty.Next(); alias != "test_empty" {
+		t.Errorf("expected primary without replicas, got %v", alias)
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestReadReplicaRouting
+func TestReadReplicaRouting(t *testing.T) {
+
+	db.RegisterReplicas("default", db.ReplicaRoundRobin, "default")
+	t.Cleanup(func() { db.RegisterReplicas("default", db.ReplicaRoundRobin) })
+
+	session := db.NewSession().SetReadReplicas()
+
+	if err := session.OpenNoTx(); err != nil {
+		t.Fatal(err)
+	}
+
+	defer session.Close()
+
+	if !session.IsReadReplica() {
+		t.Errorf("expected read replica")
+	}
+
+	if session.Primary().IsReadReplica() {
+		t.Errorf("expected primary")
+	}
+
+	if !session.Replica().IsReadReplica() {
+		t.Errorf("expected read replica")
+	}
+
+	tx := db.NewSession().SetReadReplicas()
+
+	if err := tx.OpenTx(); err != nil {
+		t.Fatal(err)
+	}
+
+	defer tx.OnError().Close()
+
+	if tx.IsReadReplica() {
+		t.Errorf("expected transaction on primary")
+	}
+}
@@ -0,0 +1,127 @@
+package db_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mobilemindtech/go-utils/beego/db"
+)
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestNested
+func TestNested(t *testing.T) {
+
+	session, _ := newTenantSession(t, "nested")
+
+	saveProducts(t, session, &Product{Code: "p1", Name: "outer"})
+
+	err := session.Nested(func(s *db.Session) error {
+		saveProducts(t, s, &Product{Code: "p2", Name: "inner"})
+		return errors.New("inner error")
+	})
+
+	if err == nil || err.Error() != "inner error" {
+		t.Fatalf("expected inner error, got %v", err)
+	}
+
+	err = session.Nested(func(s *db.Session) error {
+		saveProducts(t, s, &Product{Code: "p3", Name: "inner"})
+		s.SetError()
+		return nil
+	})
+
+	if err == nil {
+		t.Fatal("expected error of session error state")
+	}
+
+	if session.State != db.SessionStateOk {
+		t.Errorf("expected session state restored")
+	}
+
+	err = session.Nested(func(s *db.Session) error {
+		return s.Nested(func(s *db.Session) error {
+			saveProducts(t, s, &Product{Code: "p4", Name: "inner"})
+			return nil
+		})
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := session.Count(new(Product))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 2 {
+		t.Errorf("expected outer and released nested products, got %v", count)
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestNestedPanic
+func TestNestedPanic(t *testing.T) {
+
+	session, _ := newTenantSession(t, "nested panic")
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("expected panic")
+			}
+		}()
+		session.Nested(func(s *db.Session) error {
+			saveProducts(t, s, &Product{Code: "p1"})
+			panic("nested panic")
+		})
+	}()
+
+	count, err := session.Count(new(Product))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 0 {
+		t.Errorf("expected rollback to savepoint, got %v products", count)
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestSavepoint
+func TestSavepoint(t *testing.T) {
+
+	session, _ := newTenantSession(t, "savepoint")
+
+	if err := session.Savepoint("a b"); err == nil {
+		t.Errorf("expected invalid savepoint name")
+	}
+
+	if err := session.RollbackTo("none"); err == nil {
+		t.Errorf("expected savepoint not found")
+	}
+
+	if err := session.Savepoint("sp1"); err != nil {
+		t.Fatal(err)
+	}
+
+	saveProducts(t, session, &Product{Code: "p1"})
+
+	if err := session.RollbackTo("sp1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// savepoint is still active after rollback
+	if err := session.ReleaseSavepoint("sp1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if count, _ := session.Count(new(Product)); count != 0 {
+		t.Errorf("expected 0 products, got %v", count)
+	}
+
+	noTx := db.NewSession()
+
+	if err := noTx.Savepoint("sp1"); err == nil {
+		t.Errorf("expected error without transaction")
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/beego/beego/v2/core/logs"
 	"github.com/mobilemindtech/go-io/option"
 	"github.com/mobilemindtech/go-io/result"
+	"github.com/mobilemindtech/go-io/types/unit"
 	"github.com/mobilemindtech/go-utils/support"
 	"github.com/mobilemindtech/go-utils/v2/lists"
 	"github.com/mobilemindtech/go-utils/v2/optional"
@@ -71,8 +72,10 @@ type Session struct {
 	AuthorizedTenants           []TenantModel
 	IgnoreTenantFilter          bool
 	IgnoreAuthorizedTenantCheck bool
+	IgnoreSoftDeleteFilter      bool
 	Debug                       bool
 	DbName                      string
+	AuditUserId                 int64
 
 	deepSetDefault   map[string]int
 	deepSaveOrUpdate map[string]int
@@ -81,8 +84,32 @@ type Session struct {
 
 	openDbError bool
 
-	database *DataBase
-	tx       bool
+	database   *DataBase
+	tx         bool
+	savepoints []*savepoint
+
+	beforeListeners map[HookOperation][]SessionListener
+	afterListeners  map[HookOperation][]SessionListener
+
+	queryCacheTouched map[string]bool
+
+	// query stats of scope, when db.EnableQueryLog
+	queryStats *QueryStats
+
+	readReplicas bool
+	primary      bool
+	readDatabase *DataBase
+
+	// tenant of schema or database isolation
+	isolatedTenantId int64
+
+	// raw sql without tenant guard
+	unsafeRaw bool
+}
+
+type savepoint struct {
+	name  string
+	state SessionState
 }
 
 func NewSession() *Session {
@@ -93,12 +120,16 @@ func NewSessionWithDbName(dbName string) *Session {
 	return &Session{State: SessionStateOk, Debug: false, database: NewDataBase(dbName), IgnoreAuthorizedTenantCheck: true}
 }
 
+// NewSessionWithTenant session of tenant. The tenant schema or database is selected when SetTenantIsolation
 func NewSessionWithTenant(tenant interface{}) *Session {
-	return &Session{State: SessionStateOk, Tenant: tenant, Debug: false, database: NewDataBase("default"), IgnoreAuthorizedTenantCheck: true}
+	session := &Session{State: SessionStateOk, Tenant: tenant, Debug: false, database: NewDataBase("default"), IgnoreAuthorizedTenantCheck: true}
+	return session.isolateTenant()
 }
 
+// NewSessionWithTenantAndDbName session of dbName. When tenant is isolated by database, dbName is ignored and the tenant database is used
 func NewSessionWithTenantAndDbName(tenant interface{}, dbName string) *Session {
-	return &Session{State: SessionStateOk, Tenant: tenant, Debug: false, database: NewDataBase(dbName), IgnoreAuthorizedTenantCheck: true}
+	session := &Session{State: SessionStateOk, Tenant: tenant, Debug: false, database: NewDataBase(dbName), IgnoreAuthorizedTenantCheck: true}
+	return session.isolateTenant()
 }
 
 func RunTx(tenant interface{}, fn func(session *Session) error) error {
@@ -283,6 +314,18 @@ func (this *Session) OpenNoTx() error {
 }
 
 func (this *Session) OpenWithoutTx() error {
+
+	// search_path is set by transaction
+	if this.isSchemaIsolated() {
+		return this.OpenWithTx()
+	}
+
+	if err := this.openTenantIsolation(); err != nil {
+		this.openDbError = true
+		logs.Error("open tenant database error: %v", err)
+		return err
+	}
+
 	this.tx = false
 	this.database.Open()
 	return nil
@@ -299,6 +342,8 @@ func (this *Session) Close() {
 	} else {
 		this.database = nil
 	}
+
+	this.readDatabase = nil
 }
 
 func (this *Session) beginTx() error {
@@ -307,11 +352,23 @@ func (this *Session) beginTx() error {
 
 func (this *Session) beginTxWithOpts(opts *sql.TxOptions) (err error) {
 
+	if err = this.openTenantIsolation(); err != nil {
+		this.openDbError = true
+		logs.Error("open tenant database error: %v", err)
+		return err
+	}
+
 	if err = this.database.Begin(); err != nil {
 		this.openDbError = true
 		logs.Error("begin transaction error: %v", err)
 		debug.PrintStack()
+		return err
+	}
 
+	if err = this.beginTenantIsolation(); err != nil {
+		this.openDbError = true
+		logs.Error("set tenant schema error: %v", err)
+		this.database.Rollback()
 	}
 
 	return err
@@ -329,10 +386,14 @@ func (this *Session) Commit() (err error) {
 			debug.PrintStack()
 
 			this.Rollback()
+		} else {
+			this.invalidateQueryCacheTouched()
 		}
 		this.database = nil
 	}
 
+	this.savepoints = nil
+	this.queryCacheTouched = nil
 	return err
 }
 
@@ -353,35 +414,137 @@ func (this *Session) Rollback() (err error) {
 		this.database = nil
 	}
 
+	// writes are discarded, the tables were invalidated on write
+	this.queryCacheTouched = nil
+	this.savepoints = nil
 	return err
 
 }
 
-func (this *Session) Save(entity interface{}) error {
+// Savepoint create a savepoint on current transaction
+func (this *Session) Savepoint(name string) error {
 
-	if !this.isTenantNil() && this.isSetTenant(entity) {
-		if this.Debug {
-			logs.Debug("## Save set tenant")
+	if !this.tx || this.database == nil {
+		return errors.New("savepoint requires an open transaction")
+	}
+
+	if this.Debug {
+		logs.Debug("## session savepoint %v", name)
+	}
+
+	if err := this.database.Savepoint(name); err != nil {
+		logs.Error("savepoint %v error: %v", name, err)
+		return err
+	}
+
+	this.savepoints = append(this.savepoints, &savepoint{name: name, state: this.State})
+	return nil
+}
+
+// RollbackTo rollback transaction to savepoint and restore the session state of savepoint creation
+func (this *Session) RollbackTo(name string) error {
+
+	idx := this.savepointIndex(name)
+
+	if idx < 0 {
+		return fmt.Errorf("savepoint %v not found", name)
+	}
+
+	if this.Debug {
+		logs.Debug("## session rollback to savepoint %v", name)
+	}
+
+	if err := this.database.RollbackTo(name); err != nil {
+		logs.Error("rollback to savepoint %v error: %v", name, err)
+		return err
+	}
+
+	// savepoint still active after rollback, only nested savepoints are destroyed
+	this.State = this.savepoints[idx].state
+	this.savepoints = this.savepoints[:idx+1]
+	return nil
+}
+
+// ReleaseSavepoint release savepoint, keeping changes on current transaction
+func (this *Session) ReleaseSavepoint(name string) error {
+
+	idx := this.savepointIndex(name)
+
+	if idx < 0 {
+		return fmt.Errorf("savepoint %v not found", name)
+	}
+
+	if this.Debug {
+		logs.Debug("## session release savepoint %v", name)
+	}
+
+	if err := this.database.ReleaseSavepoint(name); err != nil {
+		logs.Error("release savepoint %v error: %v", name, err)
+		return err
+	}
+
+	this.savepoints = this.savepoints[:idx]
+	return nil
+}
+
+// Nested run fn inside a savepoint. If fn return error, panic or set session error state,
+// changes are rolled back to savepoint and session state is restored, so outer transaction can continue.
+func (this *Session) Nested(fn func(*Session) error) (err error) {
+
+	name := fmt.Sprintf("goutils_sp_%v", len(this.savepoints)+1)
+
+	if err = this.Savepoint(name); err != nil {
+		return err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			this.RollbackTo(name)
+			this.ReleaseSavepoint(name)
+			panic(r)
 		}
-		this.setTenant(entity)
+	}()
+
+	err = fn(this)
+
+	if err == nil && this.State == SessionStateError {
+		err = errors.New("nested session finished with error state")
 	}
 
-	if !this.checkIsAuthorizedTenant(entity, "Session.Save") {
-		this.SetError()
-		return errors.New("Tenant not authorized for entity data access. Operation: Session.Save.")
+	if err != nil {
+		if e := this.RollbackTo(name); e != nil {
+			this.SetError()
+			return fmt.Errorf("%v: rollback to savepoint error: %v", err, e)
+		}
+		this.ReleaseSavepoint(name)
+		return err
 	}
 
-	if hook, ok := entity.(ModelHookBeforeSave); ok {
-		if err := hook.BeforeSave(); err != nil {
-			return err
+	return this.ReleaseSavepoint(name)
+}
+
+func (this *Session) savepointIndex(name string) int {
+	for i := len(this.savepoints) - 1; i >= 0; i-- {
+		if this.savepoints[i].name == name {
+			return i
 		}
 	}
+	return -1
+}
+
+func (this *Session) Save(entity interface{}) error {
+
+	if err := this.beforeSave(entity, "Session.Save"); err != nil {
+		return err
+	}
 
 	if this.Debug {
 		logs.Debug("insert data %v", getTypeName(entity))
 	}
 
+	start := time.Now()
 	_, err := this.GetDb().Insert(entity)
+	this.observeQuery("insert", entity, start, queryRows(err), err)
 
 	if this.Debug {
 		logs.Debug("## save data: %+v", entity)
@@ -394,15 +557,41 @@ func (this *Session) Save(entity interface{}) error {
 		return err
 	}
 
-	if hook, ok := entity.(ModelHookAfterSave); ok {
-		if err := hook.AfterSave(); err != nil {
-			return err
-		}
+	this.invalidateQueryCache(entity)
+
+	if err := this.audit(AuditActionCreate, entity, nil); err != nil {
+		return err
+	}
+
+	if err := this.runAfterHooks(HookOperationSave, entity); err != nil {
+		return err
 	}
 
 	return nil
 }
 
+// set tenant, check authorization, run before hooks and init version
+func (this *Session) beforeSave(entity interface{}, operation string) error {
+
+	if !this.isTenantNil() && this.isSetTenant(entity) {
+		if this.Debug {
+			logs.Debug("## Save set tenant")
+		}
+		this.setTenant(entity)
+	}
+
+	if !this.checkIsAuthorizedTenant(entity, operation) {
+		this.SetError()
+		return fmt.Errorf("Tenant not authorized for entity data access. Operation: %v.", operation)
+	}
+
+	if err := this.runBeforeHooks(HookOperationSave, entity); err != nil {
+		return err
+	}
+
+	return this.initVersion(entity)
+}
+
 func (this *Session) Update(entity interface{}) error {
 
 	if !this.isTenantNil() && this.isSetTenant(entity) {
@@ -418,13 +607,25 @@ func (this *Session) Update(entity interface{}) error {
 		return errors.New("Tenant not authorized for entity data access. Operation: Session.Update.")
 	}
 
-	if hook, ok := entity.(ModelHookBeforeUpdate); ok {
-		if err := hook.BeforeUpdate(); err != nil {
-			return err
-		}
+	if err := this.runBeforeHooks(HookOperationUpdate, entity); err != nil {
+		return err
 	}
 
-	_, err := this.GetDb().Update(entity)
+	auditState, err := this.loadAuditState(entity)
+
+	if err != nil {
+		this.SetError()
+		return err
+	}
+
+	start := time.Now()
+	versioned, err := this.updateVersioned(entity)
+
+	if !versioned && err == nil {
+		_, err = this.GetDb().Update(entity)
+	}
+
+	this.observeQuery("update", entity, start, queryRows(err), err)
 
 	if this.Debug {
 		logs.Debug("## update data: %+v", entity)
@@ -432,15 +633,21 @@ func (this *Session) Update(entity interface{}) error {
 
 	if err != nil {
 		logs.Error("update error: %v", err)
-		debug.PrintStack()
+		if !IsStaleEntity(err) {
+			debug.PrintStack()
+		}
 		this.SetError()
 		return err
 	}
 
-	if hook, ok := entity.(ModelHookAfterUpdate); ok {
-		if err := hook.AfterUpdate(); err != nil {
-			return err
-		}
+	this.invalidateQueryCache(entity)
+
+	if err := this.audit(AuditActionUpdate, entity, auditState); err != nil {
+		return err
+	}
+
+	if err := this.runAfterHooks(HookOperationUpdate, entity); err != nil {
+		return err
 	}
 
 	return nil
@@ -453,13 +660,22 @@ func (this *Session) Remove(entity interface{}) error {
 		return errors.New("Tenant not authorized for entity data access. Operation: Session.Remove.")
 	}
 
-	if hook, ok := entity.(ModelHookBeforeRemove); ok {
-		if err := hook.BeforeRemove(); err != nil {
-			return err
+	if err := this.runBeforeHooks(HookOperationRemove, entity); err != nil {
+		return err
+	}
+
+	field, ok, err := this.getSoftDeleteField(entity)
+	start := time.Now()
+
+	if err == nil {
+		if ok {
+			err = this.softRemove(entity, field)
+		} else {
+			_, err = this.GetDb().Delete(entity)
 		}
 	}
 
-	_, err := this.GetDb().Delete(entity)
+	this.observeQuery("delete", entity, start, queryRows(err), err)
 
 	if err != nil {
 		logs.Error("remove error: %v", err)
@@ -468,10 +684,14 @@ func (this *Session) Remove(entity interface{}) error {
 		return err
 	}
 
-	if hook, ok := entity.(ModelHookAfterRemove); ok {
-		if err := hook.AfterRemove(); err != nil {
-			return err
-		}
+	this.invalidateQueryCache(entity)
+
+	if err := this.audit(AuditActionRemove, entity, nil); err != nil {
+		return err
+	}
+
+	if err := this.runAfterHooks(HookOperationRemove, entity); err != nil {
+		return err
 	}
 
 	return nil
@@ -525,7 +745,10 @@ func (this *Session) Get(entity interface{}) (bool, error) {
 
 	if model, ok := entity.(Model); ok {
 
-		err := this.GetDb().Read(entity)
+		start := time.Now()
+		err := this.GetReadDb().Read(entity)
+		this.observeRead(start)
+		this.observeQuery("read", entity, start, queryRows(err), err)
 
 		if err == orm.ErrNoRows {
 			//logs.Debug("## Session: error on load: %v", err.Error())
@@ -568,12 +791,14 @@ func (this *Session) Count(entity interface{}) (int64, error) {
 
 	if model, ok := entity.(Model); ok {
 
-		query := this.GetDb().QueryTable(model.TableName())
+		query := this.GetReadDb().QueryTable(model.TableName())
 
 		if !this.IgnoreTenantFilter {
 			query = this.setTenantFilter(entity, query)
 		}
 
+		query = this.setSoftDeleteFilter(entity, query)
+
 		num, err := query.Count()
 
 		if err != nil {
@@ -590,12 +815,14 @@ func (this *Session) Count(entity interface{}) (int64, error) {
 func (this *Session) HasById(entity interface{}, id int64) (bool, error) {
 
 	if model, ok := entity.(Model); ok {
-		query := this.GetDb().QueryTable(model.TableName()).Filter("id", id)
+		query := this.GetReadDb().QueryTable(model.TableName()).Filter("id", id)
 
 		if !this.IgnoreTenantFilter {
 			query = this.setTenantFilter(entity, query)
 		}
 
+		query = this.setSoftDeleteFilter(entity, query)
+
 		return query.Exist(), nil
 	}
 
@@ -621,12 +848,14 @@ func (this *Session) FindByIdOrNil(entity interface{}, id int64) (interface{}, e
 func (this *Session) FindById(entity interface{}, id int64) (interface{}, error) {
 
 	if model, ok := entity.(Model); ok {
-		query := this.GetDb().QueryTable(model.TableName()).Filter("id", id)
+		query := this.GetReadDb().QueryTable(model.TableName()).Filter("id", id)
 
 		if !this.IgnoreTenantFilter {
 			query = this.setTenantFilter(entity, query)
 		}
 
+		query = this.setSoftDeleteFilter(entity, query)
+
 		err := query.One(entity)
 
 		if err == orm.ErrNoRows {
@@ -680,12 +909,12 @@ func (this *Session) SaveOrUpdate(entity interface{}) error {
 	if model, ok := entity.(Model); ok {
 		if model.IsPersisted() {
 			if err := this.Update(entity); err != nil {
-				return errors.New(fmt.Sprintf("error on update %v: %v", model.TableName(), err))
+				return fmt.Errorf("error on update %v: %w", model.TableName(), err)
 			}
 			return nil
 		}
 		if err := this.Save(entity); err != nil {
-			return errors.New(fmt.Sprintf("error on save %v: %v", model.TableName(), err))
+			return fmt.Errorf("error on save %v: %w", model.TableName(), err)
 		}
 		return nil
 	}
@@ -697,12 +926,14 @@ func (this *Session) SaveOrUpdate(entity interface{}) error {
 func (this *Session) List(entity interface{}, entities interface{}) error {
 	if model, ok := entity.(Model); ok {
 
-		query := this.GetDb().QueryTable(model.TableName())
+		query := this.GetReadDb().QueryTable(model.TableName())
 
 		if !this.IgnoreTenantFilter {
 			query = this.setTenantFilter(entity, query)
 		}
 
+		query = this.setSoftDeleteFilter(entity, query)
+
 		if hook, ok := entity.(ModelHookBeforeQuery); ok {
 			query = hook.BeforeQuery(query)
 		}
@@ -731,7 +962,7 @@ func (this *Session) PageQuery(query orm.QuerySeter, entity interface{}, entitie
 	if model, ok := entity.(Model); ok {
 
 		if query == nil {
-			query = this.GetDb().QueryTable(model.TableName())
+			query = this.GetReadDb().QueryTable(model.TableName())
 		}
 
 		query = query.Limit(page.Limit).Offset(page.Offset)
@@ -769,6 +1000,8 @@ func (this *Session) PageQuery(query orm.QuerySeter, entity interface{}, entitie
 			query = this.setTenantFilter(entity, query)
 		}
 
+		query = this.setSoftDeleteFilter(entity, query)
+
 		if hook, ok := entity.(ModelHookBeforeQuery); ok {
 			query = hook.BeforeQuery(query)
 		}
@@ -798,6 +1031,8 @@ func (this *Session) Query(entity interface{}) (orm.QuerySeter, error) {
 			query = this.setTenantFilter(entity, query)
 		}
 
+		query = this.setSoftDeleteFilter(entity, query)
+
 		return query, nil
 	}
 
@@ -870,13 +1105,13 @@ func (this *Session) ExecuteUpdate(querySeter orm.QuerySeter, args map[string]in
 
 	var count int64
 	var err error
-	var params orm.Params
+	params := orm.Params{}
 
 	for k, v := range args {
 		params[k] = v
 	}
 
-	if _, err := querySeter.Update(params); err != nil {
+	if count, err = querySeter.Update(params); err != nil {
 		logs.Debug("## Session: error on to list: %v", err.Error())
 		//this.SetError()
 		return count, err
@@ -895,20 +1130,44 @@ func (this *Session) RawExecBatch(queries ...string) error {
 }
 
 func (this *Session) RawExec(query string, args ...interface{}) (int64, error) {
+	query, args, err := this.guardRaw(query, args)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
 	res, err := this.GetDb().Raw(query, args...).Exec()
+	var rows int64 = -1
+	if err == nil {
+		rows, err = res.RowsAffected()
+	}
+	this.observeSQL("raw", "", &querySQL{sql: query, args: args}, start, rows, err)
 	if err != nil {
 		return 0, err
 	}
-	return res.RowsAffected()
+	return rows, nil
 }
 
 func (this *Session) RawQuery(query string, args ...interface{}) (sql.Result, error) {
-	return this.GetDb().Raw(query, args...).Exec()
+	query, args, err := this.guardRaw(query, args)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	res, err := this.GetDb().Raw(query, args...).Exec()
+	this.observeSQL("raw", "", &querySQL{sql: query, args: args}, start, -1, err)
+	return res, err
 }
 
 func (this *Session) Rows(query string, args ...interface{}) ([]*Row, error) {
+	query, args, err := this.guardRaw(query, args)
+	if err != nil {
+		return nil, err
+	}
 	var params []orm.Params
-	_, err := this.GetDb().Raw(query, args...).Values(&params)
+	start := time.Now()
+	rows, err := this.GetReadDb().Raw(query, args...).Values(&params)
+	this.observeRead(start)
+	this.observeSQL("raw", "", &querySQL{sql: query, args: args}, start, rows, err)
 
 	if err != nil {
 		return nil, err
@@ -929,8 +1188,15 @@ func (this *Session) FirstRowResult(query string, args ...interface{}) *result.R
 	return result.OfValue(option.Of(row))
 }
 func (this *Session) FirstRow(query string, args ...interface{}) (*Row, error) {
+	query, args, err := this.guardRaw(query, args)
+	if err != nil {
+		return nil, err
+	}
 	var params []orm.Params
-	_, err := this.GetDb().Raw(query, args...).Values(&params)
+	start := time.Now()
+	rows, err := this.GetReadDb().Raw(query, args...).Values(&params)
+	this.observeRead(start)
+	this.observeSQL("raw", "", &querySQL{sql: query, args: args}, start, rows, err)
 
 	if err != nil {
 		return nil, err
@@ -949,16 +1215,45 @@ func (this *Session) FirstRow(query string, args ...interface{}) (*Row, error) {
 	return nil, nil
 }
 
+// Eager load eager tagged relations of entity. Slices are loaded by EagerList, one IN query by relation
 func (this *Session) Eager(reply interface{}) error {
+	if isEagerSlice(reply) {
+		return this.eagerSlice(reply, false)
+	}
 	this.deepEager = map[string]int{}
 	return this.eagerDeep(reply, false)
 }
 
+// EagerForce load all relations of entity, as Eager
 func (this *Session) EagerForce(reply interface{}) error {
+	if isEagerSlice(reply) {
+		return this.eagerSlice(reply, true)
+	}
 	this.deepEager = map[string]int{}
 	return this.eagerDeep(reply, true)
 }
 
+func (this *Session) eagerSlice(reply interface{}, ignoreTag bool) error {
+
+	values := eagerListValues(reflect.ValueOf(reply))
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	paths := this.eagerListPaths(values[0].Elem().Type(), ignoreTag, "", 0)
+
+	return this.EagerList(reply, paths...)
+}
+
+func isEagerSlice(reply interface{}) bool {
+	value := reflect.ValueOf(reply)
+	if value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	return value.Kind() == reflect.Slice
+}
+
 func (this *Session) eagerDeep(reply interface{}, ignoreTag bool) error {
 
 	if reply == nil {
@@ -1660,25 +1955,25 @@ func RunWithNewTransaction[T any](f func(session *Session) (T, error)) (T, error
 	}
 }
 
-func (this *Session) UpdateResult(entity interface{}) result.ResultOfUnit {
+func (this *Session) UpdateResult(entity interface{}) *result.Result[*unit.Unit] {
 	return result.TryUnit(func() error {
 		return this.Update(entity)
 	})
 }
 
-func (this *Session) SaveResult(entity interface{}) result.ResultOfUnit {
+func (this *Session) SaveResult(entity interface{}) *result.Result[*unit.Unit] {
 	return result.TryUnit(func() error {
 		return this.Save(entity)
 	})
 }
 
-func (this *Session) RemoveResult(entity interface{}) result.ResultOfUnit {
+func (this *Session) RemoveResult(entity interface{}) *result.Result[*unit.Unit] {
 	return result.TryUnit(func() error {
 		return this.Remove(entity)
 	})
 }
 
-func (this *Session) SeveOrUpdateResult(entity interface{}) result.ResultOfUnit {
+func (this *Session) SeveOrUpdateResult(entity interface{}) *result.Result[*unit.Unit] {
 	return result.TryUnit(func() error {
 		return this.SaveOrUpdateCascade(entity)
 	})
@@ -0,0 +1,194 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/core/logs"
+)
+
+type SoftDeleteFilter int
+
+const (
+	// default, ignore removed rows
+	SoftDeleteExclude SoftDeleteFilter = iota
+	// include removed rows
+	SoftDeleteInclude
+	// only removed rows
+	SoftDeleteOnly
+)
+
+// soft delete field, tag `goutils:"soft_delete"`. field type should be time.Time, *time.Time or bool
+type softDeleteField struct {
+	name  string
+	value reflect.Value
+}
+
+func (this *softDeleteField) isTime() bool {
+	return this.value.Kind() != reflect.Bool
+}
+
+// value to mark row as removed
+func (this *softDeleteField) deletedValue() interface{} {
+	if this.isTime() {
+		return time.Now()
+	}
+	return true
+}
+
+// condition expression of not removed rows
+func (this *softDeleteField) notDeletedExpr() (string, interface{}) {
+	if this.isTime() {
+		return fmt.Sprintf("%v__isnull", this.name), true
+	}
+	return this.name, false
+}
+
+// condition expression of removed rows
+func (this *softDeleteField) deletedExpr() (string, interface{}) {
+	if this.isTime() {
+		return fmt.Sprintf("%v__isnull", this.name), false
+	}
+	return this.name, true
+}
+
+func (this *softDeleteField) markDeleted() {
+	switch this.value.Kind() {
+	case reflect.Bool:
+		this.value.SetBool(true)
+	case reflect.Ptr:
+		now := time.Now()
+		this.value.Set(reflect.ValueOf(&now))
+	default:
+		this.value.Set(reflect.ValueOf(time.Now()))
+	}
+}
+
+func (this *softDeleteField) markRestored() {
+	this.value.Set(reflect.Zero(this.value.Type()))
+}
+
+// get soft delete field. error when field type is not time.Time, *time.Time or bool
+func (this *Session) getSoftDeleteField(reply interface{}) (*softDeleteField, bool, error) {
+
+	if this.IsNil(reply) {
+		return nil, false, nil
+	}
+
+	fullValue := reflect.ValueOf(reply).Elem()
+	fullType := fullValue.Type()
+
+	if fullType.Kind() != reflect.Struct {
+		return nil, false, nil
+	}
+
+	for i := 0; i < fullType.NumField(); i++ {
+		field := fullType.Field(i)
+		tags := this.getTags(field)
+
+		if this.hasTag(tags, "soft_delete") {
+
+			fieldValue := fullValue.Field(i)
+
+			switch fieldValue.Interface().(type) {
+			case time.Time, *time.Time, bool:
+				return &softDeleteField{name: field.Name, value: fieldValue}, true, nil
+			default:
+				return nil, false, fmt.Errorf("soft delete field %v.%v should be time.Time, *time.Time or bool", fullType.Name(), field.Name)
+			}
+		}
+	}
+
+	return nil, false, nil
+}
+
+// IsSoftDelete check if entity has a field with tag `goutils:"soft_delete"`
+func (this *Session) IsSoftDelete(entity interface{}) bool {
+	_, ok, err := this.getSoftDeleteField(entity)
+	return ok && err == nil
+}
+
+func (this *Session) SetIgnoreSoftDeleteFilter() *Session {
+	this.IgnoreSoftDeleteFilter = true
+	return this
+}
+
+func (this *Session) SetUseSoftDeleteFilter(s bool) *Session {
+	this.IgnoreSoftDeleteFilter = !s
+	return this
+}
+
+func RunWithDeleted[T any](s *Session, f func(s *Session) T) T {
+	ignore := s.IgnoreSoftDeleteFilter
+	defer func() { s.IgnoreSoftDeleteFilter = ignore }()
+	s.IgnoreSoftDeleteFilter = true
+	return f(s)
+}
+
+// filter removed rows of query
+func (this *Session) setSoftDeleteFilter(entity interface{}, query orm.QuerySeter) orm.QuerySeter {
+
+	if this.IgnoreSoftDeleteFilter {
+		return query
+	}
+
+	field, ok, err := this.getSoftDeleteField(entity)
+
+	if err != nil {
+		logs.Error("soft delete filter: %v", err)
+	}
+
+	if ok {
+		expr, value := field.notDeletedExpr()
+		query = query.Filter(expr, value)
+	}
+
+	return query
+}
+
+// mark entity as removed
+func (this *Session) softRemove(entity interface{}, field *softDeleteField) error {
+
+	field.markDeleted()
+
+	if _, err := this.GetDb().Update(entity, field.name); err != nil {
+		field.markRestored()
+		return err
+	}
+
+	return nil
+}
+
+// Restore unmark removed entity. entity should has tag `goutils:"soft_delete"`
+func (this *Session) Restore(entity interface{}) error {
+
+	field, ok, err := this.getSoftDeleteField(entity)
+
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return fmt.Errorf("entity %v does not has soft delete field", getTypeName(entity))
+	}
+
+	if !this.checkIsAuthorizedTenant(entity, "Session.Restore") {
+		this.SetError()
+		return errors.New("Tenant not authorized for entity data access. Operation: Session.Restore.")
+	}
+
+	field.markRestored()
+
+	if _, err := this.GetDb().Update(entity, field.name); err != nil {
+		logs.Error("restore error: %v", err)
+		this.SetError()
+		return err
+	}
+
+	this.invalidateQueryCache(entity)
+
+	return nil
+}
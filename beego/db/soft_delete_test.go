@@ -0,0 +1,87 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/mobilemindtech/go-utils/beego/db"
+)
+
+func countProducts(t *testing.T, c *db.Criteria) int64 {
+	t.Helper()
+	if c.Count(); c.HasError {
+		t.Fatal(c.Error)
+	}
+	return c.Count64
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestSoftDelete
+func TestSoftDelete(t *testing.T) {
+
+	session, _ := newTenantSession(t, "soft delete")
+
+	p1, p2 := &Product{Code: "p1"}, &Product{Code: "p2"}
+	saveProducts(t, session, p1, p2)
+
+	if err := session.Remove(p1); err != nil {
+		t.Fatal(err)
+	}
+
+	if p1.DeletedAt == nil {
+		t.Errorf("expected DeletedAt of removed entity")
+	}
+
+	if count := countProducts(t, db.NewCriteria(session, new(Product), nil)); count != 1 {
+		t.Errorf("expected 1 product, got %v", count)
+	}
+
+	if count := countProducts(t, db.NewCriteria(session, new(Product), nil).WithDeleted()); count != 2 {
+		t.Errorf("expected 2 products with deleted, got %v", count)
+	}
+
+	if count := countProducts(t, db.NewCriteria(session, new(Product), nil).OnlyDeleted()); count != 1 {
+		t.Errorf("expected 1 deleted product, got %v", count)
+	}
+
+	if err := session.Restore(p1); err != nil {
+		t.Fatal(err)
+	}
+
+	if count := countProducts(t, db.NewCriteria(session, new(Product), nil)); count != 2 {
+		t.Errorf("expected 2 products after restore, got %v", count)
+	}
+
+	if c := db.NewCriteria(session, new(Product), nil).Eq("Code", "p2").Delete(); c.HasError {
+		t.Fatal(c.Error)
+	}
+
+	if count := countProducts(t, db.NewCriteria(session, new(Product), nil).OnlyDeleted()); count != 1 {
+		t.Errorf("expected criteria delete as soft delete, got %v deleted", count)
+	}
+}
+
+type invalidSoftDelete struct {
+	Id        int64
+	DeletedAt string `goutils:"soft_delete"`
+}
+
+func (this *invalidSoftDelete) TableName() string {
+	return "invalid_soft_deletes"
+}
+
+func (this *invalidSoftDelete) IsPersisted() bool {
+	return this.Id > 0
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestSoftDeleteInvalidField
+func TestSoftDeleteInvalidField(t *testing.T) {
+
+	session := db.NewSession()
+
+	if err := session.Remove(&invalidSoftDelete{Id: 1}); err == nil {
+		t.Errorf("expected error of invalid soft delete field type")
+	}
+
+	if err := session.Restore(&invalidSoftDelete{Id: 1}); err == nil {
+		t.Errorf("expected error of invalid soft delete field type")
+	}
+}
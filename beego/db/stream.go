@@ -0,0 +1,101 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+)
+
+const DefaultStreamBatchSize = 500
+
+// criteria conditions state, restored after each execute of stream
+type criteriaSnapshot struct {
+	criterias         int
+	criteriasOr       int
+	criteriasAnd      int
+	criteriasAndOr    int
+	criteriasAndOrAnd int
+	criteriasOrAnd    int
+	orderBy           []*CriteriaOrder
+}
+
+func (this *Criteria) snapshot() *criteriaSnapshot {
+	return &criteriaSnapshot{
+		criterias:         len(this.criterias),
+		criteriasOr:       len(this.criteriasOr),
+		criteriasAnd:      len(this.criteriasAnd),
+		criteriasAndOr:    len(this.criteriasAndOr),
+		criteriasAndOrAnd: len(this.criteriasAndOrAnd),
+		criteriasOrAnd:    len(this.criteriasOrAnd),
+		orderBy:           this.orderBy,
+	}
+}
+
+func (this *Criteria) restore(s *criteriaSnapshot) {
+	this.criterias = this.criterias[:s.criterias]
+	this.criteriasOr = this.criteriasOr[:s.criteriasOr]
+	this.criteriasAnd = this.criteriasAnd[:s.criteriasAnd]
+	this.criteriasAndOr = this.criteriasAndOr[:s.criteriasAndOr]
+	this.criteriasAndOrAnd = this.criteriasAndOrAnd[:s.criteriasAndOrAnd]
+	this.criteriasOrAnd = this.criteriasOrAnd[:s.criteriasOrAnd]
+	this.orderBy = s.orderBy
+}
+
+// Stream list results in batches of primary key ranges (Id > last id), so memory stays bounded.
+// Results is reset on each batch and passed to fn, return false to stop.
+// The criteria ordering is replaced by Id asc, tenant filter, eager relations and Distinct are kept.
+// Conditions, ordering, Limit and Offset are restored when the stream ends
+func (this *Criteria) Stream(batchSize int64, fn func(results interface{}) bool) error {
+
+	if this.Results == nil {
+		return errors.New("Results can't be nil")
+	}
+
+	if batchSize <= 0 {
+		batchSize = DefaultStreamBatchSize
+	}
+
+	original := this.snapshot()
+	limit, offset := this.Limit, this.Offset
+
+	defer func() {
+		this.restore(original)
+		this.Limit, this.Offset = limit, offset
+	}()
+
+	idCond := &Criteria{Path: "Id", Value: int64(0), Expression: Gt}
+
+	this.criterias = append(this.criterias, idCond)
+	this.orderBy = []*CriteriaOrder{&CriteriaOrder{Path: "Id"}}
+	this.Limit = batchSize
+	this.Offset = 0
+
+	state := this.snapshot()
+	resultsType := reflect.TypeOf(this.Results).Elem()
+
+	for {
+
+		results := reflect.ValueOf(this.Results).Elem()
+		results.Set(reflect.MakeSlice(resultsType, 0, int(batchSize)))
+
+		this.execute(CriteriaList)
+		this.restore(state)
+
+		if this.HasError {
+			return this.Error
+		}
+
+		count := results.Len()
+
+		if count == 0 {
+			return nil
+		}
+
+		last := results.Index(count - 1).Interface()
+
+		if !fn(this.Results) || int64(count) < batchSize {
+			return nil
+		}
+
+		idCond.Value = this.Session.getEntityId(last)
+	}
+}
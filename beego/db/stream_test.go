@@ -0,0 +1,56 @@
+package db_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mobilemindtech/go-utils/beego/db"
+)
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestStream
+func TestStream(t *testing.T) {
+
+	session, _ := newTenantSession(t, "stream")
+
+	for i := 1; i <= 5; i++ {
+		saveProducts(t, session, &Product{Code: fmt.Sprintf("p%v", i), Price: float64(i)})
+	}
+
+	c := db.NewCriteria(session, new(Product), &[]*Product{}).
+		Gt("Price", 1).
+		OrderDesc("Price").
+		SetLimit(3).
+		SetOffset(1)
+
+	codes := []string{}
+	batches := 0
+
+	err := c.Stream(2, func(results interface{}) bool {
+		batches++
+		for _, it := range *results.(*[]*Product) {
+			codes = append(codes, it.Code)
+		}
+		return true
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "[p2 p3 p4 p5]"
+
+	if fmt.Sprint(codes) != expected || batches != 2 {
+		t.Errorf("expected %v in 2 batches, got %v in %v", expected, codes, batches)
+	}
+
+	if c.Limit != 3 || c.Offset != 1 {
+		t.Errorf("expected limit and offset restored, got %v %v", c.Limit, c.Offset)
+	}
+
+	// criteria is reusable with its own conditions and ordering
+	products := listProducts(t, c)
+
+	if len(products) != 3 || products[0].Code != "p4" {
+		t.Errorf("expected p4, p3 and p2, got %v", len(products))
+	}
+}
@@ -0,0 +1,118 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+)
+
+// InSubquery filter path in values selected by subquery. Subquery select Id, or path of Select.
+// Subquery has its own tenant filter. eg.: c.InSubquery("User", NewCriteria(s, new(TenantUser), nil).Eq("Tenant", t).Select("User"))
+func (this *Criteria) InSubquery(path string, subquery *Criteria) *Criteria {
+	this.criterias = append(this.criterias, &Criteria{Path: path, Expression: InSubquery, Subquery: subquery})
+	return this
+}
+
+func (this *Criteria) NotInSubquery(path string, subquery *Criteria) *Criteria {
+	this.criterias = append(this.criterias, &Criteria{Path: path, Expression: NotInSubquery, Subquery: subquery})
+	return this
+}
+
+// ExistsSubquery filter rows where subquery has results. Use EqOuter on subquery to correlate with this query.
+// eg.: c.ExistsSubquery(NewCriteria(s, new(UserRole), nil).EqOuter("User", "Id"))
+func (this *Criteria) ExistsSubquery(subquery *Criteria) *Criteria {
+	this.criterias = append(this.criterias, &Criteria{Path: "Id", Expression: ExistsSubquery, Subquery: subquery})
+	return this
+}
+
+func (this *Criteria) NotExistsSubquery(subquery *Criteria) *Criteria {
+	this.criterias = append(this.criterias, &Criteria{Path: "Id", Expression: NotExistsSubquery, Subquery: subquery})
+	return this
+}
+
+// EqOuter compare path with outerPath of outer query, to correlated subqueries
+func (this *Criteria) EqOuter(path string, outerPath string) *Criteria {
+	this.criterias = append(this.criterias, &Criteria{Path: path, Expression: EqOuter, Value: outerPath})
+	return this
+}
+
+// Select path of subquery result, default is Id
+func (this *Criteria) Select(path string) *Criteria {
+	this.selectPath = path
+	return this
+}
+
+// subquery condition of criteria, written after the path column: IN (SELECT ...), NOT IN (SELECT ...),
+// EXISTS (SELECT ...) or NOT EXISTS (SELECT ...). EXISTS does not use the column
+func (this *Criteria) subquerySQL(criteria *Criteria) (CriteriaExpression, string, []interface{}, error) {
+
+	sub := criteria.Subquery
+	kind := criteria.Expression
+
+	if sub.Session == nil {
+		sub.Session = this.Session
+	}
+
+	if sub.Result == nil {
+		return kind, "", nil, errors.New("subquery entity can't be nil")
+	}
+
+	sub.sqlOuter = this
+	sub.sqlAlias = nextSQLAlias(this.getSQLAlias())
+
+	switch kind {
+	case ExistsSubquery, NotExistsSubquery:
+
+		query, args, err := sub.SelectSQL("1")
+
+		if err != nil {
+			return kind, "", nil, err
+		}
+
+		if kind == NotExistsSubquery {
+			return kind, fmt.Sprintf("NOT EXISTS (%v)", query), args, nil
+		}
+		return kind, fmt.Sprintf("EXISTS (%v)", query), args, nil
+
+	case InSubquery, NotInSubquery:
+
+		selectPath := sub.selectPath
+		if len(selectPath) == 0 {
+			selectPath = "Id"
+		}
+
+		selectColumn, err := sub.SQLColumn(selectPath)
+
+		if err != nil {
+			return kind, "", nil, err
+		}
+
+		query, args, err := sub.SelectSQL(selectColumn)
+
+		if err != nil {
+			return kind, "", nil, err
+		}
+
+		if kind == NotInSubquery {
+			return kind, fmt.Sprintf("NOT IN (%v)", query), args, nil
+		}
+		return kind, fmt.Sprintf("IN (%v)", query), args, nil
+	}
+
+	return kind, "", nil, fmt.Errorf("invalid subquery expression %v", kind)
+}
+
+// column of outer query, to correlated subquery
+func (this *Criteria) outerSQLColumn(path string) (string, error) {
+	if this.sqlOuter == nil {
+		return "", errors.New("EqOuter should be used on subquery")
+	}
+	return this.sqlOuter.SQLColumn(path)
+}
+
+func nextSQLAlias(alias string) string {
+	var n int
+	if _, err := fmt.Sscanf(alias, "S%d", &n); err != nil {
+		n = 0
+	}
+	return fmt.Sprintf("S%v", n+1)
+}
@@ -0,0 +1,131 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/mobilemindtech/go-utils/beego/db"
+)
+
+func subqueryFixtures(t *testing.T) (*db.Session, *Category, *Category) {
+
+	t.Helper()
+
+	session, _ := newTenantSession(t, "subquery")
+
+	books, music := &Category{Name: "books"}, &Category{Name: "music"}
+
+	for _, it := range []*Category{books, music} {
+		if err := session.Save(it); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	saveProducts(t, session,
+		&Product{Code: "p1", Name: "o'reilly ?", Price: 10, Category: books},
+		&Product{Code: "p2", Name: "cheap", Price: 1, Category: books},
+		&Product{Code: "p3", Name: "none", Price: 100})
+
+	return session, books, music
+}
+
+func listCategories(t *testing.T, c *db.Criteria) []*Category {
+	t.Helper()
+	if c.List(); c.HasError {
+		t.Fatal(c.Error)
+	}
+	return *c.Results.(*[]*Category)
+}
+
+func listProducts(t *testing.T, c *db.Criteria) []*Product {
+	t.Helper()
+	if c.List(); c.HasError {
+		t.Fatal(c.Error)
+	}
+	return *c.Results.(*[]*Product)
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestRawCriteria
+func TestRawCriteria(t *testing.T) {
+
+	session, _, _ := subqueryFixtures(t)
+
+	products := listProducts(t, db.NewCriteria(session, new(Product), &[]*Product{}).Raw("Price", "> ?", 5).OrderAsc("Code"))
+
+	if len(products) != 2 || products[0].Code != "p1" {
+		t.Errorf("expected p1 and p3, got %v", len(products))
+	}
+
+	// text values are not inlined
+	c := db.NewCriteria(session, new(Product), &[]*Product{}).Raw("Name", "= ?", "o'reilly ?").List()
+
+	if !c.HasError {
+		t.Errorf("expected error of raw text value")
+	}
+
+	products = listProducts(t, db.NewCriteria(session, new(Product), &[]*Product{}).Raw("Price", "IN (?)", []float64{1, 100}))
+
+	if len(products) != 2 {
+		t.Errorf("expected p2 and p3, got %v", len(products))
+	}
+
+	// raw on or condition
+	products = listProducts(t, db.NewCriteria(session, new(Product), &[]*Product{}).
+		AndOr(db.NewCondition().Eq("Code", "p2").Raw("Price", ">= ?", 100)))
+
+	if len(products) != 2 {
+		t.Errorf("expected p2 and p3, got %v", len(products))
+	}
+
+	c = db.NewCriteria(session, new(Product), &[]*Product{}).Raw("Price", "> ? AND ?", 5).List()
+
+	if !c.HasError {
+		t.Errorf("expected error of missing raw value")
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestSubquery
+func TestSubquery(t *testing.T) {
+
+	session, books, music := subqueryFixtures(t)
+
+	expensive := db.NewCriteria(session, new(Product), nil).Raw("Price", "> ?", 5).Eq("Category", books).Select("Category")
+
+	categories := listCategories(t, db.NewCriteria(session, new(Category), &[]*Category{}).InSubquery("Id", expensive))
+
+	if len(categories) != 1 || categories[0].Id != books.Id {
+		t.Errorf("expected books, got %v", len(categories))
+	}
+
+	withProducts := db.NewCriteria(session, new(Product), nil).EqOuter("Category", "Id")
+
+	categories = listCategories(t, db.NewCriteria(session, new(Category), &[]*Category{}).ExistsSubquery(withProducts))
+
+	if len(categories) != 1 || categories[0].Id != books.Id {
+		t.Errorf("expected books, got %v", len(categories))
+	}
+
+	withProducts = db.NewCriteria(session, new(Product), nil).EqOuter("Category", "Id")
+
+	categories = listCategories(t, db.NewCriteria(session, new(Category), &[]*Category{}).NotExistsSubquery(withProducts))
+
+	if len(categories) != 1 || categories[0].Id != music.Id {
+		t.Errorf("expected music, got %v", len(categories))
+	}
+
+	// subquery has its own tenant filter
+	company := &Company{Name: "other"}
+
+	if err := session.Save(company); err != nil {
+		t.Fatal(err)
+	}
+
+	other := db.NewSessionWithTenant(company).SetDatabase(session.GetDb())
+
+	withProducts = db.NewCriteria(other, new(Product), nil).EqOuter("Category", "Id")
+
+	categories = listCategories(t, db.NewCriteria(session, new(Category), &[]*Category{}).ExistsSubquery(withProducts))
+
+	if len(categories) != 0 {
+		t.Errorf("expected no categories of other tenant products, got %v", len(categories))
+	}
+}
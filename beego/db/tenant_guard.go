@@ -0,0 +1,561 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/beego/beego/v2/core/logs"
+)
+
+type TenantGuardMode int
+
+const (
+	TenantGuardOff TenantGuardMode = iota
+	// reject raw sql of tenant tables without tenant_id = ? of session tenant
+	TenantGuardReject
+	// inject tenant_id = ? on WHERE of top level tables, reject when not possible (insert, subqueries, union)
+	TenantGuardInject
+)
+
+var ErrTenantGuard = errors.New("tenant guard")
+
+var (
+	tenantGuardMode   TenantGuardMode
+	tenantGuardTables = map[string]string{}
+	tenantGuardMu     sync.RWMutex
+)
+
+// SetTenantGuard guard raw sql (Session.RawExec, RawQuery, Rows, FirstRow and db.RawQuery) of sessions with tenant.
+// models with tenant filter (Tenant field with goutils:"tenant") are guarded. Use Session.UnsafeRaw to bypass.
+// eg.: db.SetTenantGuard(db.TenantGuardReject, new(models.Customer), new(models.Order))
+func SetTenantGuard(mode TenantGuardMode, models ...interface{}) {
+
+	tenantGuardMu.Lock()
+	defer tenantGuardMu.Unlock()
+
+	tenantGuardMode = mode
+
+	for _, model := range models {
+
+		if !new(Session).HasFilterTenant(model) {
+			continue
+		}
+
+		table := ""
+
+		if m, ok := model.(Model); ok {
+			table = m.TableName()
+		} else {
+			table = SnakeString(getTypeName(model))
+		}
+
+		for _, column := range getModelColumns(model) {
+			if column.field == "Tenant" {
+				tenantGuardTables[strings.ToLower(table)] = strings.ToLower(column.column)
+			}
+		}
+	}
+}
+
+// UnsafeRaw disable tenant guard of raw sql, to cross tenant admin jobs
+func (this *Session) UnsafeRaw() *Session {
+	this.unsafeRaw = true
+	return this
+}
+
+// SafeRaw undo UnsafeRaw
+func (this *Session) SafeRaw() *Session {
+	this.unsafeRaw = false
+	return this
+}
+
+// guard raw sql of session tenant. returns query and args with tenant constraint
+func (this *Session) guardRaw(query string, args []interface{}) (string, []interface{}, error) {
+
+	tenantGuardMu.RLock()
+	mode := tenantGuardMode
+	tenantGuardMu.RUnlock()
+
+	if mode == TenantGuardOff || this.unsafeRaw || this.IgnoreTenantFilter || !this.HasTenant() {
+		return query, args, nil
+	}
+
+	tenant, ok := this.Tenant.(TenantModel)
+
+	if !ok {
+		return query, args, nil
+	}
+
+	guarded, guardedArgs, err := guardTenantSQL(mode, query, args, tenant.GetId())
+
+	if err != nil {
+		logs.Error("## tenant guard: %v: %v", err, query)
+		return "", nil, err
+	}
+
+	if this.Debug && guarded != query {
+		logs.Debug("## tenant guard injected: %v", guarded)
+	}
+
+	return guarded, guardedArgs, nil
+}
+
+type sqlToken struct {
+	text  string
+	start int
+	end   int
+	depth int
+}
+
+type sqlTableRef struct {
+	name      string
+	qualifier string
+	column    string
+	depth     int
+}
+
+var sqlClauseKeywords = map[string]bool{
+	"where": true, "group": true, "order": true, "limit": true, "having": true, "offset": true, "union": true,
+	"returning": true, "for": true, "window": true, "intersect": true, "except": true, "set": true, "on": true,
+	"inner": true, "left": true, "right": true, "full": true, "cross": true, "join": true, "natural": true,
+	"values": true, "using": true, "select": true, "outer": true,
+}
+
+// tokens of identifiers (with dots), placeholders and punctuation. literals, comments and quotes are skipped
+func tokenizeSQL(query string) []*sqlToken {
+
+	tokens := []*sqlToken{}
+	depth := 0
+
+	for i := 0; i < len(query); {
+
+		c := query[i]
+
+		switch {
+		case c == '\'':
+			// string literal, '' is escaped quote
+			i++
+			for i < len(query) {
+				if query[i] == '\'' {
+					if i+1 < len(query) && query[i+1] == '\'' {
+						i += 2
+						continue
+					}
+					break
+				}
+				i++
+			}
+			i++
+		case c == '-' && i+1 < len(query) && query[i+1] == '-':
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				i = len(query)
+			} else {
+				i += end + 4
+			}
+		case c == '"' || c == '`' || c == '.' || c == '_' || isSQLIdentChar(c):
+			start := i
+			for i < len(query) && (query[i] == '"' || query[i] == '`' || query[i] == '.' || query[i] == '_' || isSQLIdentChar(query[i])) {
+				i++
+			}
+			text := strings.ToLower(strings.NewReplacer(`"`, "", "`", "").Replace(query[start:i]))
+			tokens = append(tokens, &sqlToken{text: text, start: start, end: i, depth: depth})
+		case c == '(':
+			tokens = append(tokens, &sqlToken{text: "(", start: i, end: i + 1, depth: depth})
+			depth++
+			i++
+		case c == ')':
+			depth--
+			tokens = append(tokens, &sqlToken{text: ")", start: i, end: i + 1, depth: depth})
+			i++
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		default:
+			// operators as <> and >= are splitted, only = is checked
+			tokens = append(tokens, &sqlToken{text: string(c), start: i, end: i + 1, depth: depth})
+			i++
+		}
+	}
+
+	return tokens
+}
+
+func isSQLIdentChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '$'
+}
+
+// tenant tables referenced by query
+func findTenantTables(tokens []*sqlToken) []*sqlTableRef {
+
+	tenantGuardMu.RLock()
+	defer tenantGuardMu.RUnlock()
+
+	refs := []*sqlTableRef{}
+
+	for i := 0; i < len(tokens); i++ {
+
+		switch tokens[i].text {
+		case "from", "join", "update", "into":
+		default:
+			continue
+		}
+
+		list := tokens[i].text == "from"
+
+		for j := i + 1; j < len(tokens); {
+
+			if tokens[j].text == "(" {
+				break
+			}
+
+			name := tokens[j].text
+			qualifier := name
+			j++
+
+			if j < len(tokens) && tokens[j].text == "as" {
+				j++
+			}
+
+			if j < len(tokens) && !sqlClauseKeywords[tokens[j].text] && isSQLIdent(tokens[j].text) {
+				qualifier = tokens[j].text
+				j++
+			}
+
+			table := name[strings.LastIndex(name, ".")+1:]
+
+			if column, ok := tenantGuardTables[table]; ok {
+				refs = append(refs, &sqlTableRef{name: name, qualifier: qualifier, column: column, depth: tokens[i].depth})
+			}
+
+			if !list || j >= len(tokens) || tokens[j].text != "," {
+				break
+			}
+
+			j++
+		}
+	}
+
+	return refs
+}
+
+func isSQLIdent(text string) bool {
+	c := text[0]
+	return c == '_' || (c >= 'a' && c <= 'z')
+}
+
+// table constrained by qualifier.tenant_id = ? bound to session tenant, unqualified when single tenant table, or on
+// insert columns with tenant placeholders. literals, IN and conditions under OR of same or enclosing level are not constraints
+func isTenantConstrained(tokens []*sqlToken, ref *sqlTableRef, single bool, args []interface{}, tenantId int64) bool {
+
+	for i := 0; i+1 < len(tokens); i++ {
+
+		text := tokens[i].text
+		column := text[strings.LastIndex(text, ".")+1:]
+
+		if column != ref.column {
+			continue
+		}
+
+		qualified := text == ref.qualifier+"."+ref.column || text == ref.name+"."+ref.column
+
+		if !qualified && (text != ref.column || !single) {
+			continue
+		}
+
+		next := tokens[i+1].text
+
+		// <=, >= and != are tokenized as two tokens. assignments of UPDATE SET are not constraints
+		if next == "=" && i+2 < len(tokens) && !isSQLAssignment(tokens, i) {
+			if isTenantPlaceholder(tokens, i+2, args, tenantId) && !hasSQLOrAround(tokens, i, ref.depth) {
+				return true
+			}
+			continue
+		}
+
+		// insert column list
+		if next == "," || next == ")" {
+			for j := i - 1; j >= 0; j-- {
+				if tokens[j].text == "into" {
+					return isTenantInsertValues(tokens, i, args, tenantId)
+				}
+				if tokens[j].text == "values" || tokens[j].text == "select" {
+					break
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// OR on depth of constraint token i, or of any enclosing parentheses up to the WHERE or ON clause of depth,
+// that can bypass the constraint. eg.: where (tenant_id = ?) or 1 = 1
+func hasSQLOrAround(tokens []*sqlToken, i int, clauseDepth int) bool {
+
+	for depth := tokens[i].depth; depth > clauseDepth; depth-- {
+
+		for j := i; j >= 0 && tokens[j].depth >= depth; j-- {
+			if tokens[j].depth == depth && tokens[j].text == "or" {
+				return true
+			}
+		}
+
+		for j := i; j < len(tokens) && tokens[j].depth >= depth; j++ {
+			if tokens[j].depth == depth && tokens[j].text == "or" {
+				return true
+			}
+		}
+	}
+
+	if tokens[i].depth < clauseDepth {
+		clauseDepth = tokens[i].depth
+	}
+
+	return hasSQLOr(tokens, clauseDepth)
+}
+
+// OR on depth, that can bypass a constraint of same depth
+func hasSQLOr(tokens []*sqlToken, depth int) bool {
+	for _, it := range tokens {
+		if it.depth == depth && it.text == "or" {
+			return true
+		}
+	}
+	return false
+}
+
+// args with slices expanded, as placeholders of raw sql
+func flattenSQLArgs(args []interface{}) []interface{} {
+	flat := []interface{}{}
+	for _, arg := range args {
+		value := reflect.ValueOf(arg)
+		if arg != nil && (value.Kind() == reflect.Slice || value.Kind() == reflect.Array) && value.Type().Elem().Kind() != reflect.Uint8 {
+			for i := 0; i < value.Len(); i++ {
+				flat = append(flat, value.Index(i).Interface())
+			}
+			continue
+		}
+		flat = append(flat, arg)
+	}
+	return flat
+}
+
+// token i is ? or $n placeholder of tenant id
+func isTenantPlaceholder(tokens []*sqlToken, i int, args []interface{}, tenantId int64) bool {
+
+	index := -1
+
+	if tokens[i].text == "?" {
+		index = 0
+		for j := 0; j < i; j++ {
+			if tokens[j].text == "?" {
+				index++
+			}
+		}
+	} else if n, err := strconv.Atoi(strings.TrimPrefix(tokens[i].text, "$")); err == nil && strings.HasPrefix(tokens[i].text, "$") {
+		index = n - 1
+	}
+
+	if index < 0 || index >= len(args) {
+		return false
+	}
+
+	if model, ok := args[index].(TenantModel); ok {
+		return model.GetId() == tenantId
+	}
+
+	return fmt.Sprint(args[index]) == fmt.Sprint(tenantId)
+}
+
+// column i of insert column list has tenant placeholder on all VALUES rows. INSERT SELECT is not constrained
+func isTenantInsertValues(tokens []*sqlToken, i int, args []interface{}, tenantId int64) bool {
+
+	depth := tokens[i].depth
+	position := 0
+	j := i - 1
+
+	for ; j >= 0 && tokens[j].depth >= depth; j-- {
+		if tokens[j].depth == depth && tokens[j].text == "," {
+			position++
+		}
+	}
+
+	for j = i + 1; j < len(tokens) && !(tokens[j].depth == depth-1 && tokens[j].text == "values"); j++ {
+	}
+
+	rows := 0
+
+	for j++; j < len(tokens) && tokens[j].text == "(" && tokens[j].depth == depth-1; {
+
+		item := 0
+		value := []int{}
+
+		for j++; j < len(tokens) && tokens[j].depth >= depth; j++ {
+			if tokens[j].depth == depth && tokens[j].text == "," {
+				item++
+			} else if item == position {
+				value = append(value, j)
+			}
+		}
+
+		if len(value) != 1 || !isTenantPlaceholder(tokens, value[0], args, tenantId) {
+			return false
+		}
+
+		rows++
+
+		// ) and , of next row
+		if j++; j < len(tokens) && tokens[j].text == "," && tokens[j].depth == depth-1 {
+			j++
+		}
+	}
+
+	return rows > 0
+}
+
+// token i is on UPDATE SET or SELECT columns, by nearest clause of same depth
+func isSQLAssignment(tokens []*sqlToken, i int) bool {
+	for j := i - 1; j >= 0; j-- {
+		if tokens[j].depth != tokens[i].depth {
+			continue
+		}
+		switch tokens[j].text {
+		case "set", "select":
+			return true
+		case "where", "on", "having", "from", "join":
+			return false
+		}
+	}
+	return false
+}
+
+func guardTenantSQL(mode TenantGuardMode, query string, args []interface{}, tenantId int64) (string, []interface{}, error) {
+
+	tokens := tokenizeSQL(query)
+	refs := findTenantTables(tokens)
+
+	if len(refs) == 0 {
+		return query, args, nil
+	}
+
+	missing := []*sqlTableRef{}
+	bound := flattenSQLArgs(args)
+
+	for _, ref := range refs {
+		if !isTenantConstrained(tokens, ref, len(refs) == 1, bound, tenantId) {
+			missing = append(missing, ref)
+		}
+	}
+
+	if len(missing) == 0 {
+		return query, args, nil
+	}
+
+	names := []string{}
+	for _, ref := range missing {
+		names = append(names, ref.name)
+	}
+
+	if mode != TenantGuardInject {
+		return "", nil, fmt.Errorf("%w: tenant column not constrained on %v", ErrTenantGuard, strings.Join(names, ", "))
+	}
+
+	return injectTenantSQL(query, args, tokens, missing, tenantId)
+}
+
+// inject qualifier.tenant_id = ? on top level WHERE
+func injectTenantSQL(query string, args []interface{}, tokens []*sqlToken, refs []*sqlTableRef, tenantId int64) (string, []interface{}, error) {
+
+	kind := tokens[0].text
+
+	if kind != "select" && kind != "update" && kind != "delete" {
+		return "", nil, fmt.Errorf("%w: can't inject tenant on %v", ErrTenantGuard, kind)
+	}
+
+	for _, it := range tokens {
+		if it.depth == 0 && (it.text == "union" || it.text == "intersect" || it.text == "except") {
+			return "", nil, fmt.Errorf("%w: can't inject tenant on %v", ErrTenantGuard, it.text)
+		}
+		if it.text == ";" && it.end < len(strings.TrimRight(query, "; \t\n\r")) {
+			return "", nil, fmt.Errorf("%w: multiple statements", ErrTenantGuard)
+		}
+		if strings.HasPrefix(it.text, "$") {
+			return "", nil, fmt.Errorf("%w: can't inject tenant with $n placeholders", ErrTenantGuard)
+		}
+	}
+
+	conds := []string{}
+
+	for _, ref := range refs {
+		if ref.depth > 0 {
+			return "", nil, fmt.Errorf("%w: tenant column not constrained on subquery table %v", ErrTenantGuard, ref.name)
+		}
+		conds = append(conds, fmt.Sprintf("%v.%v = ?", ref.qualifier, ref.column))
+	}
+
+	predicate := strings.Join(conds, " AND ")
+	limit := len(strings.TrimRight(query, "; \t\n\r"))
+	where := -1
+	end := limit
+
+	// top level WHERE and first clause after it
+	for i, it := range tokens {
+
+		if i == 0 || it.depth != 0 || it.start >= limit {
+			continue
+		}
+
+		switch it.text {
+		case "where":
+			if where < 0 {
+				where = i
+			}
+		case "group", "order", "limit", "having", "offset", "returning", "for", "window":
+			if end == limit {
+				end = it.start
+			}
+		}
+	}
+
+	var guarded string
+	var at int
+
+	if where >= 0 {
+		// WHERE tenant AND (conditions)
+		whereEnd := tokens[where].end
+		guarded = fmt.Sprintf("%v %v AND (%v) %v", query[:whereEnd], predicate, strings.TrimSpace(query[whereEnd:end]), query[end:])
+		at = whereEnd
+	} else {
+		guarded = fmt.Sprintf("%v WHERE %v %v", strings.TrimRight(query[:end], " \t\n\r"), predicate, query[end:])
+		at = end
+	}
+
+	// tenant args position
+	index := 0
+	for _, it := range tokens {
+		if it.text == "?" && it.start < at {
+			index++
+		}
+	}
+
+	if index > len(args) {
+		return "", nil, fmt.Errorf("%w: args count does not match placeholders", ErrTenantGuard)
+	}
+
+	guardedArgs := make([]interface{}, 0, len(args)+len(refs))
+	guardedArgs = append(guardedArgs, args[:index]...)
+	for range refs {
+		guardedArgs = append(guardedArgs, tenantId)
+	}
+	guardedArgs = append(guardedArgs, args[index:]...)
+
+	return strings.TrimSpace(guarded), guardedArgs, nil
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestTenantGuard
+func TestTenantGuard(t *testing.T) {
+
+	tenantGuardMu.Lock()
+	tenantGuardTables["guarded"] = "tenant_id"
+	tenantGuardMu.Unlock()
+
+	defer func() {
+		tenantGuardMu.Lock()
+		delete(tenantGuardTables, "guarded")
+		tenantGuardMu.Unlock()
+	}()
+
+	tests := []struct {
+		name  string
+		query string
+		args  []interface{}
+		valid bool
+	}{
+		{"bound tenant", "select * from guarded where tenant_id = ?", []interface{}{7}, true},
+		{"qualified tenant", "select * from guarded g where g.tenant_id = ? and g.code = ?", []interface{}{int64(7), "a"}, true},
+		{"or inside parentheses", "select * from guarded g where g.tenant_id = ? and (g.name = ? or g.code = ?)", []interface{}{7, "a", "b"}, true},
+		{"expanded slice args", "select * from guarded where code in (?, ?) and tenant_id = ?", []interface{}{[]string{"a", "b"}, 7}, true},
+		{"numbered placeholders", "select * from guarded where code = $1 and tenant_id = $2", []interface{}{"a", 7}, true},
+		{"insert values", "insert into guarded (code, tenant_id) values (?, ?), (?, ?)", []interface{}{"a", 7, "b", 7}, true},
+		{"other tenant", "select * from guarded where tenant_id = ?", []interface{}{8}, false},
+		{"literal tenant", "select * from guarded where tenant_id = 7", nil, false},
+		{"top level or", "select * from guarded where tenant_id = ? or 1 = 1", []interface{}{7}, false},
+		{"or out of parentheses", "select * from guarded where (tenant_id = ?) or 1 = 1", []interface{}{7}, false},
+		{"or out of nested parentheses", "select * from guarded where ((tenant_id = ?)) or id > 0", []interface{}{7}, false},
+		{"or of enclosing parentheses", "select * from guarded where code = ? and ((tenant_id = ? and id > 0) or 1 = 1)", []interface{}{"a", 7}, false},
+		{"nested and", "select * from guarded where ((tenant_id = ?) and (code = ? or id > 0))", []interface{}{7, "a"}, true},
+		{"tenant in", "select * from guarded where tenant_id in (?)", []interface{}{7}, false},
+		{"missing arg", "select * from guarded where tenant_id = ?", nil, false},
+		{"insert other tenant", "insert into guarded (code, tenant_id) values (?, ?), (?, ?)", []interface{}{"a", 7, "b", 8}, false},
+		{"insert literal tenant", "insert into guarded (code, tenant_id) values (?, 7)", []interface{}{"a"}, false},
+		{"insert select", "insert into guarded (code, tenant_id) select code, tenant_id from guarded where tenant_id = ?", []interface{}{7}, false},
+		{"update assignment", "update guarded set tenant_id = ? where id = ?", []interface{}{7, 1}, false},
+		{"unconstrained", "select * from guarded", nil, false},
+	}
+
+	for _, it := range tests {
+		t.Run(it.name, func(t *testing.T) {
+
+			guarded, _, err := guardTenantSQL(TenantGuardReject, it.query, it.args, 7)
+
+			if it.valid && (err != nil || guarded != it.query) {
+				t.Errorf("expected valid query, got %v", err)
+			}
+
+			if !it.valid && !errors.Is(err, ErrTenantGuard) {
+				t.Errorf("expected tenant guard error, got %v", guarded)
+			}
+		})
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestTenantGuardInject
+func TestTenantGuardInject(t *testing.T) {
+
+	tenantGuardMu.Lock()
+	tenantGuardTables["guarded"] = "tenant_id"
+	tenantGuardMu.Unlock()
+
+	defer func() {
+		tenantGuardMu.Lock()
+		delete(tenantGuardTables, "guarded")
+		tenantGuardMu.Unlock()
+	}()
+
+	tests := []struct {
+		name     string
+		query    string
+		args     []interface{}
+		expected string
+		params   string
+	}{
+		{"bound tenant", "select * from guarded where tenant_id = ?", []interface{}{7},
+			"select * from guarded where tenant_id = ?", "[7]"},
+		{"top level or", "select * from guarded where tenant_id = ? or code = ?", []interface{}{7, "a"},
+			"select * from guarded where guarded.tenant_id = ? AND (tenant_id = ? or code = ?)", "[7 7 a]"},
+		{"literal tenant", "select * from guarded g where g.tenant_id = 8 order by g.code", nil,
+			"select * from guarded g where g.tenant_id = ? AND (g.tenant_id = 8) order by g.code", "[7]"},
+		{"other tenant", "select * from guarded where code = ? and tenant_id = ?", []interface{}{"a", 8},
+			"select * from guarded where guarded.tenant_id = ? AND (code = ? and tenant_id = ?)", "[7 a 8]"},
+	}
+
+	for _, it := range tests {
+		t.Run(it.name, func(t *testing.T) {
+
+			guarded, args, err := guardTenantSQL(TenantGuardInject, it.query, it.args, 7)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if guarded != it.expected || fmt.Sprint(args) != it.params {
+				t.Errorf("expected %v %v, got %v %v", it.expected, it.params, guarded, args)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package db
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/core/logs"
+)
+
+type TenantIsolationMode int
+
+const (
+	// tenant filter by goutils:"tenant" fields (default)
+	TenantIsolationRow TenantIsolationMode = iota
+	// postgres schema by tenant, set as search_path of session transaction
+	TenantIsolationSchema
+	// database by tenant, as beego orm alias registered on demand
+	TenantIsolationDatabase
+)
+
+var tenantIdentRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// TenantIsolation physical isolation of tenants. Applied by NewSessionWithTenant and NewSessionWithTenantAndDbName,
+// row filters are kept. eg.:
+//
+//	db.SetTenantIsolation(&db.TenantIsolation{Mode: db.TenantIsolationSchema})
+type TenantIsolation struct {
+	Mode TenantIsolationMode
+	// primary alias, of tenant schemas and database provisioning. default is "default"
+	Alias string
+	// schema, database and alias name of tenant. default is tenant_<id>
+	Name func(tenantId int64) string
+	// driver and data source of tenant database, to register the alias on demand
+	DataSource func(tenantId int64, database string) (driverName string, dataSource string, err error)
+	// tenants with physical isolation, other tenants use row isolation. nil is all tenants
+	Isolated func(tenantId int64) bool
+	// connection pool of each tenant alias. zero is beego default
+	MaxIdleConns int
+	MaxOpenConns int
+
+	mu      sync.Mutex
+	aliases map[string]bool
+}
+
+var tenantIsolation *TenantIsolation
+
+func SetTenantIsolation(isolation *TenantIsolation) {
+	if len(isolation.Alias) == 0 {
+		isolation.Alias = "default"
+	}
+	isolation.aliases = map[string]bool{}
+	tenantIsolation = isolation
+}
+
+// GetTenantIsolation nil when row isolation
+func GetTenantIsolation() *TenantIsolation {
+	if tenantIsolation == nil || tenantIsolation.Mode == TenantIsolationRow {
+		return nil
+	}
+	return tenantIsolation
+}
+
+func (this *TenantIsolation) TenantName(tenantId int64) string {
+	if this.Name != nil {
+		return this.Name(tenantId)
+	}
+	return fmt.Sprintf("tenant_%v", tenantId)
+}
+
+func (this *TenantIsolation) IsIsolated(tenantId int64) bool {
+	return tenantId > 0 && (this.Isolated == nil || this.Isolated(tenantId))
+}
+
+// QuotedTenantName tenant name quoted to sql. error when name is not a valid identifier
+func (this *TenantIsolation) QuotedTenantName(tenantId int64) (string, error) {
+	name := this.TenantName(tenantId)
+	if !tenantIdentRegex.MatchString(name) {
+		return "", fmt.Errorf("invalid tenant name: %v", name)
+	}
+	return fmt.Sprintf(`"%v"`, name), nil
+}
+
+// RegisterTenantAlias register beego orm alias of tenant database, once. Returns the alias
+func (this *TenantIsolation) RegisterTenantAlias(tenantId int64) (string, error) {
+
+	alias := this.TenantName(tenantId)
+
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	if this.aliases[alias] {
+		return alias, nil
+	}
+
+	// registered by app
+	if _, err := orm.GetDB(alias); err == nil {
+		this.aliases[alias] = true
+		return alias, nil
+	}
+
+	if this.DataSource == nil {
+		return "", fmt.Errorf("tenant isolation: DataSource not configured to register alias %v", alias)
+	}
+
+	driverName, dataSource, err := this.DataSource(tenantId, alias)
+
+	if err != nil {
+		return "", err
+	}
+
+	params := []orm.DBOption{}
+
+	if this.MaxIdleConns > 0 {
+		params = append(params, orm.MaxIdleConnections(this.MaxIdleConns))
+	}
+
+	if this.MaxOpenConns > 0 {
+		params = append(params, orm.MaxOpenConnections(this.MaxOpenConns))
+	}
+
+	if err := orm.RegisterDataBase(alias, driverName, dataSource, params...); err != nil {
+		return "", fmt.Errorf("tenant isolation: register alias %v: %v", alias, err)
+	}
+
+	logs.Info("tenant isolation: alias %v registered", alias)
+
+	this.aliases[alias] = true
+
+	return alias, nil
+}
+
+// select tenant database of isolation mode. called on session creation
+func (this *Session) isolateTenant() *Session {
+
+	isolation := GetTenantIsolation()
+
+	if isolation == nil {
+		return this
+	}
+
+	tenant, ok := this.Tenant.(TenantModel)
+
+	if !ok || !isolation.IsIsolated(tenant.GetId()) {
+		return this
+	}
+
+	this.isolatedTenantId = tenant.GetId()
+
+	if isolation.Mode == TenantIsolationDatabase {
+		this.database = NewDataBase(isolation.TenantName(this.isolatedTenantId))
+	}
+
+	return this
+}
+
+// IsTenantIsolated session uses tenant schema or database
+func (this *Session) IsTenantIsolated() bool {
+	return this.isolatedTenantId > 0
+}
+
+// register tenant alias before open database
+func (this *Session) openTenantIsolation() error {
+
+	if this.isolatedTenantId == 0 {
+		return nil
+	}
+
+	if isolation := GetTenantIsolation(); isolation != nil && isolation.Mode == TenantIsolationDatabase {
+		_, err := isolation.RegisterTenantAlias(this.isolatedTenantId)
+		return err
+	}
+
+	return nil
+}
+
+// set tenant schema on transaction. SET LOCAL is reset on commit or rollback, so pooled connections are not affected
+func (this *Session) beginTenantIsolation() error {
+
+	if this.isolatedTenantId == 0 {
+		return nil
+	}
+
+	isolation := GetTenantIsolation()
+
+	if isolation == nil || isolation.Mode != TenantIsolationSchema {
+		return nil
+	}
+
+	schema, err := isolation.QuotedTenantName(this.isolatedTenantId)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = this.database.Raw(fmt.Sprintf("SET LOCAL search_path TO %v, public", schema)).Exec()
+	return err
+}
+
+func (this *Session) isSchemaIsolated() bool {
+	isolation := GetTenantIsolation()
+	return this.isolatedTenantId > 0 && isolation != nil && isolation.Mode == TenantIsolationSchema
+}
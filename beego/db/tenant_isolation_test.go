@@ -0,0 +1,81 @@
+package db
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+type isolationTenant struct {
+	Id int64
+}
+
+func (this *isolationTenant) GetId() int64 {
+	return this.Id
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestTenantIsolation
+func TestTenantIsolation(t *testing.T) {
+
+	defer func() { tenantIsolation = nil }()
+
+	dir := t.TempDir()
+
+	SetTenantIsolation(&TenantIsolation{
+		Mode: TenantIsolationDatabase,
+		Name: func(tenantId int64) string {
+			return fmt.Sprintf("isolation_%v", tenantId)
+		},
+		DataSource: func(tenantId int64, database string) (string, string, error) {
+			return "sqlite3", filepath.Join(dir, database+".db"), nil
+		},
+		Isolated: func(tenantId int64) bool {
+			return tenantId == 1
+		},
+	})
+
+	shared := NewSessionWithTenant(&isolationTenant{Id: 2})
+
+	if shared.IsTenantIsolated() || shared.GetDb().dbName != "default" {
+		t.Errorf("expected tenant of row isolation on default database, got %v", shared.GetDb().dbName)
+	}
+
+	// db name is ignored by tenant database
+	session := NewSessionWithTenantAndDbName(&isolationTenant{Id: 1}, "default")
+
+	if !session.IsTenantIsolated() || session.GetDb().dbName != "isolation_1" {
+		t.Fatalf("expected tenant database, got %v", session.GetDb().dbName)
+	}
+
+	if err := session.OpenNoTx(); err != nil {
+		t.Fatal(err)
+	}
+
+	defer session.Close()
+
+	if _, err := orm.GetDB("isolation_1"); err != nil {
+		t.Errorf("expected tenant alias registered, got %v", err)
+	}
+
+	if alias, err := tenantIsolation.RegisterTenantAlias(1); err != nil || alias != "isolation_1" {
+		t.Errorf("expected tenant alias registered once, got %v", err)
+	}
+
+	if _, err := session.GetDb().Raw("create table items (id integer)").Exec(); err != nil {
+		t.Errorf("expected query on tenant database, got %v", err)
+	}
+
+	if name, err := tenantIsolation.QuotedTenantName(1); err != nil || name != `"isolation_1"` {
+		t.Errorf("expected quoted tenant name, got %v %v", name, err)
+	}
+
+	tenantIsolation.Name = func(tenantId int64) string {
+		return "tenant-1; drop table users"
+	}
+
+	if _, err := tenantIsolation.QuotedTenantName(1); err == nil {
+		t.Errorf("expected error of invalid tenant name")
+	}
+}
@@ -0,0 +1,253 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/core/logs"
+)
+
+// Upsert insert entity or update on conflict of conflictCols (field or column names), in only one statement.
+// If conflictCols is empty, use the field with tag orm:"unique", required when model has more than one unique field.
+// Supports postgres, mysql and sqlite (>= 3.35).
+// Set tenant and check authorization like Save. On conflict, the row is updated only if it belongs to the same tenant.
+// A soft deleted row is restored on conflict, the soft delete field is cleared on insert and update.
+// Returns true if the row was created. Hooks, version and audit are not applied.
+func (this *Session) Upsert(entity interface{}, conflictCols ...string) (bool, error) {
+
+	if !this.isTenantNil() && this.isSetTenant(entity) {
+		this.setTenant(entity)
+	}
+
+	if !this.checkIsAuthorizedTenant(entity, "Session.Upsert") {
+		this.SetError()
+		return false, errors.New("Tenant not authorized for entity data access. Operation: Session.Upsert.")
+	}
+
+	model, ok := entity.(Model)
+
+	if !ok {
+		return false, errors.New("entity does not implements of Model")
+	}
+
+	field, ok, err := this.getSoftDeleteField(entity)
+
+	if err != nil {
+		return false, err
+	}
+
+	if ok {
+		field.markRestored()
+	}
+
+	columns := getModelColumns(entity)
+	conflicts := []*modelColumn{}
+
+	if len(conflictCols) == 0 {
+		for _, it := range columns {
+			if it.unique && !it.pk {
+				conflicts = append(conflicts, it)
+			}
+		}
+		// each unique field is a distinct conflict target
+		if len(conflicts) > 1 {
+			return false, fmt.Errorf("%v has %v unique fields, conflict columns are required", getTypeName(entity), len(conflicts))
+		}
+	} else {
+		for _, name := range conflictCols {
+			col := findModelColumn(columns, name)
+			if col == nil {
+				return false, fmt.Errorf("conflict column %v not found on %v", name, getTypeName(entity))
+			}
+			conflicts = append(conflicts, col)
+		}
+	}
+
+	if len(conflicts) == 0 {
+		return false, fmt.Errorf("conflict columns not found on %v", getTypeName(entity))
+	}
+
+	var pk, tenant *modelColumn
+	insertCols := []*modelColumn{}
+	updateCols := []*modelColumn{}
+	now := time.Now()
+
+	for _, it := range columns {
+
+		if it.pk {
+			pk = it
+			if it.auto {
+				continue
+			}
+		}
+
+		if it.field == "Tenant" && !this.isTenantNil() && this.HasFilterTenant(entity) {
+			tenant = it
+		}
+
+		if it.autoNow || it.autoNowAdd {
+			if _, ok := it.value.Interface().(time.Time); ok {
+				it.value.Set(reflect.ValueOf(now))
+			}
+		}
+
+		insertCols = append(insertCols, it)
+
+		if !it.pk && !it.autoNowAdd && findModelColumn(conflicts, it.column) == nil {
+			updateCols = append(updateCols, it)
+		}
+	}
+
+	if pk == nil {
+		return false, fmt.Errorf("primary key not found on %v", getTypeName(entity))
+	}
+
+	driver := this.GetDb().DriverType()
+	query, args := buildUpsert(driver, model.TableName(), pk, tenant, insertCols, updateCols, conflicts)
+
+	if this.Debug {
+		logs.Debug("## upsert: %v, args: %v", query, args)
+	}
+
+	var id int64
+	var created bool
+
+	switch driver {
+	case orm.DRPostgres:
+		err = this.GetDb().Raw(query, args...).QueryRow(&id, &created)
+	case orm.DRSqlite:
+		// sqlite has no insert flag on upsert, so insert or nothing first. created is known by the write itself
+		insertQuery, insertArgs := buildInsert(model.TableName(), insertCols)
+		insertQuery = fmt.Sprintf("%v ON CONFLICT (%v) DO NOTHING RETURNING %v", insertQuery, columnNames(conflicts), pk.column)
+		if err = this.GetDb().Raw(insertQuery, insertArgs...).QueryRow(&id); err == nil {
+			created = true
+		} else if err == orm.ErrNoRows {
+			err = this.GetDb().Raw(query, args...).QueryRow(&id)
+		}
+	case orm.DRMySQL:
+		var res sql.Result
+		if res, err = this.GetDb().Raw(query, args...).Exec(); err == nil {
+			var affected int64
+			affected, _ = res.RowsAffected()
+			// 1 = inserted, 2 = updated, 0 = not changed
+			created = affected == 1
+			id, err = res.LastInsertId()
+		}
+		if err == nil && !created && tenant != nil {
+			err = this.checkUpsertTenant(model.TableName(), pk, tenant, id)
+		}
+	default:
+		return false, fmt.Errorf("upsert not supported by driver %v", driver)
+	}
+
+	if err == orm.ErrNoRows {
+		// conflict with row of another tenant
+		err = errors.New("Tenant not authorized for entity data access. Operation: Session.Upsert.")
+	}
+
+	if err != nil {
+		logs.Error("upsert error: %v", err)
+		debug.PrintStack()
+		this.SetError()
+		return false, err
+	}
+
+	if pk.auto {
+		pk.value.SetInt(id)
+	}
+
+	this.invalidateQueryCache(entity)
+
+	return created, nil
+}
+
+func buildUpsert(driver orm.DriverType, table string, pk *modelColumn, tenant *modelColumn,
+	insertCols []*modelColumn, updateCols []*modelColumn, conflicts []*modelColumn) (string, []interface{}) {
+
+	query, args := buildInsert(table, insertCols)
+
+	sets := []string{}
+
+	switch driver {
+	case orm.DRMySQL:
+		// LAST_INSERT_ID(id) returns the id of updated row
+		sets = append(sets, fmt.Sprintf("%v = LAST_INSERT_ID(%v)", pk.column, pk.column))
+		for _, it := range updateCols {
+			if tenant != nil {
+				sets = append(sets, fmt.Sprintf("%v = IF(%v = VALUES(%v), VALUES(%v), %v)",
+					it.column, tenant.column, tenant.column, it.column, it.column))
+			} else {
+				sets = append(sets, fmt.Sprintf("%v = VALUES(%v)", it.column, it.column))
+			}
+		}
+		query = fmt.Sprintf("%v ON DUPLICATE KEY UPDATE %v", query, strings.Join(sets, ", "))
+
+	default:
+		for _, it := range updateCols {
+			sets = append(sets, fmt.Sprintf("%v = excluded.%v", it.column, it.column))
+		}
+
+		// always update to return the row id
+		if len(sets) == 0 {
+			sets = append(sets, fmt.Sprintf("%v = excluded.%v", conflicts[0].column, conflicts[0].column))
+		}
+
+		query = fmt.Sprintf("%v ON CONFLICT (%v) DO UPDATE SET %v",
+			query, columnNames(conflicts), strings.Join(sets, ", "))
+
+		if tenant != nil {
+			query = fmt.Sprintf("%v WHERE %v.%v = excluded.%v", query, table, tenant.column, tenant.column)
+		}
+
+		if driver == orm.DRPostgres {
+			// xmax = 0 only on inserted rows
+			query = fmt.Sprintf("%v RETURNING %v, (xmax = 0)", query, pk.column)
+		} else {
+			query = fmt.Sprintf("%v RETURNING %v", query, pk.column)
+		}
+	}
+
+	return query, args
+}
+
+func buildInsert(table string, insertCols []*modelColumn) (string, []interface{}) {
+
+	names := []string{}
+	marks := []string{}
+	args := []interface{}{}
+
+	for _, it := range insertCols {
+		names = append(names, it.column)
+		marks = append(marks, "?")
+		args = append(args, it.dbValue())
+	}
+
+	return fmt.Sprintf("INSERT INTO %v (%v) VALUES (%v)", table, strings.Join(names, ", "), strings.Join(marks, ", ")), args
+}
+
+func columnNames(columns []*modelColumn) string {
+	names := []string{}
+	for _, it := range columns {
+		names = append(names, it.column)
+	}
+	return strings.Join(names, ", ")
+}
+
+// mysql does not support where on upsert, so check the tenant of updated row
+func (this *Session) checkUpsertTenant(table string, pk *modelColumn, tenant *modelColumn, id int64) error {
+	var count int64
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %v WHERE %v = ? AND %v = ?", table, pk.column, tenant.column)
+	if err := this.GetDb().Raw(query, id, tenant.dbValue()).QueryRow(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return orm.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/mobilemindtech/go-utils/beego/db"
+)
+
+type multiUnique struct {
+	Id    int64
+	Code  string `orm:"unique"`
+	Email string `orm:"unique"`
+}
+
+func (this *multiUnique) TableName() string {
+	return "multi_uniques"
+}
+
+func (this *multiUnique) IsPersisted() bool {
+	return this.Id > 0
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestUpsert
+func TestUpsert(t *testing.T) {
+
+	session, company := newTenantSession(t, "upsert")
+
+	product := &Product{Code: "p1", Name: "first", Price: 1}
+
+	created, err := session.Upsert(product)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !created || product.Id == 0 || product.Tenant.Id != company.Id {
+		t.Fatalf("expected created product with id and tenant, got %v %+v", created, product)
+	}
+
+	other := &Product{Code: "p1", Name: "second", Price: 2}
+
+	created, err = session.Upsert(other, "Code")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if created || other.Id != product.Id {
+		t.Errorf("expected update of product %v, got %v created %v", product.Id, other.Id, created)
+	}
+
+	loaded := &Product{Id: product.Id}
+
+	if err := session.GetDb().Read(loaded); err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.Name != "second" || loaded.Price != 2 {
+		t.Errorf("expected updated values, got %+v", loaded)
+	}
+
+	if count, _ := session.Count(new(Product)); count != 1 {
+		t.Errorf("expected 1 product, got %v", count)
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestUpsertOtherTenant
+func TestUpsertOtherTenant(t *testing.T) {
+
+	session, _ := newTenantSession(t, "upsert")
+
+	saveProducts(t, session, &Product{Code: "p1", Name: "first"})
+
+	company := &Company{Name: "other"}
+
+	if err := session.Save(company); err != nil {
+		t.Fatal(err)
+	}
+
+	other := db.NewSessionWithTenant(company).SetDatabase(session.GetDb())
+
+	if _, err := other.Upsert(&Product{Code: "p1", Name: "other"}); err == nil {
+		t.Errorf("expected error of conflict with row of other tenant")
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestUpsertConflictColumns
+func TestUpsertConflictColumns(t *testing.T) {
+
+	session := db.NewSession()
+
+	if _, err := session.Upsert(&multiUnique{Code: "a", Email: "a@a.com"}); err == nil {
+		t.Errorf("expected error of conflict columns required")
+	}
+
+	if _, err := session.Upsert(&multiUnique{Code: "a"}, "Name"); err == nil {
+		t.Errorf("expected error of conflict column not found")
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestUpsertRestore
+func TestUpsertRestore(t *testing.T) {
+
+	session, _ := newTenantSession(t, "upsert")
+
+	product := &Product{Code: "p1", Name: "first"}
+	saveProducts(t, session, product)
+
+	if err := session.Remove(product); err != nil {
+		t.Fatal(err)
+	}
+
+	other := &Product{Code: "p1", Name: "second"}
+
+	if created, err := session.Upsert(other); err != nil || created {
+		t.Fatalf("expected update of removed product, got created %v, err %v", created, err)
+	}
+
+	if count := countProducts(t, db.NewCriteria(session, new(Product), nil).Eq("Name", "second")); count != 1 {
+		t.Errorf("expected restored product on upsert, got %v", count)
+	}
+}
@@ -0,0 +1,152 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// ErrStaleEntity is returned by Session.Update when the entity version (field with tag `goutils:"version"`)
+// does not match the database version, eg. the row was changed by another user
+type ErrStaleEntity struct {
+	Entity  string
+	Id      int64
+	Version int64
+}
+
+func NewErrStaleEntity(entity string, id int64, version int64) *ErrStaleEntity {
+	return &ErrStaleEntity{Entity: entity, Id: id, Version: version}
+}
+
+func (this *ErrStaleEntity) Error() string {
+	return fmt.Sprintf("stale entity %v with id %v and version %v: row was updated or removed by another transaction",
+		this.Entity, this.Id, this.Version)
+}
+
+func IsStaleEntity(err error) bool {
+	var stale *ErrStaleEntity
+	return errors.As(err, &stale)
+}
+
+// get version field, tag `goutils:"version"`. version field should be a int type
+func (this *Session) getVersionField(reply interface{}) (reflect.Value, string, error) {
+
+	fullValue := reflect.ValueOf(reply).Elem()
+	fullType := fullValue.Type()
+
+	for i := 0; i < fullType.NumField(); i++ {
+		field := fullType.Field(i)
+		tags := this.getTags(field)
+
+		if this.hasTag(tags, "version") {
+			fieldValue := fullValue.Field(i)
+			switch fieldValue.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				return fieldValue, field.Name, nil
+			default:
+				return reflect.Value{}, "", fmt.Errorf("version field %v.%v should be int type", fullType.Name(), field.Name)
+			}
+		}
+	}
+
+	return reflect.Value{}, "", nil
+}
+
+func (this *Session) getEntityId(reply interface{}) int64 {
+	field := reflect.ValueOf(reply).Elem().FieldByName("Id")
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return field.Int()
+	}
+	return 0
+}
+
+// set initial version on insert
+func (this *Session) initVersion(entity interface{}) error {
+
+	field, _, err := this.getVersionField(entity)
+
+	if err != nil {
+		return err
+	}
+
+	if field.IsValid() && field.Int() == 0 {
+		field.SetInt(1)
+	}
+
+	return nil
+}
+
+// update entity and increment version on one statement, only if database version is the same of entity version:
+// UPDATE ... SET ..., version = version + 1 WHERE id = ? AND version = ?. Returns false when entity is not versioned
+func (this *Session) updateVersioned(entity interface{}) (bool, error) {
+
+	field, fieldName, err := this.getVersionField(entity)
+
+	if err != nil || !field.IsValid() {
+		return false, err
+	}
+
+	model, ok := entity.(Model)
+
+	if !ok {
+		return true, errors.New("entity does not implements of Model")
+	}
+
+	current := field.Int()
+	id := this.getEntityId(entity)
+	params := orm.Params{fieldName: orm.ColValue(orm.ColAdd, 1)}
+
+	for _, column := range getModelColumns(entity) {
+
+		if column.pk || column.autoNowAdd || column.field == fieldName {
+			continue
+		}
+
+		// as beego orm Update
+		if column.autoNow && column.value.Type() == reflect.TypeOf(time.Time{}) {
+			column.value.Set(reflect.ValueOf(time.Now()))
+		}
+
+		params[column.field] = versionedDbValue(column)
+	}
+
+	count, err := this.GetDb().
+		QueryTable(model.TableName()).
+		Filter("Id", id).
+		Filter(fieldName, current).
+		Update(params)
+
+	if err != nil {
+		return true, err
+	}
+
+	if count == 0 {
+		return true, NewErrStaleEntity(getTypeName(entity), id, current)
+	}
+
+	field.SetInt(current + 1)
+
+	return true, nil
+}
+
+// column value of update params. beego orm does not convert params values as on Update(entity)
+func versionedDbValue(column *modelColumn) interface{} {
+
+	if column.value.CanAddr() {
+		if fielder, ok := column.value.Addr().Interface().(orm.Fielder); ok {
+			return fielder.RawValue()
+		}
+	}
+
+	value := column.dbValue()
+
+	if t, ok := value.(time.Time); ok {
+		return t.In(orm.DefaultTimeLoc)
+	}
+
+	return value
+}
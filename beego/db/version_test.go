@@ -0,0 +1,111 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mobilemindtech/go-utils/beego/db"
+)
+
+type invalidVersion struct {
+	Id      int64
+	Version string `goutils:"version"`
+}
+
+func (this *invalidVersion) TableName() string {
+	return "invalid_versions"
+}
+
+func (this *invalidVersion) IsPersisted() bool {
+	return this.Id > 0
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestOptimisticLocking
+func TestOptimisticLocking(t *testing.T) {
+
+	session, _ := newTenantSession(t, "version")
+
+	category := &Category{Name: "books"}
+
+	if err := session.Save(category); err != nil {
+		t.Fatal(err)
+	}
+
+	product := &Product{Code: "p1", Name: "first"}
+
+	saveProducts(t, session, product)
+
+	if product.Version != 1 {
+		t.Fatalf("expected version 1, got %v", product.Version)
+	}
+
+	stale := *product
+
+	product.Name = "second"
+	product.Price = 9.5
+	product.Category = category
+
+	if err := session.Update(product); err != nil {
+		t.Fatal(err)
+	}
+
+	if product.Version != 2 {
+		t.Errorf("expected version 2, got %v", product.Version)
+	}
+
+	stale.Name = "stale"
+
+	err := session.Update(&stale)
+
+	if !db.IsStaleEntity(err) {
+		t.Fatalf("expected stale entity, got %v", err)
+	}
+
+	if stale.Version != 1 {
+		t.Errorf("expected stale version unchanged, got %v", stale.Version)
+	}
+
+	session.State = db.SessionStateOk
+
+	loaded := &Product{Id: product.Id}
+
+	if _, err := session.Load(loaded); err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.Version != 2 || loaded.Name != "second" || loaded.Price != 9.5 ||
+		loaded.Category == nil || loaded.Category.Id != category.Id || loaded.DeletedAt != nil {
+		t.Errorf("unexpected loaded product %+v", loaded)
+	}
+
+	if loaded.CreatedAt.Unix() != product.CreatedAt.Unix() {
+		t.Errorf("expected created at unchanged, got %v and %v", loaded.CreatedAt, product.CreatedAt)
+	}
+
+	deletedAt := time.Now()
+	product.DeletedAt = &deletedAt
+
+	if err := session.Update(product); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded = &Product{Id: product.Id}
+
+	if err := session.GetDb().Read(loaded); err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.DeletedAt == nil || loaded.DeletedAt.Unix() != deletedAt.Unix() {
+		t.Errorf("expected deleted at %v, got %v", deletedAt, loaded.DeletedAt)
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/db -run TestInvalidVersionField
+func TestInvalidVersionField(t *testing.T) {
+
+	session := db.NewSession()
+
+	if err := session.Save(&invalidVersion{}); err == nil {
+		t.Errorf("expected error of string version field")
+	}
+}
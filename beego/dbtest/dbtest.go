@@ -0,0 +1,151 @@
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/mobilemindtech/go-utils/beego/db"
+)
+
+type Config struct {
+	// default is "default", the alias of db.NewSession
+	Alias string
+	// default is "sqlite3". The driver should be imported by test, eg.: _ "github.com/mattn/go-sqlite3"
+	Driver string
+	// default is sqlite in memory database shared by connections
+	DataSource string
+	// print sync sql
+	Verbose bool
+}
+
+var (
+	config   *Config
+	configMu sync.Mutex
+	// keep in memory database while tests run
+	keepAlive *sql.Conn
+)
+
+// Setup register sqlite in memory alias "default" and create tables of models, usually on TestMain.
+// beego orm can't register models after sync, so next calls of same alias only check models are registered.
+//
+//	func TestMain(m *testing.M) {
+//		if err := dbtest.Setup(new(models.Tenant), new(models.User)); err != nil {
+//			log.Fatal(err)
+//		}
+//		os.Exit(m.Run())
+//	}
+func Setup(models ...interface{}) error {
+	return SetupWithConfig(&Config{}, models...)
+}
+
+func SetupWithConfig(c *Config, models ...interface{}) error {
+
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	cfg := *c
+
+	if len(cfg.Alias) == 0 {
+		cfg.Alias = "default"
+	}
+
+	if len(cfg.Driver) == 0 {
+		cfg.Driver = "sqlite3"
+	}
+
+	if len(cfg.DataSource) == 0 {
+		cfg.DataSource = "file::memory:?cache=shared"
+	}
+
+	if config != nil {
+		return checkSetup(&cfg, models...)
+	}
+
+	if err := orm.RegisterDataBase(cfg.Alias, cfg.Driver, cfg.DataSource); err != nil {
+		return fmt.Errorf("dbtest: register database %v (%v): %v", cfg.Alias, cfg.Driver, err)
+	}
+
+	database, err := orm.GetDB(cfg.Alias)
+
+	if err != nil {
+		return fmt.Errorf("dbtest: %v", err)
+	}
+
+	if keepAlive, err = database.Conn(context.Background()); err != nil {
+		return fmt.Errorf("dbtest: %v", err)
+	}
+
+	orm.RegisterModel(models...)
+	RegisterFixtureModels(models...)
+
+	if err := orm.RunSyncdb(cfg.Alias, false, cfg.Verbose); err != nil {
+		return fmt.Errorf("dbtest: sync models: %v", err)
+	}
+
+	config = &cfg
+
+	return nil
+}
+
+func checkSetup(cfg *Config, models ...interface{}) error {
+
+	if cfg.Alias != config.Alias || cfg.Driver != config.Driver || cfg.DataSource != config.DataSource {
+		return fmt.Errorf("dbtest: already setup with alias %v", config.Alias)
+	}
+
+	fixtureModelsMu.RLock()
+	defer fixtureModelsMu.RUnlock()
+
+	for _, it := range models {
+		typ := reflect.TypeOf(it)
+		if typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		if _, ok := fixtureModels[typ.Name()]; !ok {
+			return fmt.Errorf("dbtest: model %v not registered on first Setup", typ.Name())
+		}
+	}
+
+	return nil
+}
+
+func getConfig() *Config {
+	configMu.Lock()
+	defer configMu.Unlock()
+	return config
+}
+
+// NewSession session with transaction rolled back on test cleanup
+func NewSession(t testing.TB) *db.Session {
+	t.Helper()
+	return NewSessionWithTenant(t, nil)
+}
+
+// NewSessionWithTenant session of tenant with transaction rolled back on test cleanup
+func NewSessionWithTenant(t testing.TB, tenant interface{}) *db.Session {
+
+	t.Helper()
+
+	cfg := getConfig()
+
+	if cfg == nil {
+		t.Fatal("dbtest: Setup not called")
+	}
+
+	session := db.NewSessionWithTenantAndDbName(tenant, cfg.Alias)
+
+	if err := session.OpenTx(); err != nil {
+		t.Fatalf("dbtest: open tx: %v", err)
+	}
+
+	t.Cleanup(func() {
+		session.OnError().Close()
+	})
+
+	return session
+}
@@ -0,0 +1,144 @@
+package dbtest_test
+
+import (
+	"log"
+	"os"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/mobilemindtech/go-utils/beego/dbtest"
+)
+
+type Group struct {
+	Id   int64  `json:",string,omitempty"`
+	Name string `orm:"size(50)"`
+}
+
+func (this *Group) TableName() string {
+	return "groups"
+}
+
+func (this *Group) IsPersisted() bool {
+	return this.Id > 0
+}
+
+type Member struct {
+	Id    int64    `json:",string,omitempty"`
+	Name  string   `orm:"size(50)" json:"name"`
+	Group *Group   `orm:"rel(fk)"`
+	Tags  []string `orm:"-"`
+}
+
+func (this *Member) TableName() string {
+	return "members"
+}
+
+func (this *Member) IsPersisted() bool {
+	return this.Id > 0
+}
+
+func TestMain(m *testing.M) {
+	if err := dbtest.Setup(new(Group), new(Member)); err != nil {
+		log.Fatal(err)
+	}
+	os.Exit(m.Run())
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/dbtest -run TestSetup
+func TestSetup(t *testing.T) {
+
+	if err := dbtest.Setup(new(Group), new(Member)); err != nil {
+		t.Errorf("setup again: %v", err)
+	}
+
+	if err := dbtest.Setup(new(Group), new(struct{ Id int64 })); err == nil {
+		t.Errorf("expected error of model not registered")
+	}
+
+	if err := dbtest.SetupWithConfig(&dbtest.Config{Alias: "other"}); err == nil {
+		t.Errorf("expected error of other alias")
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/dbtest -run TestLoadFixtures
+func TestLoadFixtures(t *testing.T) {
+
+	session := dbtest.NewSession(t)
+
+	fixtures := dbtest.MustLoadFixtures(t, session, nil, "testdata/fixtures.yml", "testdata/fixtures.json")
+
+	if len(fixtures["Group"]) != 3 || len(fixtures["Member"]) != 3 {
+		t.Fatalf("expected 3 groups and 3 members, got %v and %v", len(fixtures["Group"]), len(fixtures["Member"]))
+	}
+
+	john := fixtures.Get("Member", 0).(*Member)
+
+	if john.Id == 0 || john.Name != "john" || john.Group.Id != 1 || len(john.Tags) != 2 {
+		t.Errorf("unexpected member %+v", john)
+	}
+
+	// json tag
+	if ana := fixtures.Get("Member", 1).(*Member); ana.Name != "ana" {
+		t.Errorf("expected ana, got %v", ana.Name)
+	}
+
+	count, err := session.Count(new(Member))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 3 {
+		t.Errorf("expected 3 members, got %v", count)
+	}
+
+	if fixtures.Get("Member", 10) != nil {
+		t.Errorf("expected nil of out of range")
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/dbtest -run TestLoadFixturesError
+func TestLoadFixturesError(t *testing.T) {
+
+	session := dbtest.NewSession(t)
+
+	if _, err := dbtest.LoadFixtures(session, nil, "testdata/none.yml"); err == nil {
+		t.Errorf("expected error of file not found")
+	}
+
+	file := t.TempDir() + "/fixtures.yml"
+
+	for _, data := range []string{"Other:\n  - Id: 1\n", "Group:\n  - Unknown: 1\n", "Group: 1\n"} {
+
+		if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := dbtest.LoadFixtures(session, nil, file); err == nil {
+			t.Errorf("expected error of %q", data)
+		}
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/dbtest -run TestNewSessionRollback
+func TestNewSessionRollback(t *testing.T) {
+
+	t.Run("insert", func(t *testing.T) {
+		session := dbtest.NewSession(t)
+		if err := session.Save(&Group{Name: "rollback"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	session := dbtest.NewSession(t)
+
+	count, err := session.Count(new(Group))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 0 {
+		t.Errorf("expected rollback of subtest, got %v groups", count)
+	}
+}
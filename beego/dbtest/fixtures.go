@@ -0,0 +1,237 @@
+package dbtest
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mobilemindtech/go-utils/beego/db"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	fixtureModels   = map[string]reflect.Type{}
+	fixtureModelsMu sync.RWMutex
+)
+
+// Fixtures saved entities by model name, in file order
+type Fixtures map[string][]interface{}
+
+func (this Fixtures) Get(model string, index int) interface{} {
+	if index < len(this[model]) {
+		return this[model][index]
+	}
+	return nil
+}
+
+// RegisterFixtureModels models of fixture files, by struct name. Setup register its models
+func RegisterFixtureModels(models ...interface{}) {
+	fixtureModelsMu.Lock()
+	defer fixtureModelsMu.Unlock()
+	for _, it := range models {
+		typ := reflect.TypeOf(it)
+		if typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		fixtureModels[typ.Name()] = typ
+	}
+}
+
+// LoadFixtures save entities of YAML or JSON files, by model name. Models are saved in file order, so
+// relations can reference previous rows by Id. When tenant is not nil, it's set on goutils:"tenant" fields.
+//
+//	Role:
+//	  - Id: 1
+//	    Name: admin
+//	User:
+//	  - Name: john
+//	    Role: {Id: 1}
+func LoadFixtures(session *db.Session, tenant interface{}, files ...string) (Fixtures, error) {
+
+	fixtures := Fixtures{}
+
+	if tenant != nil {
+		previous := session.Tenant
+		session.SetTenant(tenant)
+		defer session.SetTenant(previous)
+	}
+
+	for _, file := range files {
+
+		data, err := os.ReadFile(file)
+
+		if err != nil {
+			return nil, fmt.Errorf("dbtest: %v", err)
+		}
+
+		if err := loadFixtures(session, data, fixtures); err != nil {
+			return nil, fmt.Errorf("dbtest: fixture %v: %v", file, err)
+		}
+	}
+
+	return fixtures, nil
+}
+
+// MustLoadFixtures LoadFixtures or fail test
+func MustLoadFixtures(t testing.TB, session *db.Session, tenant interface{}, files ...string) Fixtures {
+	t.Helper()
+	fixtures, err := LoadFixtures(session, tenant, files...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fixtures
+}
+
+// json is yaml, so yaml decoder is used to both, keeping models order. Rows are decoded straight into models
+func loadFixtures(session *db.Session, data []byte, fixtures Fixtures) error {
+
+	var doc yaml.Node
+
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+
+	if len(doc.Content) == 0 {
+		return nil
+	}
+
+	root := doc.Content[0]
+
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("expected map of model name to rows")
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+
+		name := root.Content[i].Value
+
+		fixtureModelsMu.RLock()
+		typ, ok := fixtureModels[name]
+		fixtureModelsMu.RUnlock()
+
+		if !ok {
+			return fmt.Errorf("model %v not registered", name)
+		}
+
+		rows := root.Content[i+1]
+
+		if rows.Kind != yaml.SequenceNode {
+			return fmt.Errorf("%v: expected list of rows", name)
+		}
+
+		for _, row := range rows.Content {
+
+			entity := reflect.New(typ)
+
+			if err := decodeFixture(row, entity.Elem()); err != nil {
+				return fmt.Errorf("%v: %v", name, err)
+			}
+
+			if err := session.Save(entity.Interface()); err != nil {
+				return fmt.Errorf("%v: %v", name, err)
+			}
+
+			fixtures[name] = append(fixtures[name], entity.Interface())
+		}
+	}
+
+	return nil
+}
+
+// decodeFixture decode node straight into value. Struct fields are matched by name, case insensitive, or json tag,
+// so json options as ,string don't apply. Other values are decoded by yaml, as numbers, strings and times
+func decodeFixture(node *yaml.Node, value reflect.Value) error {
+
+	if node.Kind == yaml.AliasNode {
+		return decodeFixture(node.Alias, value)
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr:
+		if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+			value.Set(reflect.Zero(value.Type()))
+			return nil
+		}
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		return decodeFixture(node, value.Elem())
+
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			break
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			field, err := fixtureField(value, key)
+			if err != nil {
+				return err
+			}
+			if err := decodeFixture(node.Content[i+1], field); err != nil {
+				return fmt.Errorf("%v: %v", key, err)
+			}
+		}
+		return nil
+
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			break
+		}
+		items := reflect.MakeSlice(value.Type(), len(node.Content), len(node.Content))
+		for i, item := range node.Content {
+			if err := decodeFixture(item, items.Index(i)); err != nil {
+				return err
+			}
+		}
+		value.Set(items)
+		return nil
+	}
+
+	return node.Decode(value.Addr().Interface())
+}
+
+// exported field by json tag or name, promoted fields of embedded structs included
+func fixtureField(value reflect.Value, key string) (reflect.Value, error) {
+
+	var found *reflect.StructField
+
+	for _, field := range reflect.VisibleFields(value.Type()) {
+
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+
+		tag := strings.Split(field.Tag.Get("json"), ",")[0]
+
+		if tag == key {
+			found = &field
+			break
+		}
+
+		if found == nil && strings.EqualFold(field.Name, key) {
+			found = &field
+		}
+	}
+
+	if found == nil {
+		return reflect.Value{}, fmt.Errorf("field %v not found on %v", key, value.Type().Name())
+	}
+
+	// allocate embedded struct pointers
+	for i, index := range found.Index {
+		if i > 0 {
+			if value.Kind() == reflect.Ptr {
+				if value.IsNil() {
+					value.Set(reflect.New(value.Type().Elem()))
+				}
+				value = value.Elem()
+			}
+		}
+		value = value.Field(index)
+	}
+
+	return value, nil
+}
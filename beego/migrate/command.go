@@ -0,0 +1,96 @@
+package migrate
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// Run migration command from application binary. Commands: up, down [n] (default 1), status and drift.
+// eg.: if len(os.Args) > 2 && os.Args[1] == "migrate" { err = m.Run(os.Args[2:]...) }
+func (this *Migrator) Run(args ...string) error {
+	return this.RunWithOutput(os.Stdout, args...)
+}
+
+func (this *Migrator) RunWithOutput(out io.Writer, args ...string) error {
+
+	if len(args) == 0 {
+		return fmt.Errorf("migrate: command is required (up, down [n], status, drift)")
+	}
+
+	switch args[0] {
+	case "up":
+
+		applied, err := this.Up()
+
+		for _, it := range applied {
+			fmt.Fprintf(out, "applied %v_%v\n", it.Version, it.Name)
+		}
+
+		if err == nil && len(applied) == 0 {
+			fmt.Fprintln(out, "no pending migrations")
+		}
+
+		return err
+
+	case "down":
+
+		n := 1
+
+		if len(args) > 1 {
+			v, err := strconv.Atoi(args[1])
+			if err != nil || v <= 0 {
+				return fmt.Errorf("migrate: invalid down count %v", args[1])
+			}
+			n = v
+		}
+
+		reverted, err := this.Down(n)
+
+		for _, it := range reverted {
+			fmt.Fprintf(out, "reverted %v_%v\n", it.Version, it.Name)
+		}
+
+		return err
+
+	case "status":
+
+		status, err := this.Status()
+
+		if err != nil {
+			return err
+		}
+
+		for _, it := range status {
+			if it.Applied {
+				fmt.Fprintf(out, "%v_%v\tapplied at %v\n", it.Version, it.Name, it.AppliedAt.Format("2006-01-02 15:04:05"))
+			} else {
+				fmt.Fprintf(out, "%v_%v\tpending\n", it.Version, it.Name)
+			}
+		}
+
+		return nil
+
+	case "drift":
+
+		report, err := this.Drift()
+
+		if err != nil {
+			return err
+		}
+
+		if !report.HasDrift() {
+			fmt.Fprintln(out, "no schema drift")
+			return nil
+		}
+
+		fmt.Fprintln(out, report.String())
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, report.SQL())
+
+		return nil
+	}
+
+	return fmt.Errorf("migrate: unknown command %v", args[0])
+}
@@ -0,0 +1,557 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+type DriftKind string
+
+const (
+	DriftMissingTable  DriftKind = "missing_table"
+	DriftMissingColumn DriftKind = "missing_column"
+	DriftTypeMismatch  DriftKind = "type_mismatch"
+	DriftNullMismatch  DriftKind = "null_mismatch"
+	DriftMissingIndex  DriftKind = "missing_index"
+)
+
+// DriftIssue difference between model and database. SQL is the statement to fix, can be a comment
+// when the database does not support the change (eg.: sqlite alter column)
+type DriftIssue struct {
+	Kind     DriftKind
+	Model    string
+	Table    string
+	Column   string
+	Expected string
+	Actual   string
+	SQL      string
+}
+
+func (this *DriftIssue) String() string {
+	switch this.Kind {
+	case DriftMissingTable:
+		return fmt.Sprintf("%v: table %v of %v", this.Kind, this.Table, this.Model)
+	case DriftMissingIndex:
+		return fmt.Sprintf("%v: %v on %v", this.Kind, this.Expected, this.Table)
+	case DriftMissingColumn:
+		return fmt.Sprintf("%v: %v.%v (%v)", this.Kind, this.Table, this.Column, this.Expected)
+	}
+	return fmt.Sprintf("%v: %v.%v expected %v, found %v", this.Kind, this.Table, this.Column, this.Expected, this.Actual)
+}
+
+type DriftReport struct {
+	Issues []*DriftIssue
+}
+
+func (this *DriftReport) HasDrift() bool {
+	return len(this.Issues) > 0
+}
+
+// SQL statements to fix the database schema. Review before apply
+func (this *DriftReport) SQL() string {
+	stmts := []string{}
+	for _, it := range this.Issues {
+		stmts = append(stmts, it.SQL)
+	}
+	return strings.Join(stmts, "\n")
+}
+
+func (this *DriftReport) String() string {
+	lines := []string{}
+	for _, it := range this.Issues {
+		lines = append(lines, it.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+type dbColumn struct {
+	name     string
+	typ      string
+	nullable bool
+}
+
+type dbIndex struct {
+	name    string
+	columns []string
+	unique  bool
+}
+
+type dbTable struct {
+	columns map[string]*dbColumn
+	indexes []*dbIndex
+}
+
+// Drift compare models with database schema. Without models, uses models of migrate.RegisterModel
+func (this *Migrator) Drift(models ...interface{}) (*DriftReport, error) {
+
+	if len(models) == 0 {
+		models = getRegisteredModels()
+	}
+
+	conn, release, err := this.openConn()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer release()
+
+	tables, err := this.loadSchema(conn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DriftReport{}
+
+	for _, model := range models {
+
+		table, err := getModelTable(model, this.driver)
+
+		if err != nil {
+			return nil, err
+		}
+
+		actual, ok := tables[strings.ToLower(table.name)]
+
+		if !ok {
+			report.Issues = append(report.Issues, &DriftIssue{
+				Kind: DriftMissingTable, Model: table.model, Table: table.name, SQL: this.createTableSQL(table)})
+			continue
+		}
+
+		this.diffTable(report, table, actual)
+	}
+
+	return report, nil
+}
+
+func (this *Migrator) diffTable(report *DriftReport, table *modelTable, actual *dbTable) {
+
+	for _, column := range table.columns {
+
+		dbcol, ok := actual.columns[strings.ToLower(column.name)]
+
+		if !ok {
+			report.Issues = append(report.Issues, &DriftIssue{
+				Kind: DriftMissingColumn, Model: table.model, Table: table.name, Column: column.name,
+				Expected: column.typ, SQL: this.addColumnSQL(table, column)})
+			continue
+		}
+
+		if !isCompatibleType(column.typ, dbcol.typ) {
+			report.Issues = append(report.Issues, &DriftIssue{
+				Kind: DriftTypeMismatch, Model: table.model, Table: table.name, Column: column.name,
+				Expected: column.typ, Actual: dbcol.typ, SQL: this.alterColumnSQL(table, column)})
+		}
+
+		if !column.pk && column.null != dbcol.nullable {
+			report.Issues = append(report.Issues, &DriftIssue{
+				Kind: DriftNullMismatch, Model: table.model, Table: table.name, Column: column.name,
+				Expected: nullDescription(column.null), Actual: nullDescription(dbcol.nullable), SQL: this.alterNullSQL(table, column)})
+		}
+	}
+
+	for _, index := range table.indexes {
+		if !hasIndex(actual.indexes, index) {
+			report.Issues = append(report.Issues, &DriftIssue{
+				Kind: DriftMissingIndex, Model: table.model, Table: table.name, Column: strings.Join(index.columns, ","),
+				Expected: indexDescription(index), SQL: createIndexSQL(table, index)})
+		}
+	}
+}
+
+func (this *Migrator) loadSchema(conn *sql.Conn) (map[string]*dbTable, error) {
+	switch this.driver {
+	case orm.DRSqlite:
+		return loadSqliteSchema(conn)
+	case orm.DRPostgres:
+		return loadInformationSchema(conn,
+			`SELECT table_name, column_name, data_type, is_nullable, character_maximum_length, numeric_precision, numeric_scale
+			FROM information_schema.columns WHERE table_schema = current_schema()`,
+			`SELECT t.relname, i.relname, ix.indisunique, a.attname
+			FROM pg_class t
+			JOIN pg_index ix ON t.oid = ix.indrelid
+			JOIN pg_class i ON i.oid = ix.indexrelid
+			JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = ANY(ix.indkey)
+			JOIN pg_namespace n ON n.oid = t.relnamespace
+			WHERE n.nspname = current_schema()
+			ORDER BY t.relname, i.relname, array_position(ix.indkey::int2[], a.attnum)`)
+	case orm.DRMySQL:
+		return loadInformationSchema(conn,
+			`SELECT table_name, column_name, data_type, is_nullable, character_maximum_length, numeric_precision, numeric_scale
+			FROM information_schema.columns WHERE table_schema = DATABASE()`,
+			`SELECT table_name, index_name, non_unique = 0, column_name
+			FROM information_schema.statistics WHERE table_schema = DATABASE()
+			ORDER BY table_name, index_name, seq_in_index`)
+	}
+	return nil, fmt.Errorf("schema drift is not supported to driver %v", this.driver)
+}
+
+func loadInformationSchema(conn *sql.Conn, columnsQuery string, indexesQuery string) (map[string]*dbTable, error) {
+
+	ctx := context.Background()
+	tables := map[string]*dbTable{}
+
+	rows, err := conn.QueryContext(ctx, columnsQuery)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var table, name, typ, nullable string
+		var size, precision, scale sql.NullInt64
+
+		if err := rows.Scan(&table, &name, &typ, &nullable, &size, &precision, &scale); err != nil {
+			return nil, err
+		}
+
+		typ = strings.ToLower(typ)
+
+		switch {
+		case size.Valid && strings.Contains(typ, "char") && size.Int64 < 1<<24:
+			typ = fmt.Sprintf("%v(%v)", typ, size.Int64)
+		case (typ == "numeric" || typ == "decimal") && precision.Valid:
+			typ = fmt.Sprintf("numeric(%v, %v)", precision.Int64, scale.Int64)
+		}
+
+		getDbTable(tables, table).columns[strings.ToLower(name)] = &dbColumn{name: name, typ: typ, nullable: nullable == "YES"}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	irows, err := conn.QueryContext(ctx, indexesQuery)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer irows.Close()
+
+	for irows.Next() {
+
+		var table, name, column string
+		var unique bool
+
+		if err := irows.Scan(&table, &name, &unique, &column); err != nil {
+			return nil, err
+		}
+
+		t := getDbTable(tables, table)
+
+		var index *dbIndex
+		if n := len(t.indexes); n > 0 && t.indexes[n-1].name == name {
+			index = t.indexes[n-1]
+		} else {
+			index = &dbIndex{name: name, unique: unique}
+			t.indexes = append(t.indexes, index)
+		}
+
+		index.columns = append(index.columns, strings.ToLower(column))
+	}
+
+	return tables, irows.Err()
+}
+
+func loadSqliteSchema(conn *sql.Conn) (map[string]*dbTable, error) {
+
+	ctx := context.Background()
+	tables := map[string]*dbTable{}
+	names := []string{}
+
+	rows, err := conn.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'")
+
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	rows.Close()
+
+	for _, name := range names {
+
+		table := getDbTable(tables, name)
+
+		// cid, name, type, notnull, dflt_value, pk
+		crows, err := conn.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(%q)", name))
+
+		if err != nil {
+			return nil, err
+		}
+
+		for crows.Next() {
+			var cid, notnull, pk int
+			var column, typ string
+			var dflt sql.NullString
+			if err := crows.Scan(&cid, &column, &typ, &notnull, &dflt, &pk); err != nil {
+				crows.Close()
+				return nil, err
+			}
+			table.columns[strings.ToLower(column)] = &dbColumn{name: column, typ: strings.ToLower(typ), nullable: notnull == 0 && pk == 0}
+		}
+
+		crows.Close()
+
+		// seq, name, unique, origin, partial
+		irows, err := conn.QueryContext(ctx, fmt.Sprintf("PRAGMA index_list(%q)", name))
+
+		if err != nil {
+			return nil, err
+		}
+
+		for irows.Next() {
+			var seq, unique, partial int
+			var index, origin string
+			if err := irows.Scan(&seq, &index, &unique, &origin, &partial); err != nil {
+				irows.Close()
+				return nil, err
+			}
+			table.indexes = append(table.indexes, &dbIndex{name: index, unique: unique == 1})
+		}
+
+		irows.Close()
+
+		for _, index := range table.indexes {
+
+			// seqno, cid, name
+			irows, err := conn.QueryContext(ctx, fmt.Sprintf("PRAGMA index_info(%q)", index.name))
+
+			if err != nil {
+				return nil, err
+			}
+
+			for irows.Next() {
+				var seqno, cid int
+				var column sql.NullString
+				if err := irows.Scan(&seqno, &cid, &column); err != nil {
+					irows.Close()
+					return nil, err
+				}
+				index.columns = append(index.columns, strings.ToLower(column.String))
+			}
+
+			irows.Close()
+		}
+	}
+
+	return tables, nil
+}
+
+func getDbTable(tables map[string]*dbTable, name string) *dbTable {
+	name = strings.ToLower(name)
+	if _, ok := tables[name]; !ok {
+		tables[name] = &dbTable{columns: map[string]*dbColumn{}}
+	}
+	return tables[name]
+}
+
+// index with same columns. unique index also satisfy a non unique index
+func hasIndex(indexes []*dbIndex, index *modelIndex) bool {
+	for _, it := range indexes {
+		if len(it.columns) != len(index.columns) || (index.unique && !it.unique) {
+			continue
+		}
+		match := true
+		for i, column := range index.columns {
+			if it.columns[i] != strings.ToLower(column) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// type family, so synonyms are compatible. eg.: int4 and integer, timestamptz and timestamp with time zone
+func typeFamily(typ string) string {
+	typ = strings.ToLower(typ)
+	switch {
+	case strings.HasPrefix(typ, "bool") || typ == "tinyint(1)":
+		return "bool"
+	case strings.Contains(typ, "char") || strings.Contains(typ, "text") || strings.Contains(typ, "clob"):
+		return "string"
+	case strings.Contains(typ, "int") || strings.Contains(typ, "serial"):
+		return "int"
+	case strings.HasPrefix(typ, "numeric") || strings.HasPrefix(typ, "decimal"):
+		return "decimal"
+	case strings.Contains(typ, "double") || strings.Contains(typ, "real") || strings.Contains(typ, "float"):
+		return "float"
+	case strings.HasPrefix(typ, "timestamp") || strings.HasPrefix(typ, "datetime"):
+		return "datetime"
+	case typ == "date":
+		return "date"
+	case strings.HasPrefix(typ, "json"):
+		return "json"
+	}
+	return typ
+}
+
+func isCompatibleType(expected string, actual string) bool {
+
+	ef, af := typeFamily(expected), typeFamily(actual)
+
+	// mysql and sqlite store bool as tinyint
+	if ef == "bool" && af == "int" {
+		return true
+	}
+
+	if ef != af {
+		return false
+	}
+
+	// varchar size
+	if ef == "string" && strings.Contains(expected, "(") && strings.Contains(actual, "(") {
+		return sizeOf(expected) == sizeOf(actual)
+	}
+
+	if ef == "decimal" && strings.Contains(actual, "(") {
+		return strings.ReplaceAll(expected, " ", "") == strings.ReplaceAll(actual, " ", "")
+	}
+
+	return true
+}
+
+func sizeOf(typ string) string {
+	start, end := strings.Index(typ, "("), strings.Index(typ, ")")
+	if start < 0 || end < start {
+		return ""
+	}
+	return typ[start+1 : end]
+}
+
+func nullDescription(null bool) string {
+	if null {
+		return "null"
+	}
+	return "not null"
+}
+
+func indexDescription(index *modelIndex) string {
+	if index.unique {
+		return fmt.Sprintf("unique index (%v)", strings.Join(index.columns, ", "))
+	}
+	return fmt.Sprintf("index (%v)", strings.Join(index.columns, ", "))
+}
+
+func (this *Migrator) columnDefinition(column *modelColumn) string {
+
+	if column.auto {
+		switch this.driver {
+		case orm.DRPostgres:
+			if column.typ == "integer" {
+				return fmt.Sprintf("%v serial NOT NULL PRIMARY KEY", column.name)
+			}
+			return fmt.Sprintf("%v bigserial NOT NULL PRIMARY KEY", column.name)
+		case orm.DRSqlite:
+			return fmt.Sprintf("%v integer NOT NULL PRIMARY KEY AUTOINCREMENT", column.name)
+		}
+		return fmt.Sprintf("%v %v AUTO_INCREMENT NOT NULL PRIMARY KEY", column.name, column.typ)
+	}
+
+	def := fmt.Sprintf("%v %v", column.name, column.typ)
+
+	if column.pk {
+		return def + " NOT NULL PRIMARY KEY"
+	}
+
+	if !column.null {
+		def += " NOT NULL"
+	}
+
+	if len(column.defaults) > 0 {
+		def += fmt.Sprintf(" DEFAULT %v", quoteDefault(column))
+	}
+
+	return def
+}
+
+func quoteDefault(column *modelColumn) string {
+	switch typeFamily(column.typ) {
+	case "string", "date", "datetime", "json":
+		return fmt.Sprintf("'%v'", strings.ReplaceAll(column.defaults, "'", "''"))
+	}
+	return column.defaults
+}
+
+func (this *Migrator) createTableSQL(table *modelTable) string {
+
+	defs := []string{}
+
+	for _, column := range table.columns {
+		defs = append(defs, "    "+this.columnDefinition(column))
+	}
+
+	stmts := []string{fmt.Sprintf("CREATE TABLE %v (\n%v\n);", table.name, strings.Join(defs, ",\n"))}
+
+	for _, index := range table.indexes {
+		stmts = append(stmts, createIndexSQL(table, index))
+	}
+
+	return strings.Join(stmts, "\n")
+}
+
+func (this *Migrator) addColumnSQL(table *modelTable, column *modelColumn) string {
+
+	stmt := fmt.Sprintf("ALTER TABLE %v ADD COLUMN %v;", table.name, this.columnDefinition(column))
+
+	if !column.null && !column.pk && len(column.defaults) == 0 {
+		return "-- not null column without default fails on table with rows, set a default or fill it before\n" + stmt
+	}
+
+	return stmt
+}
+
+func (this *Migrator) alterColumnSQL(table *modelTable, column *modelColumn) string {
+	switch this.driver {
+	case orm.DRPostgres:
+		return fmt.Sprintf("ALTER TABLE %v ALTER COLUMN %v TYPE %v USING %v::%v;", table.name, column.name, column.typ, column.name, column.typ)
+	case orm.DRMySQL:
+		return fmt.Sprintf("ALTER TABLE %v MODIFY COLUMN %v;", table.name, this.columnDefinition(column))
+	}
+	return fmt.Sprintf("-- %v does not support alter column type: %v.%v %v", this.driver, table.name, column.name, column.typ)
+}
+
+func (this *Migrator) alterNullSQL(table *modelTable, column *modelColumn) string {
+	switch this.driver {
+	case orm.DRPostgres:
+		if column.null {
+			return fmt.Sprintf("ALTER TABLE %v ALTER COLUMN %v DROP NOT NULL;", table.name, column.name)
+		}
+		return fmt.Sprintf("ALTER TABLE %v ALTER COLUMN %v SET NOT NULL;", table.name, column.name)
+	case orm.DRMySQL:
+		return fmt.Sprintf("ALTER TABLE %v MODIFY COLUMN %v;", table.name, this.columnDefinition(column))
+	}
+	return fmt.Sprintf("-- %v does not support alter column null: %v.%v %v", this.driver, table.name, column.name, nullDescription(column.null))
+}
+
+func createIndexSQL(table *modelTable, index *modelIndex) string {
+
+	name := fmt.Sprintf("%v_%v", table.name, strings.Join(index.columns, "_"))
+
+	if index.unique {
+		return fmt.Sprintf("CREATE UNIQUE INDEX %v ON %v (%v);", name, table.name, strings.Join(index.columns, ", "))
+	}
+
+	return fmt.Sprintf("CREATE INDEX %v ON %v (%v);", name, table.name, strings.Join(index.columns, ", "))
+}
@@ -0,0 +1,124 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type driftCategory struct {
+	Id   int64
+	Name string `orm:"size(100)"`
+}
+
+func (this *driftCategory) TableName() string {
+	return "drift_categories"
+}
+
+type driftProduct struct {
+	Id        int64
+	Code      string         `orm:"size(20);unique"`
+	Name      string         `orm:"size(100)"`
+	Price     float64        `orm:"digits(10);decimals(2)"`
+	Notes     string         `orm:"type(text);null"`
+	CreatedAt time.Time      `orm:"auto_now_add"`
+	Category  *driftCategory `orm:"rel(fk);null"`
+}
+
+func (this *driftProduct) TableName() string {
+	return "drift_products"
+}
+
+func (this *driftProduct) TableIndex() [][]string {
+	return [][]string{{"Name", "Category"}}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/migrate -run TestDrift
+func TestDrift(t *testing.T) {
+
+	migrator, db := newTestMigrator(t)
+
+	report, err := migrator.Drift(new(driftCategory), new(driftProduct))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Issues) != 2 || report.Issues[0].Kind != DriftMissingTable || report.Issues[1].Kind != DriftMissingTable {
+		t.Fatalf("expected 2 missing tables, got %v", report)
+	}
+
+	// sql of report creates the schema of models
+	for _, stmt := range splitStatements(report.SQL()) {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%v: %v", stmt, err)
+		}
+	}
+
+	if report, err = migrator.Drift(new(driftCategory), new(driftProduct)); err != nil {
+		t.Fatal(err)
+	}
+
+	if report.HasDrift() {
+		t.Fatalf("expected no drift, got %v", report)
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/migrate -run TestDriftIssues
+func TestDriftIssues(t *testing.T) {
+
+	migrator, db := newTestMigrator(t)
+
+	_, err := db.Exec(`CREATE TABLE drift_products (
+		id integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+		code varchar(10) NOT NULL,
+		name varchar(100),
+		price numeric(10, 2) NOT NULL,
+		created_at datetime NOT NULL
+	)`)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := migrator.Drift(new(driftProduct))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issues := map[string]*DriftIssue{}
+	for _, it := range report.Issues {
+		issues[string(it.Kind)+":"+it.Column] = it
+	}
+
+	expected := []string{
+		"type_mismatch:code",
+		"null_mismatch:name",
+		"missing_column:notes",
+		"missing_column:category_id",
+		"missing_index:code",
+		"missing_index:name,category_id",
+	}
+
+	if len(report.Issues) != len(expected) {
+		t.Errorf("expected %v issues, got %v", len(expected), report)
+	}
+
+	for _, it := range expected {
+		if _, ok := issues[it]; !ok {
+			t.Errorf("expected issue %v, got %v", it, report)
+		}
+	}
+
+	// sqlite can't alter column, fix is a comment
+	if issue := issues["type_mismatch:code"]; issue != nil && !strings.HasPrefix(issue.SQL, "--") {
+		t.Errorf("expected comment to alter column on sqlite, got %v", issue.SQL)
+	}
+
+	if issue := issues["missing_column:notes"]; issue != nil {
+		if _, err := db.Exec(issue.SQL); err != nil {
+			t.Errorf("expected valid add column sql: %v", err)
+		}
+	}
+}
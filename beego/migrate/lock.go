@@ -0,0 +1,215 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/crc32"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/core/logs"
+)
+
+const DefaultStaleLockTTL = 5 * time.Minute
+
+// run fn holding the migration lock, so two app instances does not migrate at same time.
+// postgres uses advisory lock, mysql GET_LOCK and others (sqlite) a lock table
+func (this *Migrator) withLock(fn func(conn *sql.Conn) error) error {
+
+	conn, release, err := this.openConn()
+
+	if err != nil {
+		return err
+	}
+
+	defer release()
+
+	unlock, err := this.lock(conn)
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := unlock(); err != nil {
+			logs.Error("migration unlock error: %v", err)
+		}
+	}()
+
+	if err := this.createTable(conn); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// dedicated connection, with search_path of Schema
+func (this *Migrator) openConn() (*sql.Conn, func(), error) {
+
+	ctx := context.Background()
+	conn, err := this.db.Conn(ctx)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(this.Schema) == 0 {
+		return conn, func() { conn.Close() }, nil
+	}
+
+	if !schemaNameRegex.MatchString(this.Schema) {
+		conn.Close()
+		return nil, nil, fmt.Errorf("invalid schema name: %v", this.Schema)
+	}
+
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf(`SET search_path TO "%v"`, this.Schema)); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	release := func() {
+		// connection returns to pool
+		if _, err := conn.ExecContext(ctx, "SET search_path TO DEFAULT"); err != nil {
+			logs.Error("migration reset search_path error: %v", err)
+		}
+		conn.Close()
+	}
+
+	return conn, release, nil
+}
+
+func (this *Migrator) lock(conn *sql.Conn) (func() error, error) {
+
+	ctx := context.Background()
+	name := fmt.Sprintf("%v_lock", this.Table)
+
+	if len(this.Schema) > 0 {
+		name = fmt.Sprintf("%v_%v", this.Schema, name)
+	}
+
+	switch this.driver {
+	case orm.DRPostgres:
+
+		key := int64(crc32.ChecksumIEEE([]byte(name)))
+		deadline := time.Now().Add(this.LockTimeout)
+
+		for {
+			var locked bool
+			if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&locked); err != nil {
+				return nil, err
+			}
+			if locked {
+				break
+			}
+			if time.Now().After(deadline) {
+				return nil, fmt.Errorf("migration lock timeout after %v", this.LockTimeout)
+			}
+			time.Sleep(time.Second)
+		}
+
+		return func() error {
+			_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", key)
+			return err
+		}, nil
+
+	case orm.DRMySQL:
+
+		var locked sql.NullInt64
+		timeout := int(this.LockTimeout / time.Second)
+
+		if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", name, timeout).Scan(&locked); err != nil {
+			return nil, err
+		}
+
+		if !locked.Valid || locked.Int64 != 1 {
+			return nil, fmt.Errorf("migration lock timeout after %v", this.LockTimeout)
+		}
+
+		return func() error {
+			_, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", name)
+			return err
+		}, nil
+	}
+
+	// lock table, the row insert fails while other instance holds the lock.
+	// lock not refreshed for StaleLockTTL is stale (instance stopped without unlock) and is removed
+	staleTTL := this.StaleLockTTL
+
+	if staleTTL <= 0 {
+		staleTTL = DefaultStaleLockTTL
+	}
+
+	query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %v (id INTEGER NOT NULL PRIMARY KEY, locked_at TIMESTAMP NOT NULL)", name)
+
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(this.LockTimeout)
+
+	for {
+		_, err := conn.ExecContext(ctx, this.bind(fmt.Sprintf("INSERT INTO %v (id, locked_at) VALUES (1, ?)", name)), time.Now().UTC())
+		if err == nil {
+			break
+		}
+
+		stale := time.Now().UTC().Add(-staleTTL)
+		result, e := conn.ExecContext(ctx, this.bind(fmt.Sprintf("DELETE FROM %v WHERE id = 1 AND locked_at < ?", name)), stale)
+
+		if e != nil {
+			return nil, e
+		}
+
+		if n, _ := result.RowsAffected(); n > 0 {
+			logs.Warn("migration stale lock %v removed", name)
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("migration lock timeout after %v: %v", this.LockTimeout, err)
+		}
+		time.Sleep(time.Second)
+	}
+
+	stop := this.heartbeat(name, staleTTL/3)
+
+	return func() error {
+		stop()
+		_, err := conn.ExecContext(ctx, fmt.Sprintf("DELETE FROM %v WHERE id = 1", name))
+		return err
+	}, nil
+}
+
+// refresh locked_at of lock table each interval, on other connection, so migration transaction does not
+// hold it. returns func to stop
+func (this *Migrator) heartbeat(name string, interval time.Duration) func() {
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+
+		defer close(stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				query := this.bind(fmt.Sprintf("UPDATE %v SET locked_at = ? WHERE id = 1", name))
+				if _, err := this.db.ExecContext(context.Background(), query, time.Now().UTC()); err != nil {
+					logs.Warn("migration lock heartbeat error: %v", err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
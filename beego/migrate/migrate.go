@@ -0,0 +1,445 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/core/logs"
+)
+
+const DefaultTable = "schema_migrations"
+
+type MigrationFunc func(tx *sql.Tx) error
+
+// Migration is applied in a transaction with the schema_migrations row. Use Up and Down to go
+// migrations or UpSQL and DownSQL to sql migrations. Note: mysql commits DDL statements implicitly
+type Migration struct {
+	Version int64
+	Name    string
+	Up      MigrationFunc
+	Down    MigrationFunc
+	UpSQL   string
+	DownSQL string
+}
+
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	db         *sql.DB
+	driver     orm.DriverType
+	migrations []*Migration
+
+	// schema migrations table, default is schema_migrations
+	Table string
+	// max wait of migration lock, default is 1 minute
+	LockTimeout time.Duration
+	// lock table (sqlite) lock not refreshed for it is removed as stale, default is 5 minutes.
+	// The lock is refreshed while migrations run
+	StaleLockTTL time.Duration
+	// postgres schema of migrations, set as search_path of migration connection
+	Schema string
+	Debug  bool
+}
+
+func New(db *sql.DB, driver orm.DriverType) *Migrator {
+	return &Migrator{db: db, driver: driver, Table: DefaultTable, LockTimeout: time.Minute, StaleLockTTL: DefaultStaleLockTTL}
+}
+
+// NewWithAlias migrator of beego orm registered database alias. eg.: migrate.NewWithAlias("default")
+func NewWithAlias(alias string) (*Migrator, error) {
+
+	db, err := orm.GetDB(alias)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return New(db, orm.NewOrmUsingDB(alias).Driver().Type()), nil
+}
+
+// Add go migration. Versions should be unique, are applied in ascending order
+func (this *Migrator) Add(version int64, name string, up MigrationFunc, down MigrationFunc) *Migrator {
+	return this.AddMigration(&Migration{Version: version, Name: name, Up: up, Down: down})
+}
+
+// AddSQL sql migration. Statements are separated by ;
+func (this *Migrator) AddSQL(version int64, name string, upSQL string, downSQL string) *Migrator {
+	return this.AddMigration(&Migration{Version: version, Name: name, UpSQL: upSQL, DownSQL: downSQL})
+}
+
+func (this *Migrator) AddMigration(migrations ...*Migration) *Migrator {
+	this.migrations = append(this.migrations, migrations...)
+	return this
+}
+
+func (this *Migrator) Migrations() []*Migration {
+	return this.sorted()
+}
+
+func (this *Migrator) sorted() []*Migration {
+	migrations := append([]*Migration{}, this.migrations...)
+	sort.SliceStable(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations
+}
+
+func (this *Migrator) validate() error {
+	versions := map[int64]string{}
+	for _, it := range this.migrations {
+		if it.Version <= 0 {
+			return fmt.Errorf("migration %v: version should be greater than zero", it.Name)
+		}
+		if name, ok := versions[it.Version]; ok {
+			return fmt.Errorf("migration version %v is duplicated: %v and %v", it.Version, name, it.Name)
+		}
+		if it.Up == nil && len(strings.TrimSpace(it.UpSQL)) == 0 {
+			return fmt.Errorf("migration %v_%v: up is required", it.Version, it.Name)
+		}
+		versions[it.Version] = it.Name
+	}
+	return nil
+}
+
+// Up apply all pending migrations. Returns the applied migrations
+func (this *Migrator) Up() ([]*Migration, error) {
+
+	if err := this.validate(); err != nil {
+		return nil, err
+	}
+
+	applied := []*Migration{}
+
+	err := this.withLock(func(conn *sql.Conn) error {
+
+		versions, err := this.appliedVersions(conn)
+
+		if err != nil {
+			return err
+		}
+
+		for _, it := range this.sorted() {
+
+			if _, ok := versions[it.Version]; ok {
+				continue
+			}
+
+			if err := this.apply(conn, it, true); err != nil {
+				return err
+			}
+
+			applied = append(applied, it)
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down revert the last n applied migrations. Returns the reverted migrations
+func (this *Migrator) Down(n int) ([]*Migration, error) {
+
+	if err := this.validate(); err != nil {
+		return nil, err
+	}
+
+	reverted := []*Migration{}
+
+	err := this.withLock(func(conn *sql.Conn) error {
+
+		versions, err := this.appliedVersions(conn)
+
+		if err != nil {
+			return err
+		}
+
+		migrations := this.sorted()
+
+		for i := len(migrations) - 1; i >= 0 && len(reverted) < n; i-- {
+
+			it := migrations[i]
+
+			if _, ok := versions[it.Version]; !ok {
+				continue
+			}
+
+			if it.Down == nil && len(strings.TrimSpace(it.DownSQL)) == 0 {
+				return fmt.Errorf("migration %v_%v: down is not defined", it.Version, it.Name)
+			}
+
+			if err := this.apply(conn, it, false); err != nil {
+				return err
+			}
+
+			reverted = append(reverted, it)
+		}
+
+		return nil
+	})
+
+	return reverted, err
+}
+
+// Status of all migrations, ordered by version. Applied versions without migration are included
+func (this *Migrator) Status() ([]*MigrationStatus, error) {
+
+	conn, release, err := this.openConn()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer release()
+
+	if err := this.createTable(conn); err != nil {
+		return nil, err
+	}
+
+	versions, err := this.appliedVersions(conn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	status := []*MigrationStatus{}
+
+	for _, it := range this.sorted() {
+		st := &MigrationStatus{Version: it.Version, Name: it.Name}
+		if applied, ok := versions[it.Version]; ok {
+			st.Applied = true
+			st.AppliedAt = applied.AppliedAt
+			delete(versions, it.Version)
+		}
+		status = append(status, st)
+	}
+
+	for _, it := range versions {
+		status = append(status, it)
+	}
+
+	sort.SliceStable(status, func(i, j int) bool {
+		return status[i].Version < status[j].Version
+	})
+
+	return status, nil
+}
+
+func (this *Migrator) apply(conn *sql.Conn, migration *Migration, up bool) error {
+
+	ctx := context.Background()
+	action := "up"
+
+	if !up {
+		action = "down"
+	}
+
+	logs.Info("migration %v %v_%v", action, migration.Version, migration.Name)
+
+	tx, err := conn.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	run := func() error {
+
+		fn, query := migration.Up, migration.UpSQL
+
+		if !up {
+			fn, query = migration.Down, migration.DownSQL
+		}
+
+		if fn != nil {
+			if err := fn(tx); err != nil {
+				return err
+			}
+		} else {
+			for _, stmt := range splitStatements(query) {
+				if this.Debug {
+					logs.Debug("## migration sql: %v", stmt)
+				}
+				if _, err := tx.ExecContext(ctx, stmt); err != nil {
+					return err
+				}
+			}
+		}
+
+		if up {
+			_, err = tx.ExecContext(ctx,
+				this.bind(fmt.Sprintf("INSERT INTO %v (version, name, applied_at) VALUES (?, ?, ?)", this.Table)),
+				migration.Version, migration.Name, time.Now().UTC())
+		} else {
+			_, err = tx.ExecContext(ctx,
+				this.bind(fmt.Sprintf("DELETE FROM %v WHERE version = ?", this.Table)), migration.Version)
+		}
+
+		return err
+	}
+
+	if err := run(); err != nil {
+		if e := tx.Rollback(); e != nil {
+			logs.Error("migration rollback error: %v", e)
+		}
+		return fmt.Errorf("migration %v %v_%v: %v", action, migration.Version, migration.Name, err)
+	}
+
+	return tx.Commit()
+}
+
+func (this *Migrator) createTable(conn *sql.Conn) error {
+
+	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %v (
+	version BIGINT NOT NULL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	applied_at TIMESTAMP NOT NULL
+)`, this.Table)
+
+	_, err := conn.ExecContext(context.Background(), query)
+	return err
+}
+
+func (this *Migrator) appliedVersions(conn *sql.Conn) (map[int64]*MigrationStatus, error) {
+
+	rows, err := conn.QueryContext(context.Background(),
+		fmt.Sprintf("SELECT version, name, applied_at FROM %v ORDER BY version", this.Table))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	versions := map[int64]*MigrationStatus{}
+
+	for rows.Next() {
+		st := &MigrationStatus{Applied: true}
+		if err := rows.Scan(&st.Version, &st.Name, &st.AppliedAt); err != nil {
+			return nil, err
+		}
+		versions[st.Version] = st
+	}
+
+	return versions, rows.Err()
+}
+
+// replace ? with $n on postgres
+func (this *Migrator) bind(query string) string {
+
+	if this.driver != orm.DRPostgres {
+		return query
+	}
+
+	var sb strings.Builder
+	n := 0
+
+	for _, c := range query {
+		if c == '?' {
+			n++
+			fmt.Fprintf(&sb, "$%v", n)
+		} else {
+			sb.WriteRune(c)
+		}
+	}
+
+	return sb.String()
+}
+
+// split sql statements by ;, ignoring ; inside quotes, postgres dollar quoted bodies ($$ or $tag$)
+// and comments. Comments are removed
+func splitStatements(query string) []string {
+
+	stmts := []string{}
+	var sb strings.Builder
+
+	flush := func() {
+		if stmt := strings.TrimSpace(sb.String()); len(stmt) > 0 {
+			stmts = append(stmts, stmt)
+		}
+		sb.Reset()
+	}
+
+	for i := 0; i < len(query); i++ {
+
+		c := query[i]
+		rest := query[i:]
+
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			// quoted text, '' is escaped quote
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				sb.WriteString(rest)
+				i = len(query)
+				continue
+			}
+			sb.WriteString(query[i : i+end+2])
+			i += end + 1
+		case strings.HasPrefix(rest, "--"):
+			// line comment, the line break is kept
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				i = len(query)
+				continue
+			}
+			i += end - 1
+		case strings.HasPrefix(rest, "/*"):
+			end := strings.Index(rest[2:], "*/")
+			if end < 0 {
+				i = len(query)
+				continue
+			}
+			sb.WriteByte(' ')
+			i += end + 3
+		case c == '$':
+			tag := dollarQuoteTag(rest)
+			if len(tag) == 0 {
+				sb.WriteByte(c)
+				continue
+			}
+			end := strings.Index(rest[len(tag):], tag)
+			if end < 0 {
+				sb.WriteString(rest)
+				i = len(query)
+				continue
+			}
+			sb.WriteString(rest[:len(tag)+end+len(tag)])
+			i += len(tag) + end + len(tag) - 1
+		case c == ';':
+			flush()
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	flush()
+
+	return stmts
+}
+
+// tag of postgres dollar quote on start of text, $$ or $name$. returns empty if is not a dollar quote
+func dollarQuoteTag(text string) string {
+
+	for i := 1; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '$':
+			return text[:i+1]
+		case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 1 && c >= '0' && c <= '9'):
+		default:
+			return ""
+		}
+	}
+
+	return ""
+}
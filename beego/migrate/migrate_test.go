@@ -0,0 +1,191 @@
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newTestMigrator(t *testing.T) (*Migrator, *sql.DB) {
+
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "migrate.db"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	return New(db, orm.DRSqlite), db
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/migrate -run TestSplitStatements
+func TestSplitStatements(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{"statements", "create table a (id int); create table b (id int);", []string{"create table a (id int)", "create table b (id int)"}},
+		{"quotes", "insert into a values ('x;y', \"c;d\"); select 1", []string{"insert into a values ('x;y', \"c;d\")", "select 1"}},
+		{"escaped quote", "insert into a values ('it''s; ok')", []string{"insert into a values ('it''s; ok')"}},
+		{"line comment", "-- create; table\nselect 1; -- last;", []string{"select 1"}},
+		{"comment on statement", "select 1 -- one; two\n, 2;", []string{"select 1 \n, 2"}},
+		{"block comment", "/* a; b */ select 1;", []string{"select 1"}},
+		{"dollar body", "create function f() returns int as $$ begin return 1; end; $$ language plpgsql; select 1",
+			[]string{"create function f() returns int as $$ begin return 1; end; $$ language plpgsql", "select 1"}},
+		{"dollar tag", "do $body$ begin perform 1; end $body$; select 1", []string{"do $body$ begin perform 1; end $body$", "select 1"}},
+		{"placeholder", "select $1; select 2", []string{"select $1", "select 2"}},
+	}
+
+	for _, it := range tests {
+		t.Run(it.name, func(t *testing.T) {
+			if stmts := splitStatements(it.query); !reflect.DeepEqual(stmts, it.expected) {
+				t.Errorf("expected %q, got %q", it.expected, stmts)
+			}
+		})
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/migrate -run TestUpDown
+func TestUpDown(t *testing.T) {
+
+	migrator, db := newTestMigrator(t)
+
+	migrator.
+		AddSQL(2, "create_products", "-- products\ncreate table products (id integer primary key, name text);", "drop table products").
+		AddSQL(1, "create_categories", "create table categories (id integer primary key); insert into categories values (1);", "drop table categories")
+
+	applied, err := migrator.Up()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(applied) != 2 || applied[0].Version != 1 {
+		t.Fatalf("expected 2 applied migrations in version order, got %v", len(applied))
+	}
+
+	if applied, _ = migrator.Up(); len(applied) != 0 {
+		t.Errorf("expected no pending migrations, got %v", len(applied))
+	}
+
+	status, err := migrator.Status()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(status) != 2 || !status[0].Applied || !status[1].Applied {
+		t.Errorf("expected 2 applied migrations on status")
+	}
+
+	reverted, err := migrator.Down(1)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reverted) != 1 || reverted[0].Version != 2 {
+		t.Fatalf("expected version 2 reverted")
+	}
+
+	if _, err := db.Exec("select 1 from products"); err == nil {
+		t.Errorf("expected products dropped")
+	}
+
+	// failed migration is rolled back
+	migrator.AddSQL(3, "invalid", "create table others (id integer); invalid sql", "")
+
+	if _, err := migrator.Up(); err == nil {
+		t.Fatalf("expected error of invalid migration")
+	}
+
+	status, _ = migrator.Status()
+
+	if len(status) != 3 || !status[0].Applied || status[2].Applied {
+		t.Errorf("expected invalid migration not applied")
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/migrate -run TestLock
+func TestLock(t *testing.T) {
+
+	migrator, db := newTestMigrator(t)
+	migrator.AddSQL(1, "create_categories", "create table categories (id integer primary key)", "")
+
+	name := fmt.Sprintf("%v_lock", migrator.Table)
+
+	if _, err := db.Exec(fmt.Sprintf("CREATE TABLE %v (id INTEGER NOT NULL PRIMARY KEY, locked_at TIMESTAMP NOT NULL)", name)); err != nil {
+		t.Fatal(err)
+	}
+
+	// lock of other instance, not stale while waiting
+	if _, err := db.Exec(fmt.Sprintf("INSERT INTO %v (id, locked_at) VALUES (1, ?)", name), time.Now().UTC().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	migrator.LockTimeout = 10 * time.Millisecond
+
+	if _, err := migrator.Up(); err == nil {
+		t.Fatalf("expected lock timeout")
+	}
+
+	// stale lock of stopped instance
+	if _, err := db.Exec(fmt.Sprintf("UPDATE %v SET locked_at = ?", name), time.Now().UTC().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	migrator.LockTimeout = time.Minute
+
+	if applied, err := migrator.Up(); err != nil || len(applied) != 1 {
+		t.Fatalf("expected stale lock removed and migration applied, got %v", err)
+	}
+
+	var count int
+
+	if err := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %v", name)).Scan(&count); err != nil || count != 0 {
+		t.Errorf("expected lock released, got %v", count)
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/migrate -run TestLockHeartbeat
+func TestLockHeartbeat(t *testing.T) {
+
+	migrator, _ := newTestMigrator(t)
+	migrator.StaleLockTTL = 30 * time.Millisecond
+
+	name := fmt.Sprintf("%v_lock", migrator.Table)
+	start := time.Now().UTC()
+
+	migrator.Add(1, "slow", func(tx *sql.Tx) error {
+
+		time.Sleep(100 * time.Millisecond)
+
+		var lockedAt time.Time
+
+		if err := tx.QueryRow(fmt.Sprintf("SELECT locked_at FROM %v", name)).Scan(&lockedAt); err != nil {
+			return err
+		}
+
+		// lock of slow migration is not stale
+		if !lockedAt.After(start.Add(20 * time.Millisecond)) {
+			t.Errorf("expected lock refreshed while migration runs, got %v of start %v", lockedAt, start)
+		}
+
+		return nil
+	}, nil)
+
+	if _, err := migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+}
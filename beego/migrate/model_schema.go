@@ -0,0 +1,226 @@
+package migrate
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/mobilemindtech/go-utils/beego/db"
+)
+
+var (
+	registeredModels   = []interface{}{}
+	registeredModelsMu sync.Mutex
+)
+
+// RegisterModel register models on beego orm and keep them to schema drift check
+func RegisterModel(models ...interface{}) {
+	registeredModelsMu.Lock()
+	registeredModels = append(registeredModels, models...)
+	registeredModelsMu.Unlock()
+	orm.RegisterModel(models...)
+}
+
+func getRegisteredModels() []interface{} {
+	registeredModelsMu.Lock()
+	defer registeredModelsMu.Unlock()
+	return append([]interface{}{}, registeredModels...)
+}
+
+// table schema expected by model, following beego orm rules
+type modelTable struct {
+	name    string
+	model   string
+	columns []*modelColumn
+	indexes []*modelIndex
+}
+
+type modelColumn struct {
+	field    string
+	name     string
+	typ      string
+	null     bool
+	pk       bool
+	auto     bool
+	unique   bool
+	index    bool
+	defaults string
+}
+
+type modelIndex struct {
+	columns []string
+	unique  bool
+}
+
+func (this *modelTable) column(name string) *modelColumn {
+	for _, it := range this.columns {
+		if it.name == name || it.field == name {
+			return it
+		}
+	}
+	return nil
+}
+
+func getModelTable(model interface{}, driver orm.DriverType) (*modelTable, error) {
+
+	value := reflect.ValueOf(model)
+
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("model %T should be a pointer of struct", model)
+	}
+
+	typ := value.Elem().Type()
+	table := &modelTable{model: typ.String(), name: db.SnakeString(typ.Name())}
+
+	if m, ok := model.(interface{ TableName() string }); ok {
+		table.name = m.TableName()
+	}
+
+	for _, field := range db.GetModelFields(typ) {
+
+		column := &modelColumn{
+			field:    field.Name,
+			name:     field.Column,
+			null:     field.Null,
+			pk:       field.Pk,
+			auto:     field.Auto,
+			unique:   field.Unique,
+			index:    field.Attrs["index"],
+			defaults: field.Values["default"],
+		}
+
+		fieldType := field.Type
+
+		// relation column has the type of related pk
+		if field.Rel {
+			fieldType = reflect.TypeOf(int64(0))
+			if field.Type.Kind() == reflect.Ptr && field.Type.Elem().Kind() == reflect.Struct {
+				if pk, ok := field.Type.Elem().FieldByName("Id"); ok {
+					fieldType = pk.Type
+				}
+			}
+		}
+
+		typ, ok := getColumnType(driver, fieldType, field.Values)
+
+		if !ok {
+			// struct fields that are not columns
+			continue
+		}
+
+		column.typ = typ
+		table.columns = append(table.columns, column)
+
+		if column.index && !column.pk {
+			table.indexes = append(table.indexes, &modelIndex{columns: []string{column.name}})
+		}
+
+		if column.unique && !column.pk {
+			table.indexes = append(table.indexes, &modelIndex{columns: []string{column.name}, unique: true})
+		}
+	}
+
+	if m, ok := model.(interface{ TableIndex() [][]string }); ok {
+		for _, it := range m.TableIndex() {
+			index, err := table.modelIndex(it, false)
+			if err != nil {
+				return nil, err
+			}
+			table.indexes = append(table.indexes, index)
+		}
+	}
+
+	if m, ok := model.(interface{ TableUnique() [][]string }); ok {
+		for _, it := range m.TableUnique() {
+			index, err := table.modelIndex(it, true)
+			if err != nil {
+				return nil, err
+			}
+			table.indexes = append(table.indexes, index)
+		}
+	}
+
+	return table, nil
+}
+
+func (this *modelTable) modelIndex(fields []string, unique bool) (*modelIndex, error) {
+	index := &modelIndex{unique: unique}
+	for _, it := range fields {
+		column := this.column(it)
+		if column == nil {
+			return nil, fmt.Errorf("model %v: index field %v not found", this.model, it)
+		}
+		index.columns = append(index.columns, column.name)
+	}
+	return index, nil
+}
+
+// column type of beego orm syncdb. returns false if field is not a column
+func getColumnType(driver orm.DriverType, fieldType reflect.Type, values map[string]string) (string, bool) {
+
+	if fieldType.Kind() == reflect.Ptr {
+		fieldType = fieldType.Elem()
+	}
+
+	size := values["size"]
+	if len(size) == 0 {
+		size = "255"
+	}
+
+	if fieldType == reflect.TypeOf(time.Time{}) {
+		switch values["type"] {
+		case "date":
+			return "date", true
+		}
+		if driver == orm.DRPostgres {
+			return "timestamp with time zone", true
+		}
+		return "datetime", true
+	}
+
+	switch fieldType.Kind() {
+	case reflect.Bool:
+		return "bool", true
+	case reflect.String:
+		switch values["type"] {
+		case "text":
+			if driver == orm.DRMySQL {
+				return "longtext", true
+			}
+			return "text", true
+		case "char":
+			return fmt.Sprintf("char(%v)", size), true
+		case "json", "jsonb":
+			if driver == orm.DRPostgres {
+				return values["type"], true
+			}
+		}
+		return fmt.Sprintf("varchar(%v)", size), true
+	case reflect.Int8, reflect.Uint8:
+		if driver == orm.DRPostgres {
+			return "smallint", true
+		}
+		return "tinyint", true
+	case reflect.Int16, reflect.Uint16:
+		return "smallint", true
+	case reflect.Int, reflect.Int32, reflect.Uint, reflect.Uint32:
+		return "integer", true
+	case reflect.Int64, reflect.Uint64:
+		if driver == orm.DRSqlite {
+			return "integer", true
+		}
+		return "bigint", true
+	case reflect.Float32, reflect.Float64:
+		if digits, ok := values["digits"]; ok {
+			return fmt.Sprintf("numeric(%v, %v)", digits, values["decimals"]), true
+		}
+		if driver == orm.DRSqlite {
+			return "real", true
+		}
+		return "double precision", true
+	}
+
+	return "", false
+}
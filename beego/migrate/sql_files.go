@@ -0,0 +1,80 @@
+package migrate
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// <version>_<name>.up.sql and <version>_<name>.down.sql. eg.: 0001_create_users.up.sql
+var sqlFileRegex = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// AddSQLDir add sql migrations of dir, to use with embed.FS. eg.:
+//
+//	//go:embed migrations/*.sql
+//	var migrations embed.FS
+//	m.AddSQLDir(migrations, "migrations")
+func (this *Migrator) AddSQLDir(fsys fs.FS, dir string) error {
+
+	entries, err := fs.ReadDir(fsys, dir)
+
+	if err != nil {
+		return err
+	}
+
+	migrations := map[int64]*Migration{}
+	versions := []int64{}
+
+	for _, entry := range entries {
+
+		if entry.IsDir() {
+			continue
+		}
+
+		m := sqlFileRegex.FindStringSubmatch(entry.Name())
+
+		if m == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(m[1], 10, 64)
+
+		if err != nil {
+			return fmt.Errorf("invalid migration file %v: %v", entry.Name(), err)
+		}
+
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+
+		if err != nil {
+			return err
+		}
+
+		migration, ok := migrations[version]
+
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			migrations[version] = migration
+			versions = append(versions, version)
+		} else if migration.Name != m[2] {
+			return fmt.Errorf("migration version %v is duplicated: %v and %v", version, migration.Name, m[2])
+		}
+
+		if m[3] == "up" {
+			migration.UpSQL = string(data)
+		} else {
+			migration.DownSQL = string(data)
+		}
+	}
+
+	for _, version := range versions {
+		if len(strings.TrimSpace(migrations[version].UpSQL)) == 0 {
+			return fmt.Errorf("migration %v_%v: up file not found", version, migrations[version].Name)
+		}
+		this.AddMigration(migrations[version])
+	}
+
+	return nil
+}
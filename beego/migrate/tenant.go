@@ -0,0 +1,123 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/core/logs"
+	"github.com/mobilemindtech/go-utils/beego/db"
+)
+
+var schemaNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ProvisionTenant create the schema or database of tenant, by db.SetTenantIsolation mode, and apply the migrations
+// added by setup. Returns the applied migrations. eg.:
+//
+//	applied, err := migrate.ProvisionTenant(tenant.Id, func(m *migrate.Migrator) error {
+//		return m.AddSQLDir(migrations, "migrations/tenant")
+//	})
+func ProvisionTenant(tenantId int64, setup func(m *Migrator) error) ([]*Migration, error) {
+
+	isolation := db.GetTenantIsolation()
+
+	if isolation == nil {
+		return nil, errors.New("tenant isolation not configured")
+	}
+
+	if !isolation.IsIsolated(tenantId) {
+		return nil, fmt.Errorf("tenant %v is not isolated", tenantId)
+	}
+
+	name, err := isolation.QuotedTenantName(tenantId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	primary, err := orm.GetDB(isolation.Alias)
+
+	if err != nil {
+		return nil, err
+	}
+
+	driver := orm.NewOrmUsingDB(isolation.Alias).Driver().Type()
+
+	var m *Migrator
+
+	switch isolation.Mode {
+
+	case db.TenantIsolationSchema:
+
+		if driver != orm.DRPostgres {
+			return nil, errors.New("schema isolation requires postgres")
+		}
+
+		if _, err := primary.Exec(fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %v", name)); err != nil {
+			return nil, fmt.Errorf("create tenant schema %v: %v", name, err)
+		}
+
+		m = New(primary, driver)
+		m.Schema = isolation.TenantName(tenantId)
+
+	case db.TenantIsolationDatabase:
+
+		if err := createTenantDatabase(primary, driver, isolation.TenantName(tenantId), name); err != nil {
+			return nil, fmt.Errorf("create tenant database %v: %v", name, err)
+		}
+
+		alias, err := isolation.RegisterTenantAlias(tenantId)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if m, err = NewWithAlias(alias); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := setup(m); err != nil {
+		return nil, err
+	}
+
+	applied, err := m.Up()
+
+	if err == nil {
+		logs.Info("tenant %v provisioned, %v migrations applied", tenantId, len(applied))
+	}
+
+	return applied, err
+}
+
+// sqlite database is created on connect
+func createTenantDatabase(primary *sql.DB, driver orm.DriverType, database string, quoted string) error {
+
+	switch driver {
+
+	case orm.DRPostgres:
+
+		var exists bool
+
+		if err := primary.QueryRow("SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1)", database).Scan(&exists); err != nil {
+			return err
+		}
+
+		if exists {
+			return nil
+		}
+
+		// can't run in a transaction
+		_, err := primary.Exec(fmt.Sprintf("CREATE DATABASE %v", quoted))
+		return err
+
+	case orm.DRMySQL:
+
+		_, err := primary.Exec(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%v`", database))
+		return err
+	}
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package features
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
@@ -19,6 +20,8 @@ import (
 	"github.com/mobilemindtech/go-utils/v2/maps"
 )
 
+var errTenantNotResolved = errors.New("tenant not resolved")
+
 type WebAuth struct {
 	IsWebLoggedIn       bool
 	IsTokenLoggedIn     bool
@@ -91,19 +94,16 @@ func (this *WebAuth) AuthPrepare() {
 	this.IsTokenLoggedIn = this.base.GetBeegoController().GetSession("appuserinfo") != nil
 	this.IsCustomAppLoggedIn = this.base.GetBeegoController().GetSession("customappuserinfo") != nil
 
-	var tenant *models.Tenant
-
-	tenantUuid := this.GetHeaderTenant()
+	tenant := this.ResolveTenant()
+	resolved := tenant
 
-	if len(tenantUuid) > 0 {
-		loader := func() (*models.Tenant, error) {
-			return this.base.GetModelTenant().GetByUuidAndEnabled(tenantUuid)
-
-		}
-		tenant, _ = cache.Memoize(this.base.GetCacheService(), tenantUuid, new(models.Tenant), loader)
+	if tenant != nil {
 		this.SetAuthTenant(tenant)
 	}
 
+	// custom resolvers take precedence over the tenant of web session
+	customResolvers := len(this.base.GetWebConfigs().TenantResolvers) > 0
+
 	if this.IsLoggedIn() {
 
 		if this.IsWebLoggedIn {
@@ -117,7 +117,10 @@ func (this *WebAuth) AuthPrepare() {
 			this.SetAuthUser(this.GetCustomAppLogin())
 		}
 
-		if this.IsWebLoggedIn {
+		// user info of root check on tenant auth
+		this.Auth.SetUserInfo(this.GetAuthUser())
+
+		if this.IsWebLoggedIn && (tenant == nil || !customResolvers) {
 			tenant = this.GetAuthTenantSession()
 			if tenant == nil {
 				tenant, _ = this.base.GetModelTenantUser().GetFirstTenant(this.GetAuthUser())
@@ -141,6 +144,17 @@ func (this *WebAuth) AuthPrepare() {
 			return
 		}
 
+		if resolved != nil && tenant == resolved && !this.HasTenantAuth(tenant) {
+			logs.Error("ERROR: user %v not authorized to tenant %v", this.GetAuthUser().Id, tenant.Id)
+
+			if this.IsTokenLoggedIn || this.base.IsJson() {
+				this.base.RenderJsonWithForbidden("operation not permitted", false)
+			} else {
+				this.base.RenderJsonOrRedirect("/", "operation not permitted")
+			}
+			return
+		}
+
 		if !tenant.Enabled && !services.IsRootUser(this.GetAuthUser()) {
 			logs.Error("ERROR: tenant ", tenant.Id, " - ", tenant.Name, " is disabled")
 
@@ -166,9 +180,6 @@ func (this *WebAuth) AuthPrepare() {
 
 		this.base.GetBeegoController().Data["UserInfo"] = this.GetAuthUser()
 		this.base.GetBeegoController().Data["Tenant"] = this.GetAuthTenant()
-
-		//ioc.Get[services.AuthService](this.Container)
-		this.Auth.SetUserInfo(this.GetAuthUser())
 	}
 
 	this.base.GetBeegoController().Data["IsLoggedIn"] = this.IsLoggedIn()
@@ -201,6 +212,50 @@ func (this *WebAuth) IsBearerToken() bool {
 	return this.Auth.IsBearerToken(this.GetHeaderToken())
 }
 
+// ResolveTenant tenant of first resolver of WebConfigs tenant resolvers chain. nil when not resolved
+func (this *WebAuth) ResolveTenant() *models.Tenant {
+
+	ctx := this.base.GetBeegoController().Ctx
+
+	for _, resolver := range this.base.GetWebConfigs().GetTenantResolvers() {
+
+		key := resolver.ResolveTenant(ctx)
+
+		if len(key) == 0 {
+			continue
+		}
+
+		loader := func() (tenant *models.Tenant, err error) {
+			if keyLoader, ok := resolver.(trait.TenantKeyLoader); ok {
+				tenant, err = keyLoader.LoadTenant(this.base.GetSession(), key)
+			} else {
+				tenant, err = this.base.GetModelTenant().GetByUuidAndEnabled(key)
+			}
+			// errors are not cached, so unknown keys are not cached too
+			if err == nil && (tenant == nil || !tenant.IsPersisted()) {
+				err = errTenantNotResolved
+			}
+			return tenant, err
+		}
+
+		cacheKey := fmt.Sprintf("tenant_resolver_%T_%v", resolver, key)
+		tenant, err := cache.Memoize(this.base.GetCacheService(), cacheKey, new(models.Tenant), loader)
+
+		if err != nil {
+			if !errors.Is(err, errTenantNotResolved) {
+				logs.Error("ERROR: resolve tenant %v: %v", key, err)
+			}
+			continue
+		}
+
+		if tenant != nil && tenant.IsPersisted() {
+			return tenant
+		}
+	}
+
+	return nil
+}
+
 func (this *WebAuth) GetHeaderTenant() string {
 	return this.base.GetHeaderByNames("tenant", "X-Auth-Tenant")
 }
@@ -356,6 +411,11 @@ func (this *WebAuth) SessionLogOut() {
 }
 
 func (this *WebAuth) LogOut() {
+	if token := this.GetHeaderToken(); this.Auth != nil && this.Auth.IsBearerToken(token) {
+		if err := this.Auth.RevokeToken(token); err != nil {
+			logs.Debug("bearer token not revoked: %v", err)
+		}
+	}
 	this.base.GetCacheService().Delete(this.cacheKeysDeleteOnLogOut...)
 	bee := this.base.GetBeegoController()
 	bee.DelSession("userinfo")
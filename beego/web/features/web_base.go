@@ -78,6 +78,11 @@ func (this *WebBase) InitWebBase(ctrl *beego.Controller) {
 	// set current tenant on session
 	this.Session.Tenant = this.GetAuthTenant()
 
+	// set current user on session, used by entity audit
+	if user := this.GetAuthUser(); user != nil {
+		this.Session.SetAuditUserId(user.Id)
+	}
+
 	// load session tenants for authenticated user
 	this.LoadUserTenants()
 
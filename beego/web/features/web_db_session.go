@@ -1,6 +1,8 @@
 package features
 
 import (
+	"fmt"
+
 	"github.com/beego/beego/v2/core/logs"
 	"github.com/mobilemindtech/go-utils/assert"
 	"github.com/mobilemindtech/go-utils/beego/db"
@@ -11,6 +13,8 @@ import (
 type WebDbSession struct {
 	Session *db.Session
 	base    trait.WebBaseInterface
+	// query stats of closed session
+	queryStats *db.QueryStats
 }
 
 func (this *WebDbSession) InitWebDbSession(base trait.WebBaseInterface) {
@@ -31,6 +35,9 @@ func (this *WebDbSession) WebControllerCreateSession() {
 	assert.Assert(session != nil, "session is nil")
 
 	this.Session = session
+
+	// per request query stats, when db.EnableQueryLog
+	session.BeginQueryScope()
 }
 
 func (this *WebDbSession) CreateSession() *db.Session {
@@ -54,6 +61,7 @@ func (this *WebDbSession) RollbackDbSession() {
 
 func (this *WebDbSession) CloseDbSession() {
 	if this.Session != nil {
+		this.queryStats = this.Session.EndQueryScope()
 		this.Session.Close()
 		this.Session = nil
 	}
@@ -61,7 +69,23 @@ func (this *WebDbSession) CloseDbSession() {
 
 func (this *WebDbSession) CloseDbSessionWithError() {
 	if this.Session != nil {
+		this.queryStats = this.Session.EndQueryScope()
 		this.Session.OnError().Close()
 		this.Session = nil
 	}
 }
+
+// LogQueryStats log query count, db time and N+1 suspicions of request, when db.EnableQueryLog
+func (this *WebDbSession) LogQueryStats() {
+	stats := this.queryStats
+	this.queryStats = nil
+
+	if stats == nil && this.Session != nil {
+		stats = this.Session.EndQueryScope()
+	}
+
+	if stats != nil {
+		input := this.base.GetBeegoController().Ctx.Input
+		db.LogQueryStats(fmt.Sprintf("%v %v", input.Method(), input.URL()), stats)
+	}
+}
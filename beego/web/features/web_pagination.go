@@ -26,8 +26,11 @@ func (this *WebPagination) GetPage() *db.Page {
 
 			jsonData := json.Try(this.base.GetRawBody()).OrNil()
 
-			if jsonData != nil && jsonData.HasKey("limit") {
+			if jsonData != nil && (jsonData.HasKey("limit") || jsonData.HasKey("cursor")) {
 				page.Limit = jsonData.OptInt64("limit", defaultLimit)
+				page.Cursor = jsonData.GetString("cursor")
+				// "cursor": "" requests the first keyset page
+				page.CursorMode = jsonData.HasKey("cursor")
 				page.Offset = jsonData.OptInt64("offset", 0)
 				page.Search = jsonData.GetString("search")
 				page.Sort = jsonData.GetString("sort")
@@ -38,6 +41,9 @@ func (this *WebPagination) GetPage() *db.Page {
 				if len(page.Order) == 0 {
 					page.Order = jsonData.GetString("order_sort")
 				}
+				if filter, ok := jsonData.GetData()["filter"]; ok && filter != nil {
+					page.SetFilterValue(filter)
+				}
 				return page
 			}
 
@@ -45,9 +51,14 @@ func (this *WebPagination) GetPage() *db.Page {
 	}
 
 	limitStr := this.base.GetQuery("limit")
+	cursor := this.base.GetQuery("cursor")
+	// ?cursor= requests the first keyset page
+	_, cursorMode := this.base.GetCtx().Request.URL.Query()["cursor"]
 
-	if len(limitStr) > 0 {
+	if len(limitStr) > 0 || cursorMode {
 
+		page.Cursor = cursor
+		page.CursorMode = cursorMode
 		page.Limit = support.StrToInt64(limitStr)
 		if page.Limit <= 0 {
 			page.Limit = defaultLimit
@@ -65,6 +76,11 @@ func (this *WebPagination) GetPage() *db.Page {
 
 		page.Offset = support.StrToInt64(this.base.GetQuery("offset"))
 		page.Search = this.base.GetQuery("search")
+
+		// json filter DSL. eg.: ?filter={"and":[{"path":"Name","op":"icontains","value":"x"}]}
+		if filter := this.base.GetQuery("filter"); len(filter) > 0 {
+			page.SetFilterJSON([]byte(filter))
+		}
 	}
 	return page
 }
@@ -0,0 +1,194 @@
+package trait
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/beego/beego/v2/core/logs"
+	"github.com/beego/beego/v2/server/web/context"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mobilemindtech/go-utils/app/models"
+	"github.com/mobilemindtech/go-utils/beego/db"
+)
+
+// TenantResolver resolve tenant key of request, by default the tenant uuid. Returns empty when not resolved.
+// Resolvers are configured as ordered chain on WebConfigs.SetTenantResolvers, the first key resolved is used
+type TenantResolver interface {
+	ResolveTenant(ctx *context.Context) string
+}
+
+// TenantKeyLoader resolver with custom tenant loader, when the key is not the tenant uuid
+type TenantKeyLoader interface {
+	LoadTenant(session *db.Session, key string) (*models.Tenant, error)
+}
+
+type TenantResolverFunc func(ctx *context.Context) string
+
+func (this TenantResolverFunc) ResolveTenant(ctx *context.Context) string {
+	return this(ctx)
+}
+
+// HeaderTenantResolver tenant uuid of header. default headers are tenant and X-Auth-Tenant
+type HeaderTenantResolver struct {
+	Names []string
+}
+
+func NewHeaderTenantResolver(names ...string) *HeaderTenantResolver {
+	if len(names) == 0 {
+		names = []string{"tenant", "X-Auth-Tenant"}
+	}
+	return &HeaderTenantResolver{Names: names}
+}
+
+func (this *HeaderTenantResolver) ResolveTenant(ctx *context.Context) string {
+	for _, name := range this.Names {
+		if value := strings.TrimSpace(ctx.Input.Header(name)); len(value) > 0 {
+			return value
+		}
+	}
+	return ""
+}
+
+// SubdomainTenantResolver subdomain of Domain. eg.: acme.example.com with domain example.com resolve acme.
+// Loader find tenant by subdomain, when nil the subdomain should be the tenant uuid
+type SubdomainTenantResolver struct {
+	Domain string
+	Loader func(session *db.Session, subdomain string) (*models.Tenant, error)
+}
+
+func NewSubdomainTenantResolver(domain string, loader func(session *db.Session, subdomain string) (*models.Tenant, error)) *SubdomainTenantResolver {
+	return &SubdomainTenantResolver{Domain: domain, Loader: loader}
+}
+
+func (this *SubdomainTenantResolver) ResolveTenant(ctx *context.Context) string {
+
+	host := ctx.Input.Host()
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	suffix := "." + strings.TrimPrefix(this.Domain, ".")
+
+	if !strings.HasSuffix(host, suffix) {
+		return ""
+	}
+
+	subdomain := strings.TrimSuffix(host, suffix)
+
+	// only first level. eg.: www.acme.example.com is not resolved
+	if len(subdomain) == 0 || strings.Contains(subdomain, ".") || subdomain == "www" {
+		return ""
+	}
+
+	return subdomain
+}
+
+func (this *SubdomainTenantResolver) LoadTenant(session *db.Session, key string) (*models.Tenant, error) {
+	if this.Loader == nil {
+		return models.NewTenant(session).GetByUuidAndEnabled(key)
+	}
+	return this.Loader(session, key)
+}
+
+// PathTenantResolver tenant uuid of path prefix. default prefix is /t/, eg.: /t/{uuid}/api/users
+type PathTenantResolver struct {
+	Prefix string
+}
+
+func NewPathTenantResolver(prefix string) *PathTenantResolver {
+	if len(prefix) == 0 {
+		prefix = "/t/"
+	}
+	return &PathTenantResolver{Prefix: prefix}
+}
+
+func (this *PathTenantResolver) ResolveTenant(ctx *context.Context) string {
+
+	path := ctx.Input.URL()
+
+	if !strings.HasPrefix(path, this.Prefix) {
+		return ""
+	}
+
+	key := strings.TrimPrefix(path, this.Prefix)
+
+	if i := strings.Index(key, "/"); i >= 0 {
+		key = key[:i]
+	}
+
+	return key
+}
+
+// QueryTenantResolver tenant uuid of query param. default param is tenant
+type QueryTenantResolver struct {
+	Name string
+}
+
+func NewQueryTenantResolver(name string) *QueryTenantResolver {
+	if len(name) == 0 {
+		name = "tenant"
+	}
+	return &QueryTenantResolver{Name: name}
+}
+
+func (this *QueryTenantResolver) ResolveTenant(ctx *context.Context) string {
+	return strings.TrimSpace(ctx.Input.Query(this.Name))
+}
+
+// TokenVerifier verify signature and claims of token, returns claims
+type TokenVerifier func(token string) (jwt.MapClaims, error)
+
+var tokenVerifier TokenVerifier
+
+// SetTokenVerifier verifier of JwtClaimTenantResolver, set by services with AuthService.ParseToken of access tokens
+func SetTokenVerifier(verifier TokenVerifier) {
+	tokenVerifier = verifier
+}
+
+// JwtClaimTenantResolver tenant uuid of bearer token claim. default claim is tenant.
+// The token is verified by Verify, or by SetTokenVerifier when nil. Without verifier the tenant is not resolved
+type JwtClaimTenantResolver struct {
+	Claim  string
+	Verify TokenVerifier
+}
+
+func NewJwtClaimTenantResolver(claim string) *JwtClaimTenantResolver {
+	if len(claim) == 0 {
+		claim = "tenant"
+	}
+	return &JwtClaimTenantResolver{Claim: claim}
+}
+
+func (this *JwtClaimTenantResolver) ResolveTenant(ctx *context.Context) string {
+
+	bearer := ctx.Input.Header("Authorization")
+
+	if !strings.HasPrefix(bearer, "Bearer ") {
+		return ""
+	}
+
+	verify := this.Verify
+
+	if verify == nil {
+		verify = tokenVerifier
+	}
+
+	if verify == nil {
+		logs.Warn("jwt tenant resolver without token verifier, import services or set Verify")
+		return ""
+	}
+
+	claims, err := verify(bearer[len("Bearer "):])
+
+	if err != nil {
+		return ""
+	}
+
+	if value, ok := claims[this.Claim]; ok && value != nil {
+		return fmt.Sprintf("%v", value)
+	}
+
+	return ""
+}
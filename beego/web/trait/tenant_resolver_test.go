@@ -0,0 +1,105 @@
+package trait
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/beego/beego/v2/server/web/context"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newResolverContext(t *testing.T, url string, headers map[string]string) *context.Context {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	ctx := context.NewContext()
+	ctx.Reset(httptest.NewRecorder(), req)
+	return ctx
+}
+
+func newResolverToken(t *testing.T, secret string, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "Bearer " + token
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/web/trait -run TestTenantResolvers
+func TestTenantResolvers(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		resolver TenantResolver
+		url      string
+		headers  map[string]string
+		expected string
+	}{
+		{"header", NewHeaderTenantResolver(), "http://example.com/api", map[string]string{"X-Auth-Tenant": " acme "}, "acme"},
+		{"header order", NewHeaderTenantResolver(), "http://example.com/api", map[string]string{"tenant": "first", "X-Auth-Tenant": "second"}, "first"},
+		{"header missing", NewHeaderTenantResolver(), "http://example.com/api", nil, ""},
+		{"subdomain", NewSubdomainTenantResolver("example.com", nil), "http://acme.example.com:8080/api", nil, "acme"},
+		{"subdomain www", NewSubdomainTenantResolver("example.com", nil), "http://www.example.com/api", nil, ""},
+		{"subdomain nested", NewSubdomainTenantResolver("example.com", nil), "http://www.acme.example.com/api", nil, ""},
+		{"subdomain other domain", NewSubdomainTenantResolver("example.com", nil), "http://acme.example.org/api", nil, ""},
+		{"subdomain of domain suffix", NewSubdomainTenantResolver("example.com", nil), "http://acmeexample.com/api", nil, ""},
+		{"path", NewPathTenantResolver(""), "http://example.com/t/acme/api/users", nil, "acme"},
+		{"path root", NewPathTenantResolver(""), "http://example.com/t/acme", nil, "acme"},
+		{"path other prefix", NewPathTenantResolver(""), "http://example.com/api/users", nil, ""},
+		{"query", NewQueryTenantResolver(""), "http://example.com/api?tenant=acme", nil, "acme"},
+		{"func", TenantResolverFunc(func(ctx *context.Context) string { return "acme" }), "http://example.com/api", nil, "acme"},
+	}
+
+	for _, it := range tests {
+		t.Run(it.name, func(t *testing.T) {
+			ctx := newResolverContext(t, it.url, it.headers)
+			if key := it.resolver.ResolveTenant(ctx); key != it.expected {
+				t.Errorf("expected %q, got %q", it.expected, key)
+			}
+		})
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/beego/web/trait -run TestJwtClaimTenantResolver
+func TestJwtClaimTenantResolver(t *testing.T) {
+
+	resolver := NewJwtClaimTenantResolver("")
+	resolver.Verify = func(token string) (jwt.MapClaims, error) {
+		claims := jwt.MapClaims{}
+		_, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+			return []byte("secret"), nil
+		})
+		return claims, err
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		expected string
+	}{
+		{"claim", newResolverToken(t, "secret", jwt.MapClaims{"tenant": "acme"}), "acme"},
+		{"missing claim", newResolverToken(t, "secret", jwt.MapClaims{"user": "ana"}), ""},
+		{"other secret", newResolverToken(t, "other", jwt.MapClaims{"tenant": "acme"}), ""},
+		{"not bearer", "acme", ""},
+	}
+
+	for _, it := range tests {
+		t.Run(it.name, func(t *testing.T) {
+			ctx := newResolverContext(t, "http://example.com/api", map[string]string{"Authorization": it.token})
+			if key := resolver.ResolveTenant(ctx); key != it.expected {
+				t.Errorf("expected %q, got %q", it.expected, key)
+			}
+		})
+	}
+
+	// without verifier the token is not trusted
+	ctx := newResolverContext(t, "http://example.com/api", map[string]string{"Authorization": tests[0].token})
+
+	if key := NewJwtClaimTenantResolver("").ResolveTenant(ctx); len(key) > 0 {
+		t.Errorf("expected tenant not resolved without verifier, got %q", key)
+	}
+}
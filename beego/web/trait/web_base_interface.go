@@ -21,6 +21,8 @@ type WebConfigs struct {
 	NotLoadTenantsOnSession bool
 	CustomAppAuthenticator  func(*models.App) (*models.User, error)
 	ViewPath                string
+	// ordered chain, default is tenant header
+	TenantResolvers []TenantResolver
 }
 
 func (this *WebConfigs) SetViewPath(path string) *WebConfigs {
@@ -57,6 +59,19 @@ func (this *WebConfigs) SetCustomAppAuthenticator(v func(*models.App) (*models.U
 	return this
 }
 
+// SetTenantResolvers eg.: SetTenantResolvers(trait.NewPathTenantResolver("/t/"), trait.NewHeaderTenantResolver())
+func (this *WebConfigs) SetTenantResolvers(resolvers ...TenantResolver) *WebConfigs {
+	this.TenantResolvers = resolvers
+	return this
+}
+
+func (this *WebConfigs) GetTenantResolvers() []TenantResolver {
+	if len(this.TenantResolvers) == 0 {
+		return []TenantResolver{NewHeaderTenantResolver()}
+	}
+	return this.TenantResolvers
+}
+
 func (this *WebConfigs) IsLoadTenantsOnSession() bool {
 	return !this.NotLoadTenantsOnSession
 }
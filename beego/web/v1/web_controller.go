@@ -31,6 +31,7 @@ func (this *WebController) Prepare() {
 func (this *WebController) Finish() {
 	logs.Trace("Finish http call, commit db session")
 	this.ReleaseDbSession()
+	this.LogQueryStats()
 	this.ReleaseCacheService()
 	if app, ok := this.AppController.(misc.NestFinisher); ok {
 		app.NestFinish()
@@ -40,6 +41,7 @@ func (this *WebController) Finish() {
 func (this *WebController) Finally() {
 	logs.Trace("Finally http call, Rollback db session")
 	this.ReleaseDbSessionWithError()
+	this.LogQueryStats()
 	this.ReleaseCacheService()
 }
 
@@ -82,15 +82,15 @@ func (this *CacheService) ExpiresMin(duration int) *CacheService {
 }
 
 func (this *CacheService) NewExpiresMin(duration int) *CacheService {
-	return &CacheService{duration: duration * 60 * 1000, rdb: this.rdb}
+	return &CacheService{duration: duration * 60 * 1000, rdb: this.rdb, sessionKashKey: this.sessionKashKey}
 }
 
 func (this *CacheService) NewExpiresSec(duration int) *CacheService {
-	return &CacheService{duration: duration * 1000, rdb: this.rdb}
+	return &CacheService{duration: duration * 1000, rdb: this.rdb, sessionKashKey: this.sessionKashKey}
 }
 
 func (this *CacheService) NewExpiresMill(duration int) *CacheService {
-	return &CacheService{duration: duration, rdb: this.rdb}
+	return &CacheService{duration: duration, rdb: this.rdb, sessionKashKey: this.sessionKashKey}
 }
 
 func (this *CacheService) getSessionKey(key string) string {
@@ -192,6 +192,30 @@ func (this *CacheService) Delete(keys ...string) *CacheService {
 	return this
 }
 
+// Incr increment counter, without expiration
+func (this *CacheService) Incr(key string) (int64, error) {
+	return this.rdb.Incr(this.getSessionKey(key)).Result()
+}
+
+// GetInt64 get counter value, 0 if not exists
+func (this *CacheService) GetInt64(key string) (int64, error) {
+	v, err := this.rdb.Get(this.getSessionKey(key)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return v, err
+}
+
+// SetNX set value with ttl when key not exists. Returns false when key exists
+func (this *CacheService) SetNX(key string, value interface{}, ttl time.Duration) (bool, error) {
+	return this.rdb.SetNX(this.getSessionKey(key), value, ttl).Result()
+}
+
+func (this *CacheService) Exists(key string) (bool, error) {
+	n, err := this.rdb.Exists(this.getSessionKey(key)).Result()
+	return n > 0, err
+}
+
 func Cached[T any](value interface{}) (T, bool) {
 	var x T
 	switch value.(type) {
@@ -8,10 +8,13 @@ require (
 	github.com/go-redis/redis/v7 v7.4.1
 	github.com/golang-jwt/jwt/v5 v5.3.0
 	github.com/leekchan/accounting v1.0.0
+	github.com/mattn/go-sqlite3 v1.14.28
 	github.com/mobilemindtech/go-io v0.0.0-20250914174532-f74450d8e6a5
 	github.com/satori/go.uuid v1.2.0
 	github.com/sirsean/go-pool v0.0.0-20170808185629-2b94e61c3882
+	golang.org/x/crypto v0.24.0
 	golang.org/x/text v0.29.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -30,9 +33,7 @@ require (
 	github.com/shopspring/decimal v0.0.0-20180709203117-cd690d0c9e24 // indirect
 	github.com/smartystreets/goconvey v1.8.1 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
-	golang.org/x/crypto v0.24.0 // indirect
 	golang.org/x/net v0.23.0 // indirect
 	golang.org/x/sys v0.21.0 // indirect
 	google.golang.org/protobuf v1.34.2 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
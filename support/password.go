@@ -0,0 +1,330 @@
+package support
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+var (
+	ErrPasswordHashFormat = errors.New("invalid password hash format")
+	ErrPasswordHashLength = fmt.Errorf("password hash longer than %v", PasswordHashMaxLength)
+)
+
+// PasswordHashMaxLength max length of encoded hashes, as size(100) of models.User.Password
+const PasswordHashMaxLength = 100
+
+// argon2id and pbkdf2 params limits of encoded hashes, to not panic or exhaust memory and cpu on verify
+const (
+	argon2MaxMemory      = 1024 * 1024 // KiB, 1 GiB
+	argon2MaxIterations  = 64
+	argon2MaxParallelism = 64
+	pbkdf2MaxIterations  = 10000000
+)
+
+// PasswordHasher hash passwords in a self describing format, as $<id>$<params>$<salt>$<hash>
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Verify password of encoded hash. ErrPasswordHashFormat when encoded is not of the hasher
+	Verify(encoded string, password string) (bool, error)
+	// Supports encoded is of hasher format
+	Supports(encoded string) bool
+	// NeedsRehash encoded with other params of hasher
+	NeedsRehash(encoded string) bool
+}
+
+var (
+	passwordHasher   PasswordHasher = NewArgon2idHasher()
+	passwordHashers                 = []PasswordHasher{NewBcryptHasher(), NewArgon2idHasher(), NewPbkdf2Hasher()}
+	passwordHasherMu sync.RWMutex
+)
+
+// SetPasswordHasher hasher of new passwords. Passwords of other hashers and legacy sha1 are still verified,
+// and rehashed on login. Encoded hashes must fit PasswordHashMaxLength. eg.: support.SetPasswordHasher(&support.BcryptHasher{Cost: 12})
+func SetPasswordHasher(hasher PasswordHasher) {
+	passwordHasherMu.Lock()
+	defer passwordHasherMu.Unlock()
+	passwordHasher = hasher
+}
+
+func GetPasswordHasher() PasswordHasher {
+	passwordHasherMu.RLock()
+	defer passwordHasherMu.RUnlock()
+	return passwordHasher
+}
+
+// HashPassword hash password with current hasher. ErrPasswordHashLength when encoded hash is longer than
+// PasswordHashMaxLength, eg.: by KeyLength or SaltLength of hasher
+func HashPassword(password string) (string, error) {
+
+	encoded, err := GetPasswordHasher().Hash(password)
+
+	if err != nil {
+		return "", err
+	}
+
+	if len(encoded) > PasswordHashMaxLength {
+		return "", ErrPasswordHashLength
+	}
+
+	return encoded, nil
+}
+
+// VerifyPassword verify password of encoded hash of any known hasher, or legacy unsalted sha1 (base64 or hex).
+// rehash is true when password match and encoded is legacy or of other hasher or params
+func VerifyPassword(encoded string, password string) (ok bool, rehash bool) {
+
+	current := GetPasswordHasher()
+
+	for _, hasher := range append([]PasswordHasher{current}, passwordHashers...) {
+
+		if !hasher.Supports(encoded) {
+			continue
+		}
+
+		ok, err := hasher.Verify(encoded, password)
+
+		if err != nil || !ok {
+			return false, false
+		}
+
+		return true, hasher != current || current.NeedsRehash(encoded)
+	}
+
+	if IsLegacyPasswordHash(encoded) {
+		ok := isSameLegacyHash(encoded, password)
+		return ok, ok
+	}
+
+	return false, false
+}
+
+// IsLegacyPasswordHash unsalted sha1 as base64 (TextToSha1) or hex (TextToSha1Hex)
+func IsLegacyPasswordHash(encoded string) bool {
+	return !strings.HasPrefix(encoded, "$") && (len(encoded) == 28 || len(encoded) == 40)
+}
+
+func isSameLegacyHash(encoded string, password string) bool {
+	hash := TextToSha1(password)
+	if len(encoded) == 40 {
+		hash = TextToSha1Hex(password)
+	}
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(encoded)) == 1
+}
+
+func randomSalt(size int) ([]byte, error) {
+	salt := make([]byte, size)
+	_, err := rand.Read(salt)
+	return salt, err
+}
+
+var passwordEncoding = base64.RawStdEncoding
+
+// BcryptHasher bcrypt hash, as $2a$<cost>$<salt+hash>. Passwords are limited to 72 bytes
+type BcryptHasher struct {
+	Cost int
+}
+
+func NewBcryptHasher() *BcryptHasher {
+	return &BcryptHasher{Cost: bcrypt.DefaultCost}
+}
+
+func (this *BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), this.Cost)
+	return string(hash), err
+}
+
+func (this *BcryptHasher) Verify(encoded string, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (this *BcryptHasher) Supports(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func (this *BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != this.Cost
+}
+
+// Argon2idHasher argon2id hash, as $argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<hash>.
+// Default params are OWASP recommended (19 MiB, 2 iterations, 1 thread)
+type Argon2idHasher struct {
+	// memory in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  int
+	KeyLength   uint32
+}
+
+func NewArgon2idHasher() *Argon2idHasher {
+	return &Argon2idHasher{Memory: 19 * 1024, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+}
+
+func (this *Argon2idHasher) Hash(password string) (string, error) {
+
+	salt, err := randomSalt(this.SaltLength)
+
+	if err != nil {
+		return "", err
+	}
+
+	hash := argon2.IDKey([]byte(password), salt, this.Iterations, this.Memory, this.Parallelism, this.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, this.Memory, this.Iterations, this.Parallelism,
+		passwordEncoding.EncodeToString(salt), passwordEncoding.EncodeToString(hash)), nil
+}
+
+func (this *Argon2idHasher) decode(encoded string) (params *Argon2idHasher, salt []byte, hash []byte, err error) {
+
+	parts := strings.Split(encoded, "$")
+
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, nil, nil, ErrPasswordHashFormat
+	}
+
+	var version int
+
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, ErrPasswordHashFormat
+	}
+
+	params = new(Argon2idHasher)
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return nil, nil, nil, ErrPasswordHashFormat
+	}
+
+	if params.Memory < 8*uint32(params.Parallelism) || params.Memory > argon2MaxMemory ||
+		params.Iterations < 1 || params.Iterations > argon2MaxIterations ||
+		params.Parallelism < 1 || params.Parallelism > argon2MaxParallelism {
+		return nil, nil, nil, ErrPasswordHashFormat
+	}
+
+	if salt, err = passwordEncoding.DecodeString(parts[4]); err != nil {
+		return nil, nil, nil, ErrPasswordHashFormat
+	}
+
+	// empty hash match any password
+	if hash, err = passwordEncoding.DecodeString(parts[5]); err != nil || len(hash) == 0 {
+		return nil, nil, nil, ErrPasswordHashFormat
+	}
+
+	params.SaltLength = len(salt)
+	params.KeyLength = uint32(len(hash))
+
+	return params, salt, hash, nil
+}
+
+func (this *Argon2idHasher) Verify(encoded string, password string) (bool, error) {
+
+	params, salt, hash, err := this.decode(encoded)
+
+	if err != nil {
+		return false, err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+
+	return subtle.ConstantTimeCompare(hash, other) == 1, nil
+}
+
+func (this *Argon2idHasher) Supports(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func (this *Argon2idHasher) NeedsRehash(encoded string) bool {
+	params, _, _, err := this.decode(encoded)
+	return err != nil || *params != *this
+}
+
+// Pbkdf2Hasher pbkdf2 with sha256, as $pbkdf2-sha256$i=<iterations>$<salt>$<hash>.
+// Default iterations is OWASP recommended (600000)
+type Pbkdf2Hasher struct {
+	Iterations int
+	SaltLength int
+	KeyLength  int
+}
+
+func NewPbkdf2Hasher() *Pbkdf2Hasher {
+	return &Pbkdf2Hasher{Iterations: 600000, SaltLength: 16, KeyLength: 32}
+}
+
+func (this *Pbkdf2Hasher) Hash(password string) (string, error) {
+
+	salt, err := randomSalt(this.SaltLength)
+
+	if err != nil {
+		return "", err
+	}
+
+	hash := pbkdf2.Key([]byte(password), salt, this.Iterations, this.KeyLength, sha256.New)
+
+	return fmt.Sprintf("$pbkdf2-sha256$i=%d$%s$%s",
+		this.Iterations, passwordEncoding.EncodeToString(salt), passwordEncoding.EncodeToString(hash)), nil
+}
+
+func (this *Pbkdf2Hasher) decode(encoded string) (params *Pbkdf2Hasher, salt []byte, hash []byte, err error) {
+
+	parts := strings.Split(encoded, "$")
+
+	if len(parts) != 5 || parts[1] != "pbkdf2-sha256" {
+		return nil, nil, nil, ErrPasswordHashFormat
+	}
+
+	params = new(Pbkdf2Hasher)
+
+	if _, err := fmt.Sscanf(parts[2], "i=%d", &params.Iterations); err != nil || params.Iterations < 1 || params.Iterations > pbkdf2MaxIterations {
+		return nil, nil, nil, ErrPasswordHashFormat
+	}
+
+	if salt, err = passwordEncoding.DecodeString(parts[3]); err != nil {
+		return nil, nil, nil, ErrPasswordHashFormat
+	}
+
+	if hash, err = passwordEncoding.DecodeString(parts[4]); err != nil || len(hash) == 0 {
+		return nil, nil, nil, ErrPasswordHashFormat
+	}
+
+	params.SaltLength = len(salt)
+	params.KeyLength = len(hash)
+
+	return params, salt, hash, nil
+}
+
+func (this *Pbkdf2Hasher) Verify(encoded string, password string) (bool, error) {
+
+	params, salt, hash, err := this.decode(encoded)
+
+	if err != nil {
+		return false, err
+	}
+
+	other := pbkdf2.Key([]byte(password), salt, params.Iterations, params.KeyLength, sha256.New)
+
+	return subtle.ConstantTimeCompare(hash, other) == 1, nil
+}
+
+func (this *Pbkdf2Hasher) Supports(encoded string) bool {
+	return strings.HasPrefix(encoded, "$pbkdf2-sha256$")
+}
+
+func (this *Pbkdf2Hasher) NeedsRehash(encoded string) bool {
+	params, _, _, err := this.decode(encoded)
+	return err != nil || *params != *this
+}
@@ -0,0 +1,154 @@
+package support
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// go test -v github.com/mobilemindtech/go-utils/support -run TestPasswordHashers
+func TestPasswordHashers(t *testing.T) {
+
+	hashers := []PasswordHasher{
+		&BcryptHasher{Cost: bcrypt.MinCost},
+		NewArgon2idHasher(),
+		&Pbkdf2Hasher{Iterations: 1000, SaltLength: 16, KeyLength: 32},
+	}
+
+	for _, hasher := range hashers {
+
+		encoded, err := hasher.Hash("secret")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !hasher.Supports(encoded) || hasher.NeedsRehash(encoded) {
+			t.Errorf("expected %T hash supported without rehash", hasher)
+		}
+
+		if ok, err := hasher.Verify(encoded, "secret"); !ok || err != nil {
+			t.Errorf("expected %T password verified, got %v", hasher, err)
+		}
+
+		if ok, _ := hasher.Verify(encoded, "other"); ok {
+			t.Errorf("expected %T other password not verified", hasher)
+		}
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/support -run TestVerifyPassword
+func TestVerifyPassword(t *testing.T) {
+
+	current := GetPasswordHasher()
+	defer SetPasswordHasher(current)
+
+	SetPasswordHasher(&Pbkdf2Hasher{Iterations: 1000, SaltLength: 16, KeyLength: 32})
+
+	encoded, err := HashPassword("secret")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, rehash := VerifyPassword(encoded, "secret"); !ok || rehash {
+		t.Errorf("expected password of current hasher without rehash")
+	}
+
+	argon2, _ := NewArgon2idHasher().Hash("secret")
+
+	if ok, rehash := VerifyPassword(argon2, "secret"); !ok || !rehash {
+		t.Errorf("expected password of other hasher with rehash")
+	}
+
+	if ok, rehash := VerifyPassword(TextToSha1("secret"), "secret"); !ok || !rehash {
+		t.Errorf("expected legacy password with rehash")
+	}
+
+	if ok, _ := VerifyPassword(TextToSha1("secret"), "other"); ok {
+		t.Errorf("expected legacy other password not verified")
+	}
+
+	if ok, _ := VerifyPassword("$unknown$hash", "secret"); ok {
+		t.Errorf("expected unknown hash not verified")
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/support -run TestArgon2idHashFormat
+func TestArgon2idHashFormat(t *testing.T) {
+
+	salt := "c2FsdHNhbHRzYWx0c2FsdA"
+	hash := "aGFzaGhhc2hoYXNoaGFzaGhhc2hoYXNoaGFzaGhhc2g"
+
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{"zero parallelism", "$argon2id$v=19$m=19456,t=2,p=0$" + salt + "$" + hash},
+		{"zero iterations", "$argon2id$v=19$m=19456,t=0,p=1$" + salt + "$" + hash},
+		{"huge memory", "$argon2id$v=19$m=4294967295,t=2,p=1$" + salt + "$" + hash},
+		{"low memory", "$argon2id$v=19$m=4,t=2,p=1$" + salt + "$" + hash},
+		{"huge iterations", "$argon2id$v=19$m=19456,t=100000,p=1$" + salt + "$" + hash},
+		{"parallelism overflow", "$argon2id$v=19$m=19456,t=2,p=300$" + salt + "$" + hash},
+		{"empty hash", "$argon2id$v=19$m=19456,t=2,p=1$" + salt + "$"},
+		{"other version", "$argon2id$v=16$m=19456,t=2,p=1$" + salt + "$" + hash},
+		{"missing params", "$argon2id$v=19$" + salt + "$" + hash},
+	}
+
+	hasher := NewArgon2idHasher()
+
+	for _, it := range tests {
+		t.Run(it.name, func(t *testing.T) {
+
+			ok, err := hasher.Verify(it.encoded, "secret")
+
+			if ok || !errors.Is(err, ErrPasswordHashFormat) {
+				t.Errorf("expected hash format error, got %v", err)
+			}
+
+			if !hasher.NeedsRehash(it.encoded) {
+				t.Errorf("expected rehash of invalid hash")
+			}
+		})
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/support -run TestPbkdf2HashFormat
+func TestPbkdf2HashFormat(t *testing.T) {
+
+	salt := "c2FsdHNhbHRzYWx0c2FsdA"
+	hash := "aGFzaGhhc2hoYXNoaGFzaGhhc2hoYXNoaGFzaGhhc2g"
+
+	hasher := NewPbkdf2Hasher()
+
+	for _, encoded := range []string{
+		"$pbkdf2-sha256$i=0$" + salt + "$" + hash,
+		"$pbkdf2-sha256$i=2000000000$" + salt + "$" + hash,
+	} {
+		if ok, err := hasher.Verify(encoded, "secret"); ok || !errors.Is(err, ErrPasswordHashFormat) {
+			t.Errorf("expected hash format error of %v, got %v", encoded, err)
+		}
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/support -run TestHashPasswordLength
+func TestHashPasswordLength(t *testing.T) {
+
+	defer SetPasswordHasher(GetPasswordHasher())
+
+	for _, hasher := range []PasswordHasher{NewArgon2idHasher(), NewBcryptHasher(), NewPbkdf2Hasher()} {
+
+		SetPasswordHasher(hasher)
+
+		if encoded, err := HashPassword("secret"); err != nil || len(encoded) > PasswordHashMaxLength {
+			t.Errorf("expected hash of %T fits max length, got %v %v", hasher, len(encoded), err)
+		}
+	}
+
+	SetPasswordHasher(&Argon2idHasher{Memory: 19 * 1024, Iterations: 2, Parallelism: 1, SaltLength: 32, KeyLength: 64})
+
+	if _, err := HashPassword("secret"); !errors.Is(err, ErrPasswordHashLength) {
+		t.Errorf("expected hash length error, got %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package criteria
+
+import (
+	"github.com/mobilemindtech/go-utils/beego/db"
+)
+
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 |
+		~float32 | ~float64
+}
+
+// Aggregate function of field, used by GroupBy
+type Aggregate struct {
+	Func  db.AggregateFunc
+	Field string
+}
+
+func SumOf(field string) *Aggregate {
+	return &Aggregate{Func: db.AggregateSum, Field: field}
+}
+
+func AvgOf(field string) *Aggregate {
+	return &Aggregate{Func: db.AggregateAvg, Field: field}
+}
+
+func MinOf(field string) *Aggregate {
+	return &Aggregate{Func: db.AggregateMin, Field: field}
+}
+
+func MaxOf(field string) *Aggregate {
+	return &Aggregate{Func: db.AggregateMax, Field: field}
+}
+
+func CountOf() *Aggregate {
+	return &Aggregate{Func: db.AggregateCount}
+}
+
+func CountDistinctOf(field string) *Aggregate {
+	return &Aggregate{Func: db.AggregateCountDistinct, Field: field}
+}
+
+type GroupRow[K comparable, R any] struct {
+	Key   K `json:"key" jsonp:""`
+	Value R `json:"value" jsonp:""`
+}
+
+func aggregateValue[T any, R any](c *Criteria[T], fn db.AggregateFunc, field string) (R, error) {
+	var r R
+	c.Criteria.AggregateValue(fn, field, &r)
+	if c.Criteria.HasError {
+		return r, c.Error
+	}
+	return r, nil
+}
+
+// Sum of field, with criteria conditions and tenant filter. eg.: criteria.Sum[*Order, float64](c, "Total")
+func Sum[T any, R Number](c *Criteria[T], field string) (R, error) {
+	return aggregateValue[T, R](c, db.AggregateSum, field)
+}
+
+func Avg[T any, R Number](c *Criteria[T], field string) (R, error) {
+	return aggregateValue[T, R](c, db.AggregateAvg, field)
+}
+
+// Min of field. R can be number, string or time.Time
+func Min[T any, R any](c *Criteria[T], field string) (R, error) {
+	return aggregateValue[T, R](c, db.AggregateMin, field)
+}
+
+// Max of field. R can be number, string or time.Time
+func Max[T any, R any](c *Criteria[T], field string) (R, error) {
+	return aggregateValue[T, R](c, db.AggregateMax, field)
+}
+
+func CountDistinct[T any](c *Criteria[T], field string) (int64, error) {
+	return aggregateValue[T, int64](c, db.AggregateCountDistinct, field)
+}
+
+// GroupByRows aggregate grouped by key field, ordered by key. Relations are grouped by id.
+// eg.: criteria.GroupByRows[*Order, int64, float64](c, "Customer", criteria.SumOf("Total"))
+func GroupByRows[T any, K comparable, R any](c *Criteria[T], key string, aggregate *Aggregate) ([]*GroupRow[K, R], error) {
+
+	var keys []K
+	var values []R
+
+	c.Criteria.AggregateGroup(key, aggregate.Func, aggregate.Field, &keys, &values)
+
+	if c.Criteria.HasError {
+		return nil, c.Error
+	}
+
+	rows := []*GroupRow[K, R]{}
+
+	for i, it := range keys {
+		var value R
+		if i < len(values) {
+			value = values[i]
+		}
+		rows = append(rows, &GroupRow[K, R]{Key: it, Value: value})
+	}
+
+	return rows, nil
+}
+
+// GroupBy aggregate grouped by key field, as map
+func GroupBy[T any, K comparable, R any](c *Criteria[T], key string, aggregate *Aggregate) (map[K]R, error) {
+
+	rows, err := GroupByRows[T, K, R](c, key, aggregate)
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := map[K]R{}
+
+	for _, it := range rows {
+		results[it.Key] = it.Value
+	}
+
+	return results, nil
+}
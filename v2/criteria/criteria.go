@@ -10,8 +10,10 @@ import (
 	"github.com/mobilemindtech/go-io/util"
 	"github.com/mobilemindtech/go-utils/v2/lists"
 	"github.com/mobilemindtech/go-utils/assert"
+	"iter"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/mobilemindtech/go-utils/beego/db"
 	"github.com/mobilemindtech/go-utils/v2/optional"
@@ -20,6 +22,7 @@ import (
 type Page struct {
 	TotalCount int         `json:"total_count" jsonp:""`
 	Data       interface{} `json:"data" jsonp:""`
+	NextCursor string      `json:"next_cursor,omitempty" jsonp:""`
 }
 
 func (this *Page) Count() int64 {
@@ -29,6 +32,8 @@ func (this *Page) Count() int64 {
 type PageOf[T any] struct {
 	TotalCount int `json:"total_count" jsonp:""`
 	Data       []T `json:"data" jsonp:""`
+	// keyset pagination next page token, empty on last page
+	NextCursor string `json:"next_cursor,omitempty" jsonp:""`
 }
 
 func NewPageOf[T any](data []T, totalCount int) *PageOf[T] {
@@ -47,7 +52,7 @@ func (this *PageOf[T]) Count() int64 {
 
 func (this *PageOf[T]) ToPage() *Page {
 	data := lists.Map[T, interface{}](this.Data, func(t T) interface{} { return t })
-	return &Page{Data: data, TotalCount: this.TotalCount}
+	return &Page{Data: data, TotalCount: this.TotalCount, NextCursor: this.NextCursor}
 }
 
 func MapPageOf[T any, R any](
@@ -56,7 +61,7 @@ func MapPageOf[T any, R any](
 	if p1.IsSome() {
 		page := p1.Get()
 		results := lists.Map[T, R](page.Data, fn)
-		return optional.Of[*PageOf[R]](&PageOf[R]{Data: results, TotalCount: page.TotalCount})
+		return optional.Of[*PageOf[R]](&PageOf[R]{Data: results, TotalCount: page.TotalCount, NextCursor: page.NextCursor})
 	}
 
 	return optional.Of[*PageOf[R]](p1.Val())
@@ -97,6 +102,7 @@ func (this *Reactive) Get() interface{} {
 	} else if this.criteria.IsListAndCount() {
 		r = &Page{
 			TotalCount: this.criteria.Count32,
+			NextCursor: this.criteria.NextCursor,
 			Data:       reflect.ValueOf(this.criteria.Results).Elem().Interface()}
 	} else if this.criteria.IsExists() {
 		r = this.criteria.Any
@@ -288,6 +294,7 @@ func (this *Criteria[T]) OptPage() *optional.Optional[*PageOf[T]] {
 	all := reflect.ValueOf(this.Criteria.Results).Elem().Interface().([]T)
 	return optional.OfSome[*PageOf[T]](&PageOf[T]{
 		TotalCount: this.Criteria.Count32,
+		NextCursor: this.Criteria.NextCursor,
 		Data:       all})
 }
 
@@ -425,16 +432,66 @@ func (this *Criteria[T]) GetPage() *result.Result[*PageOf[T]] {
 	}
 
 	all := reflect.ValueOf(this.Criteria.Results).Elem().Interface().([]T)
-	page := &PageOf[T]{this.Criteria.Count32, all}
+	page := &PageOf[T]{TotalCount: this.Criteria.Count32, Data: all, NextCursor: this.Criteria.NextCursor}
 	return result.OfValue(page)
 
 }
 
+// Cached cache query results for ttl. See db.Criteria.Cached
+func (this *Criteria[T]) Cached(ttl time.Duration) *Criteria[T] {
+	this.Criteria.Cached(ttl)
+	return this
+}
+
+// AfterCursor enable keyset pagination, returning rows after token
+func (this *Criteria[T]) AfterCursor(token string) *Criteria[T] {
+	this.Criteria.AfterCursor(token)
+	return this
+}
+
+// PageCursor list next page of keyset pagination. TotalCount is not computed,
+// NextCursor is empty on last page
+func (this *Criteria[T]) PageCursor() *result.Result[*PageOf[T]] {
+	this.Criteria.PageCursor()
+
+	if this.Criteria.HasError {
+		return result.OfError[*PageOf[T]](this.Error)
+	}
+
+	all := reflect.ValueOf(this.Criteria.Results).Elem().Interface().([]T)
+	page := &PageOf[T]{Data: all, NextCursor: this.Criteria.NextCursor}
+	return result.OfValue(page)
+}
+
 func (this *Criteria[T]) Eager(related ...string) *Criteria[T] {
 	this.Criteria.SetRelatedsSel(related...)
 	return this
 }
 
+// EagerList load relations of results with one IN query by relation, nested with __. eg.: EagerList("User", "User__Role")
+func (this *Criteria[T]) EagerList(paths ...string) *Criteria[T] {
+	this.Criteria.EagerList(paths...)
+	return this
+}
+
+// WithDeleted include soft removed rows
+func (this *Criteria[T]) WithDeleted() *Criteria[T] {
+	this.Criteria.WithDeleted()
+	return this
+}
+
+// OnlyDeleted filter only soft removed rows
+func (this *Criteria[T]) OnlyDeleted() *Criteria[T] {
+	this.Criteria.OnlyDeleted()
+	return this
+}
+
+// Restore unmark soft removed rows, return restored rows count
+func (this *Criteria[T]) Restore() (int, error) {
+	this.Criteria.Restore()
+	return this.Count32, this.Error
+}
+
 func (this *Criteria[T]) Builder(f func(c *Criteria[T])) *Criteria[T] {
 	f(this)
 	return this
@@ -486,6 +543,27 @@ func (this *Criteria[T]) Each(each func(T)) error {
 	return nil
 }
 
+// Stream iterate results paging by primary key ranges, loading batchSize rows per query.
+// Ordering is replaced by Id asc. On error, yields zero value with error and stops
+func (this *Criteria[T]) Stream(batchSize int) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+
+		err := this.Criteria.Stream(int64(batchSize), func(results interface{}) bool {
+			for _, it := range *(results.(*[]T)) {
+				if !yield(it, nil) {
+					return false
+				}
+			}
+			return true
+		})
+
+		if err != nil {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
+
 func (this *Criteria[T]) List() ([]T, error) {
 	this.Criteria.List()
 	return this.GetResults()
@@ -499,7 +577,7 @@ func (this *Criteria[T]) Exists() (bool, error) {
 func (this *Criteria[T]) Page() (*PageOf[T], error) {
 	this.Criteria.ListAndCount()
 	r, err := this.GetResults()
-	return &PageOf[T]{Data: r, TotalCount: this.Count32}, err
+	return &PageOf[T]{Data: r, TotalCount: this.Count32, NextCursor: this.NextCursor}, err
 }
 
 func (this *Criteria[T]) GetResult() (T, error) {
@@ -688,6 +766,47 @@ func (this *Criteria[T]) Raw(path string, query string) *Criteria[T] {
 	return this
 }
 
+// Filter add json filter DSL conditions. See db.Filter
+func (this *Criteria[T]) Filter(filter *db.Filter) *Criteria[T] {
+	this.Criteria.Filter(filter)
+	return this
+}
+
+// InSubquery filter path in values selected by subquery, with its own tenant filter.
+// eg.: c.InSubquery("Id", &criteria.New[*UserRole](s).Eq("Role", role).Select("User").Criteria)
+func (this *Criteria[T]) InSubquery(path string, subquery *db.Criteria) *Criteria[T] {
+	this.Criteria.InSubquery(path, subquery)
+	return this
+}
+
+func (this *Criteria[T]) NotInSubquery(path string, subquery *db.Criteria) *Criteria[T] {
+	this.Criteria.NotInSubquery(path, subquery)
+	return this
+}
+
+// ExistsSubquery filter rows where subquery has results. Exists is the terminal operation
+func (this *Criteria[T]) ExistsSubquery(subquery *db.Criteria) *Criteria[T] {
+	this.Criteria.ExistsSubquery(subquery)
+	return this
+}
+
+func (this *Criteria[T]) NotExistsSubquery(subquery *db.Criteria) *Criteria[T] {
+	this.Criteria.NotExistsSubquery(subquery)
+	return this
+}
+
+// EqOuter compare path with outerPath of outer query, to correlated subqueries
+func (this *Criteria[T]) EqOuter(path string, outerPath string) *Criteria[T] {
+	this.Criteria.EqOuter(path, outerPath)
+	return this
+}
+
+// Select path of subquery result, default is Id
+func (this *Criteria[T]) Select(path string) *Criteria[T] {
+	this.Criteria.Select(path)
+	return this
+}
+
 func (this *Criteria[T]) AndOr(criteria *db.Criteria) *Criteria[T] {
 	this.Criteria.AndOr(criteria)
 	return this
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/mobilemindtech/go-utils/beego/db"
+	"github.com/mobilemindtech/go-utils/v2/criteria"
+)
+
+// Spec composable filter of model query. eg.: repository.And(Active[User](), repository.Eq[User]("Role", role))
+type Spec[T any] func(c *criteria.Criteria[T])
+
+func Eq[T any](path string, value interface{}) Spec[T] {
+	return func(c *criteria.Criteria[T]) {
+		c.Eq(path, value)
+	}
+}
+
+func Ne[T any](path string, value interface{}) Spec[T] {
+	return func(c *criteria.Criteria[T]) {
+		c.Ne(path, value)
+	}
+}
+
+func In[T any](path string, values ...interface{}) Spec[T] {
+	return func(c *criteria.Criteria[T]) {
+		c.In(path, values...)
+	}
+}
+
+func NotIn[T any](path string, values ...interface{}) Spec[T] {
+	return func(c *criteria.Criteria[T]) {
+		c.NotIn(path, values...)
+	}
+}
+
+func IsNull[T any](path string) Spec[T] {
+	return func(c *criteria.Criteria[T]) {
+		c.IsNull(path)
+	}
+}
+
+func Like[T any](path string, value interface{}) Spec[T] {
+	return func(c *criteria.Criteria[T]) {
+		c.Like(path, value)
+	}
+}
+
+// And all specs
+func And[T any](specs ...Spec[T]) Spec[T] {
+	return func(c *criteria.Criteria[T]) {
+		for _, spec := range specs {
+			spec(c)
+		}
+	}
+}
+
+// Or of specs, as AND ((a AND b) OR c). Specs can be And of conditions, nested Or sets criteria error
+func Or[T any](specs ...Spec[T]) Spec[T] {
+	return func(c *criteria.Criteria[T]) {
+		set := db.NewCriteriaSet()
+		for _, spec := range specs {
+			cond := new(criteria.Criteria[T])
+			spec(cond)
+			if cond.HasGroups() {
+				c.SetError(errors.New("nested Or spec is not supported"))
+				return
+			}
+			set.AddCriteria(&cond.Criteria)
+		}
+		c.AndOrAnd(set)
+	}
+}
+
+// Repository common queries of model, built on criteria.Criteria[T]. Models can delegate to it:
+//
+//	func (this *User) FindByEmail(email string) (*User, error) {
+//		return repository.New[*User](this.Session).FindOneBy("Email", email)
+//	}
+type Repository[T db.Model] struct {
+	Session *db.Session
+	eager   []string
+}
+
+func New[T db.Model](session *db.Session) *Repository[T] {
+	return &Repository[T]{Session: session}
+}
+
+// Eager relations of results, loaded with one IN query by relation
+func (this *Repository[T]) Eager(paths ...string) *Repository[T] {
+	return &Repository[T]{Session: this.Session, eager: append(append([]string{}, this.eager...), paths...)}
+}
+
+// Criteria of specs, to custom queries
+func (this *Repository[T]) Criteria(specs ...Spec[T]) *criteria.Criteria[T] {
+	c := criteria.New[T](this.Session)
+	if len(this.eager) > 0 {
+		c.EagerList(this.eager...)
+	}
+	for _, spec := range specs {
+		spec(c)
+	}
+	return c
+}
+
+// FindById returns zero value when not found
+func (this *Repository[T]) FindById(id int64) (T, error) {
+	return this.Criteria().Id(id).First()
+}
+
+func (this *Repository[T]) FindAll(specs ...Spec[T]) ([]T, error) {
+	return this.Criteria(specs...).List()
+}
+
+// FindOne first result of specs. returns zero value when not found
+func (this *Repository[T]) FindOne(specs ...Spec[T]) (T, error) {
+	return this.Criteria(specs...).First()
+}
+
+func (this *Repository[T]) FindBy(field string, value interface{}) ([]T, error) {
+	return this.FindAll(Eq[T](field, value))
+}
+
+func (this *Repository[T]) FindOneBy(field string, value interface{}) (T, error) {
+	return this.FindOne(Eq[T](field, value))
+}
+
+// Page results and total count of specs. page sort, search and filter are applied
+func (this *Repository[T]) Page(page *db.Page, specs ...Spec[T]) (*criteria.PageOf[T], error) {
+	return this.Criteria(specs...).SetPage(page).Page()
+}
+
+func (this *Repository[T]) Count(specs ...Spec[T]) (int, error) {
+	c := this.Criteria(specs...)
+	c.Criteria.Count()
+	return c.Count32, c.Error
+}
+
+func (this *Repository[T]) Exists(specs ...Spec[T]) (bool, error) {
+	c := this.Criteria(specs...)
+	exists := c.Criteria.Exists()
+	return exists, c.Error
+}
+
+func (this *Repository[T]) ExistsById(id int64) (bool, error) {
+	return this.Exists(Eq[T]("Id", id))
+}
+
+// Save insert or update entity
+func (this *Repository[T]) Save(entity T) error {
+	return this.Session.SaveOrUpdate(entity)
+}
+
+func (this *Repository[T]) Delete(entity T) error {
+	return this.Session.Remove(entity)
+}
+
+// LoadRelated load relations of entities with one IN query by relation. eg.: LoadRelated(users, "Role")
+func (this *Repository[T]) LoadRelated(entities []T, paths ...string) error {
+	return this.Session.EagerList(entities, paths...)
+}
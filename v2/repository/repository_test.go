@@ -0,0 +1,220 @@
+package repository_test
+
+import (
+	"log"
+	"os"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/mobilemindtech/go-utils/beego/db"
+	"github.com/mobilemindtech/go-utils/beego/dbtest"
+	"github.com/mobilemindtech/go-utils/v2/repository"
+)
+
+type Category struct {
+	Id   int64  `json:",string,omitempty"`
+	Name string `orm:"size(50)"`
+}
+
+func (this *Category) TableName() string {
+	return "categories"
+}
+
+func (this *Category) IsPersisted() bool {
+	return this.Id > 0
+}
+
+type Product struct {
+	Id       int64     `json:",string,omitempty"`
+	Code     string    `orm:"size(20);unique"`
+	Name     string    `orm:"size(50)"`
+	Price    float64   `orm:"digits(10);decimals(2)"`
+	Category *Category `orm:"rel(fk);null"`
+}
+
+func (this *Product) TableName() string {
+	return "products"
+}
+
+func (this *Product) IsPersisted() bool {
+	return this.Id > 0
+}
+
+func TestMain(m *testing.M) {
+	if err := dbtest.Setup(new(Category), new(Product)); err != nil {
+		log.Fatal(err)
+	}
+	os.Exit(m.Run())
+}
+
+func newProducts(t *testing.T) *repository.Repository[*Product] {
+
+	t.Helper()
+
+	session := dbtest.NewSession(t)
+	category := &Category{Name: "books"}
+
+	if err := session.Save(category); err != nil {
+		t.Fatal(err)
+	}
+
+	products := repository.New[*Product](session)
+
+	for _, it := range []*Product{
+		{Code: "p1", Name: "a", Price: 1, Category: category},
+		{Code: "p2", Name: "b", Price: 2, Category: category},
+		{Code: "p3", Name: "a", Price: 3},
+	} {
+		if err := products.Save(it); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return products
+}
+
+func codes(products []*Product) []string {
+	codes := []string{}
+	for _, it := range products {
+		codes = append(codes, it.Code)
+	}
+	return codes
+}
+
+// go test -v github.com/mobilemindtech/go-utils/v2/repository -run TestRepository
+func TestRepository(t *testing.T) {
+
+	products := newProducts(t)
+
+	p1, err := products.FindOneBy("Code", "p1")
+
+	if err != nil || p1 == nil {
+		t.Fatalf("expected p1, got %v", err)
+	}
+
+	if found, err := products.FindById(p1.Id); err != nil || found.Code != "p1" {
+		t.Errorf("expected p1 by id, got %v", err)
+	}
+
+	if found, err := products.FindById(-1); err != nil || found != nil {
+		t.Errorf("expected nil of not found, got %v", err)
+	}
+
+	if list, err := products.FindBy("Name", "a"); err != nil || len(list) != 2 {
+		t.Errorf("expected p1 and p3, got %v", codes(list))
+	}
+
+	if count, err := products.Count(repository.Eq[*Product]("Name", "a")); err != nil || count != 2 {
+		t.Errorf("expected count 2, got %v", count)
+	}
+
+	p1.Price = 10
+
+	if err := products.Save(p1); err != nil {
+		t.Fatal(err)
+	}
+
+	if exists, err := products.Exists(repository.Eq[*Product]("Price", 10)); err != nil || !exists {
+		t.Errorf("expected updated p1, got %v", err)
+	}
+
+	if err := products.Delete(p1); err != nil {
+		t.Fatal(err)
+	}
+
+	if exists, err := products.ExistsById(p1.Id); err != nil || exists {
+		t.Errorf("expected p1 deleted, got %v", err)
+	}
+
+	page, err := products.Page(&db.Page{Limit: 1})
+
+	if err != nil || len(page.Data) != 1 || page.TotalCount != 2 {
+		t.Errorf("expected page of 1 with total 2, got %v", err)
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/v2/repository -run TestRepositorySpecs
+func TestRepositorySpecs(t *testing.T) {
+
+	products := newProducts(t)
+
+	// (Name = a AND Price = 1) OR Name = b
+	list, err := products.FindAll(repository.Or(
+		repository.And(repository.Eq[*Product]("Name", "a"), repository.Eq[*Product]("Price", 1)),
+		repository.Eq[*Product]("Name", "b")))
+
+	if err != nil || len(list) != 2 || list[0].Code != "p1" || list[1].Code != "p2" {
+		t.Errorf("expected p1 and p2, got %v %v", codes(list), err)
+	}
+
+	// each Or is a group: (p1 OR p2) AND (p2 OR p3)
+	list, err = products.FindAll(
+		repository.Or(repository.Eq[*Product]("Code", "p1"), repository.Eq[*Product]("Code", "p2")),
+		repository.Or(repository.Eq[*Product]("Code", "p2"), repository.Eq[*Product]("Code", "p3")))
+
+	if err != nil || len(list) != 1 || list[0].Code != "p2" {
+		t.Errorf("expected p2, got %v %v", codes(list), err)
+	}
+
+	list, err = products.FindAll(repository.Or(
+		repository.In[*Product]("Code", "p1", "p3"),
+		repository.Eq[*Product]("Name", "b")))
+
+	if err != nil || len(list) != 3 || list[0].Code != "p1" || list[1].Code != "p2" || list[2].Code != "p3" {
+		t.Errorf("expected p1, p2 and p3, got %v %v", codes(list), err)
+	}
+
+	list, err = products.FindAll(repository.Or(
+		repository.Ne[*Product]("Name", "a"),
+		repository.Eq[*Product]("Code", "p1")))
+
+	if err != nil || len(list) != 2 || list[0].Code != "p1" || list[1].Code != "p2" {
+		t.Errorf("expected p1 and p2, got %v %v", codes(list), err)
+	}
+
+	list, err = products.FindAll(repository.Or(
+		repository.Like[*Product]("Code", "p3"),
+		repository.Eq[*Product]("Name", "b")))
+
+	if err != nil || len(list) != 2 || list[0].Code != "p2" || list[1].Code != "p3" {
+		t.Errorf("expected p2 and p3, got %v %v", codes(list), err)
+	}
+
+	list, err = products.FindAll(repository.Or(
+		repository.NotIn[*Product]("Code", "p1", "p2"),
+		repository.Eq[*Product]("Name", "b")))
+
+	if err != nil || len(list) != 2 || list[0].Code != "p2" || list[1].Code != "p3" {
+		t.Errorf("expected p2 and p3, got %v %v", codes(list), err)
+	}
+
+	_, err = products.FindAll(repository.Or(
+		repository.Eq[*Product]("Code", "p1"),
+		repository.Or(repository.Eq[*Product]("Code", "p2"), repository.Eq[*Product]("Code", "p3"))))
+
+	if err == nil {
+		t.Errorf("expected error of nested Or")
+	}
+}
+
+// go test -v github.com/mobilemindtech/go-utils/v2/repository -run TestRepositoryEager
+func TestRepositoryEager(t *testing.T) {
+
+	products := newProducts(t)
+
+	list, err := products.Eager("Category").FindBy("Code", "p1")
+
+	if err != nil || len(list) != 1 {
+		t.Fatalf("expected p1, got %v", err)
+	}
+
+	if list[0].Category == nil || list[0].Category.Name != "books" {
+		t.Errorf("expected category loaded")
+	}
+
+	list, err = products.FindBy("Code", "p1")
+
+	if err != nil || len(list) != 1 || len(list[0].Category.Name) > 0 {
+		t.Errorf("expected category not loaded without eager")
+	}
+}
@@ -464,6 +464,27 @@ func (this *RxSession[T]) PersistBatch(entities ...interface{}) *result.Result[*
 	return result.OfValue(unit.OfUnit())
 }
 
+// Upsert insert or update entity on conflict, in only one statement. See db.Session.Upsert
+func (this *RxSession[T]) Upsert(entity T, conflictCols ...string) *result.Result[bool] {
+	return result.Try(func() (bool, error) {
+		return this.session.Upsert(entity, conflictCols...)
+	})
+}
+
+// SaveBatch insert entities in chunks with InsertMulti. See db.Session.SaveBatch
+func (this *RxSession[T]) SaveBatch(entities []T, chunkSize int) *result.Result[int64] {
+	return result.Try(func() (int64, error) {
+		return this.session.SaveBatch(entities, chunkSize)
+	})
+}
+
+// UpdateBatch update entities in chunks. See db.Session.UpdateBatch
+func (this *RxSession[T]) UpdateBatch(entities []T, chunkSize int) *result.Result[int64] {
+	return result.Try(func() (int64, error) {
+		return this.session.UpdateBatch(entities, chunkSize)
+	})
+}
+
 func (this *RxSession[T]) PersistIO(entity T) *types.IO[T] {
 	return io.IO[T](
 		io.Attempt(